@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encryption methods for backups.
+const (
+	encryptNone       = "none"
+	encryptAge        = "age"
+	encryptPassphrase = "passphrase"
+)
+
+var encryptionMethods = []string{encryptNone, encryptAge, encryptPassphrase}
+
+var (
+	ageMagic        = []byte("age-encryption.org/v1\n")
+	passphraseMagic = []byte("TOOLSENC")
+)
+
+// Passphrase encrypted backups are a header followed by AES-256-GCM sealed
+// chunks of gcmChunkSize bytes. The last chunk is sealed with a different
+// additional data byte so a truncated backup does not decrypt.
+const (
+	passphraseVersion = 1
+	scryptLogN        = 15
+	saltSize          = 16
+	gcmChunkSize      = 64 << 10
+)
+
+// resolveEncryption fills in the encryption method and keys of a backup from
+// the flags, falling back to backups.encryption in the config file.
+func (o *backupOptions) resolveEncryption() error {
+	if o.Encryption == "" {
+		o.Encryption = settingString("backups.encryption.method")
+	}
+	o.Encryption = strings.ToLower(o.Encryption)
+	if o.Encryption == "" {
+		o.Encryption = encryptNone
+	}
+	if !slices.Contains(encryptionMethods, o.Encryption) {
+		return usageError("unknown encryption '%s' (valid: %s)", o.Encryption, strings.Join(encryptionMethods, ", "))
+	}
+	if o.Encryption == encryptNone {
+		return nil
+	}
+	if !o.streamed() {
+		return usageError("directory archives cannot be encrypted, use --format custom or tar")
+	}
+
+	keyFile := o.KeyFile
+	if keyFile == "" {
+		keyFile = settingString("backups.encryption.key_file")
+	}
+
+	var err error
+	if o.Encryption == encryptAge {
+		o.recipients, err = ageRecipients(o.Recipients, keyFile)
+	} else {
+		o.passphrase, err = readPassphrase(keyFile)
+	}
+	return err
+}
+
+// ageRecipients parses the recipients given on the command line or in the
+// config file, plus those of keyFile. keyFile may be a recipients file or an
+// identity file, whose public keys are then used.
+func ageRecipients(keys []string, keyFile string) ([]age.Recipient, error) {
+	if len(keys) == 0 {
+		keys = settingStringSlice("backups.encryption.recipients")
+	}
+
+	var recipients []age.Recipient
+	for _, key := range keys {
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, usageError("invalid age recipient '%s': %w", key, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, wrapError(err, "failed to read key file '%s'", keyFile)
+		}
+		if bytes.Contains(data, []byte("AGE-SECRET-KEY-")) {
+			identities, err := age.ParseIdentities(bytes.NewReader(data))
+			if err != nil {
+				return nil, usageError("invalid age identity file '%s': %w", keyFile, err)
+			}
+			for _, identity := range identities {
+				if x, ok := identity.(*age.X25519Identity); ok {
+					recipients = append(recipients, x.Recipient())
+				}
+			}
+		} else {
+			parsed, err := age.ParseRecipients(bytes.NewReader(data))
+			if err != nil {
+				return nil, usageError("invalid age recipients file '%s': %w", keyFile, err)
+			}
+			recipients = append(recipients, parsed...)
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil, usageError("age encryption needs --recipient, --key-file or backups.encryption.recipients")
+	}
+	return recipients, nil
+}
+
+// readPassphrase reads the passphrase from keyFile, or from
+// backups.encryption.passphrase when there is no key file.
+func readPassphrase(keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		if passphrase := settingString("backups.encryption.passphrase"); passphrase != "" {
+			return []byte(passphrase), nil
+		}
+		return nil, usageError("passphrase encryption needs --key-file, backups.encryption.key_file or backups.encryption.passphrase")
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, wrapError(err, "failed to read key file '%s'", keyFile)
+	}
+	passphrase := bytes.TrimRight(data, "\r\n")
+	if len(passphrase) == 0 {
+		return nil, usageError("key file '%s' is empty", keyFile)
+	}
+	return passphrase, nil
+}
+
+func encryptionExtension(method string) string {
+	switch method {
+	case encryptAge:
+		return ".age"
+	case encryptPassphrase:
+		return ".enc"
+	}
+	return ""
+}
+
+// encryptWriter returns a writer that encrypts into w as opts asks. Closing
+// it flushes the last chunk but does not close w.
+func encryptWriter(w io.Writer, opts backupOptions) (io.WriteCloser, error) {
+	switch opts.Encryption {
+	case encryptAge:
+		return age.Encrypt(w, opts.recipients...)
+	case encryptPassphrase:
+		return newPassphraseWriter(w, opts.passphrase)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// detectEncryption sniffs the first bytes of r and returns a reader of the
+// decrypted stream, resolving the key from keyFile or the config file.
+func detectEncryption(r *bufio.Reader, keyFile string) (string, *bufio.Reader, error) {
+	head, err := r.Peek(len(ageMagic))
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+
+	if keyFile == "" {
+		keyFile = settingString("backups.encryption.key_file")
+	}
+
+	switch {
+	case bytes.HasPrefix(head, ageMagic):
+		if keyFile == "" {
+			return "", nil, usageError("backup is encrypted with age, pass the identity file with --key-file or set backups.encryption.key_file")
+		}
+		identities, err := readIdentities(keyFile)
+		if err != nil {
+			return "", nil, err
+		}
+		decrypted, err := age.Decrypt(r, identities...)
+		if err != nil {
+			var noMatch *age.NoIdentityMatchError
+			if errors.As(err, &noMatch) {
+				return "", nil, newError(KindAuth, "no identity in '%s' can decrypt this backup", keyFile)
+			}
+			return "", nil, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+		return encryptAge, bufio.NewReader(decrypted), nil
+	case bytes.HasPrefix(head, passphraseMagic):
+		passphrase, err := readPassphrase(keyFile)
+		if err != nil {
+			return "", nil, err
+		}
+		decrypted, err := newPassphraseReader(r, passphrase)
+		if err != nil {
+			return "", nil, err
+		}
+		return encryptPassphrase, bufio.NewReader(decrypted), nil
+	}
+	return encryptNone, r, nil
+}
+
+func readIdentities(keyFile string) ([]age.Identity, error) {
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return nil, wrapError(err, "failed to read key file '%s'", keyFile)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, usageError("invalid age identity file '%s': %w", keyFile, err)
+	}
+	return identities, nil
+}
+
+// passphraseKey derives the AES-256 key from a passphrase with scrypt.
+func passphraseKey(passphrase, salt []byte, logN int) ([]byte, error) {
+	if logN < 10 || logN > 22 {
+		return nil, fmt.Errorf("unsupported scrypt work factor %d", logN)
+	}
+	return scrypt.Key(passphrase, salt, 1<<logN, 8, 1, 32)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce mixes the chunk counter into the last bytes of the base nonce.
+func chunkNonce(base []byte, counter uint64) []byte {
+	nonce := slices.Clone(base)
+	tail := nonce[len(nonce)-8:]
+	binary.BigEndian.PutUint64(tail, binary.BigEndian.Uint64(tail)^counter)
+	return nonce
+}
+
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type passphraseWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	err     error
+}
+
+// newPassphraseWriter writes the header to w and returns a writer sealing
+// everything written to it in chunks.
+func newPassphraseWriter(w io.Writer, passphrase []byte) (*passphraseWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := passphraseKey(passphrase, salt, scryptLogN)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := slices.Concat(passphraseMagic, []byte{passphraseVersion, scryptLogN}, salt, nonce)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &passphraseWriter{w: w, aead: aead, nonce: nonce, buf: make([]byte, 0, gcmChunkSize)}, nil
+}
+
+func (pw *passphraseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if pw.err != nil {
+			return written, pw.err
+		}
+		// A full chunk is only sealed once more data follows, so Close can
+		// still mark it as the last one.
+		if len(pw.buf) == gcmChunkSize {
+			pw.seal(false)
+			continue
+		}
+		n := copy(pw.buf[len(pw.buf):gcmChunkSize], p)
+		pw.buf = pw.buf[:len(pw.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, pw.err
+}
+
+func (pw *passphraseWriter) seal(last bool) {
+	sealed := pw.aead.Seal(nil, chunkNonce(pw.nonce, pw.counter), pw.buf, chunkAdditionalData(last))
+	pw.counter++
+	pw.buf = pw.buf[:0]
+	_, pw.err = pw.w.Write(sealed)
+}
+
+func (pw *passphraseWriter) Close() error {
+	if pw.err != nil {
+		return pw.err
+	}
+	pw.seal(true)
+	return pw.err
+}
+
+type passphraseReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	chunk   []byte
+	plain   []byte
+	done    bool
+}
+
+// newPassphraseReader reads the header from r and returns a reader of the
+// decrypted stream.
+func newPassphraseReader(r *bufio.Reader, passphrase []byte) (*passphraseReader, error) {
+	header := make([]byte, len(passphraseMagic)+2+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("truncated encryption header: %w", err)
+	}
+	version, logN := header[len(passphraseMagic)], header[len(passphraseMagic)+1]
+	if version != passphraseVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", version)
+	}
+	salt := header[len(passphraseMagic)+2:]
+
+	key, err := passphraseKey(passphrase, salt, int(logN))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, fmt.Errorf("truncated encryption header: %w", err)
+	}
+	return &passphraseReader{
+		r:     r,
+		aead:  aead,
+		nonce: nonce,
+		chunk: make([]byte, gcmChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (pr *passphraseReader) Read(p []byte) (int, error) {
+	for len(pr.plain) == 0 {
+		if pr.done {
+			return 0, io.EOF
+		}
+		if err := pr.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, pr.plain)
+	pr.plain = pr.plain[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk. A short chunk, or a full one at
+// the end of the stream, must be the last.
+func (pr *passphraseReader) open() error {
+	n, err := io.ReadFull(pr.r, pr.chunk)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		return errors.New("encrypted backup is truncated")
+	case err != nil:
+		return err
+	default:
+		if _, err := pr.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := pr.aead.Open(pr.chunk[:0], chunkNonce(pr.nonce, pr.counter), pr.chunk[:n], chunkAdditionalData(last))
+	if err != nil {
+		if pr.counter == 0 {
+			return newError(KindAuth, "failed to decrypt backup: wrong passphrase or corrupted data")
+		}
+		return errors.New("failed to decrypt backup: corrupted or truncated data")
+	}
+	pr.counter++
+	pr.plain = plain
+	pr.done = last
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// passphraseHeaderSize is the magic, version, work factor, salt and nonce.
+const passphraseHeaderSize = 8 + 2 + saltSize + 12
+
+// sealedChunkSize is a full chunk with its GCM tag.
+const sealedChunkSize = gcmChunkSize + 16
+
+func sealPassphrase(t *testing.T, plain []byte, writeSize int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	pw, err := newPassphraseWriter(&out, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("newPassphraseWriter: %v", err)
+	}
+	for rest := plain; len(rest) > 0; {
+		n := min(writeSize, len(rest))
+		if _, err := pw.Write(rest[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return out.Bytes()
+}
+
+func openPassphrase(data []byte, passphrase string) ([]byte, error) {
+	pr, err := newPassphraseReader(bufio.NewReader(bytes.NewReader(data)), []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(pr)
+}
+
+func testPlaintext(size int) []byte {
+	plain := make([]byte, size)
+	for i := range plain {
+		plain[i] = byte(i*7 + i/251)
+	}
+	return plain
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		writeSize int
+		chunks    int
+	}{
+		{"empty", 0, 1, 1},
+		{"one byte", 1, 1, 1},
+		{"exactly one chunk", gcmChunkSize, gcmChunkSize, 1},
+		{"one chunk plus one byte", gcmChunkSize + 1, 4096, 2},
+		{"two chunks in odd writes", 2 * gcmChunkSize, 1000, 2},
+		{"several chunks in one write", 3*gcmChunkSize + 17, 1 << 20, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := testPlaintext(tt.size)
+			data := sealPassphrase(t, plain, tt.writeSize)
+
+			sealed := len(data) - passphraseHeaderSize
+			if want := tt.size + tt.chunks*16; sealed != want {
+				t.Errorf("sealed size = %d, want %d (%d chunks)", sealed, want, tt.chunks)
+			}
+			got, err := openPassphrase(data, "correct horse")
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes, want the %d bytes written", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestPassphraseRejectsDamage(t *testing.T) {
+	twoChunks := sealPassphrase(t, testPlaintext(gcmChunkSize+1), gcmChunkSize)
+	empty := sealPassphrase(t, nil, 1)
+
+	flip := func(data []byte, offset int) []byte {
+		damaged := bytes.Clone(data)
+		damaged[offset] ^= 0x01
+		return damaged
+	}
+	// Damage found in the first chunk reads as a wrong passphrase, since
+	// the two cannot be told apart there
+	tests := []struct {
+		name string
+		data []byte
+		auth bool
+	}{
+		{"last chunk missing", twoChunks[:passphraseHeaderSize+sealedChunkSize], true},
+		{"last chunk cut short", twoChunks[:len(twoChunks)-1], false},
+		{"first chunk cut short", twoChunks[:passphraseHeaderSize+100], true},
+		{"only chunk missing", empty[:passphraseHeaderSize], false},
+		{"header cut short", twoChunks[:passphraseHeaderSize-1], false},
+		{"tampered first chunk", flip(twoChunks, passphraseHeaderSize+10), true},
+		{"tampered last chunk", flip(twoChunks, len(twoChunks)-3), false},
+		{"tampered nonce", flip(twoChunks, passphraseHeaderSize-1), true},
+		{"trailing garbage", append(bytes.Clone(twoChunks), 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openPassphrase(tt.data, "correct horse")
+			if err == nil {
+				t.Fatal("decrypt succeeded, want an error")
+			}
+			var cmdErr *CommandError
+			if auth := errors.As(err, &cmdErr) && cmdErr.Kind == KindAuth; auth != tt.auth {
+				t.Errorf("error %q: auth = %v, want %v", err, auth, tt.auth)
+			}
+		})
+	}
+}
+
+func TestPassphraseWrongPassphrase(t *testing.T) {
+	data := sealPassphrase(t, []byte("SELECT 1;\n"), 64)
+	_, err := openPassphrase(data, "wrong horse")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Kind != KindAuth {
+		t.Fatalf("error = %v, want an auth error", err)
+	}
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// manifestName is the catalog file kept next to the backups it describes.
+const manifestName = "manifest.json"
+
+// backupEntry describes one backup in a manifest. Name is relative to the
+// backup location.
+type backupEntry struct {
+	Database    string    `json:"database"`
+	Name        string    `json:"name"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression"`
+	Encryption  string    `json:"encryption,omitempty"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Checksum    string    `json:"checksum"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type backupManifest struct {
+	Version int           `json:"version"`
+	Backups []backupEntry `json:"backups"`
+}
+
+// add records entry, replacing an older entry with the same name.
+func (m *backupManifest) add(entry backupEntry) {
+	m.remove(entry.Name)
+	m.Backups = append(m.Backups, entry)
+	sort.SliceStable(m.Backups, func(i, j int) bool {
+		return m.Backups[i].CreatedAt.Before(m.Backups[j].CreatedAt)
+	})
+}
+
+func (m *backupManifest) remove(name string) {
+	kept := m.Backups[:0]
+	for _, entry := range m.Backups {
+		if entry.Name != name {
+			kept = append(kept, entry)
+		}
+	}
+	m.Backups = kept
+}
+
+// backupStore is a place backups and their manifest live in: a local
+// directory or a MinIO prefix.
+type backupStore interface {
+	location() string
+	readManifest(ctx context.Context) (*backupManifest, error)
+	writeManifest(ctx context.Context, manifest *backupManifest) error
+	size(ctx context.Context, name string) (int64, error)
+	checksum(ctx context.Context, name string) (string, error)
+	remove(ctx context.Context, name string) error
+}
+
+// openBackupStore returns the store for a directory path or an
+// s3://bucket/prefix/ location.
+func openBackupStore(location string) (backupStore, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return &localStore{dir: location}, nil
+	}
+
+	bucket, prefix, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client, err := getMinIOClient()
+	if err != nil {
+		return nil, connectionError("failed to connect to MinIO: %w", err)
+	}
+	return &s3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// recordBackup adds entry to the manifest of store.
+func recordBackup(ctx context.Context, store backupStore, entry backupEntry) error {
+	manifest, err := store.readManifest(ctx)
+	if err != nil {
+		return err
+	}
+	manifest.add(entry)
+	return store.writeManifest(ctx, manifest)
+}
+
+// checkEntryName rejects manifest names that would reach outside the backup
+// location, so a tampered manifest cannot make prune delete anything else.
+func checkEntryName(name string) error {
+	if name == "" || name == "." || name == ".." || name == manifestName ||
+		strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid backup name '%s' in manifest", name)
+	}
+	return nil
+}
+
+func newChecksum() hash.Hash {
+	return sha256.New()
+}
+
+func formatChecksum(h hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func decodeManifest(data []byte) (*backupManifest, error) {
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func encodeManifest(manifest *backupManifest) ([]byte, error) {
+	manifest.Version = 1
+	if manifest.Backups == nil {
+		manifest.Backups = []backupEntry{}
+	}
+	return json.MarshalIndent(manifest, "", "  ")
+}
+
+type localStore struct {
+	dir string
+}
+
+func (s *localStore) location() string {
+	return s.dir
+}
+
+func (s *localStore) readManifest(ctx context.Context) (*backupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &backupManifest{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeManifest(data)
+}
+
+// writeManifest replaces the manifest through a rename so readers never see
+// a half-written file.
+func (s *localStore) writeManifest(ctx context.Context, manifest *backupManifest) error {
+	data, err := encodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(s.dir, manifestName+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(s.dir, manifestName))
+}
+
+func (s *localStore) size(ctx context.Context, name string) (int64, error) {
+	if _, err := os.Stat(filepath.Join(s.dir, name)); err != nil {
+		return 0, err
+	}
+	return pathSize(filepath.Join(s.dir, name))
+}
+
+func (s *localStore) checksum(ctx context.Context, name string) (string, error) {
+	return fileChecksum(filepath.Join(s.dir, name))
+}
+
+func (s *localStore) remove(ctx context.Context, name string) error {
+	if err := checkEntryName(name); err != nil {
+		return err
+	}
+	// Directory archives are removed as a whole
+	return os.RemoveAll(filepath.Join(s.dir, name))
+}
+
+// fileChecksum hashes a backup file, or the relative paths and contents of
+// every file of a directory archive in lexical order.
+func fileChecksum(target string) (string, error) {
+	h := newChecksum()
+	err := filepath.WalkDir(target, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		if p != target {
+			rel, _ := filepath.Rel(target, p)
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(h, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return formatChecksum(h), nil
+}
+
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func (s *s3Store) location() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.prefix)
+}
+
+func (s *s3Store) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+func (s *s3Store) readManifest(ctx context.Context) (*backupManifest, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.key(manifestName), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return &backupManifest{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeManifest(data)
+}
+
+func (s *s3Store) writeManifest(ctx context.Context, manifest *backupManifest) error {
+	data, err := encodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, s.key(manifestName), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+func (s *s3Store) size(ctx context.Context, name string) (int64, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, s.key(name), minio.StatObjectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+func (s *s3Store) checksum(ctx context.Context, name string) (string, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+
+	h := newChecksum()
+	if _, err := io.Copy(h, object); err != nil {
+		return "", err
+	}
+	return formatChecksum(h), nil
+}
+
+func (s *s3Store) remove(ctx context.Context, name string) error {
+	if err := checkEntryName(name); err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, s.key(name), minio.RemoveObjectOptions{})
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
@@ -17,6 +18,9 @@ func InitConfig() {
 	// Also try loading from current directory
 	godotenv.Load(".env")
 
+	// Load named connection profiles from the tools config file
+	loadProfiles()
+
 	// PostgreSQL environment variables
 	viper.SetDefault("postgres.host", "localhost")
 	viper.SetDefault("postgres.port", "5432")
@@ -98,3 +102,49 @@ func InitConfig() {
 		viper.Set("minio.secret_key", minioSecret)
 	}
 }
+
+// RegisterGlobalFlags adds the flags shared by every command to the root
+// command. It must be called after all subcommands have been added.
+func RegisterGlobalFlags(root *cobra.Command) {
+	root.PersistentFlags().String("profile", "", "Connection profile from the config file (env: TOOLS_PROFILE)")
+	viper.BindPFlag("profile", root.PersistentFlags().Lookup("profile"))
+	root.PersistentFlags().StringP("output", "o", outputText, "Output format: text, json, yaml, table, csv (env: TOOLS_OUTPUT)")
+	viper.BindPFlag("output", root.PersistentFlags().Lookup("output"))
+
+	// main reports errors returned from Execute itself
+	root.SilenceErrors = true
+
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError("%v", err)
+	})
+	wrapArgsValidation(root)
+
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// Arguments are valid at this point, so failures from here on are
+		// not usage mistakes and shouldn't print the usage text.
+		cmd.SilenceUsage = true
+
+		if name := viper.GetString("profile"); name != "" && !profileExists(name) {
+			return notFoundError("profile '%s' not found in config file", name)
+		}
+		if err := validateOutputFormat(); err != nil {
+			return usageError("%v", err)
+		}
+		return nil
+	}
+}
+
+// wrapArgsValidation tags argument validation errors as usage errors.
+func wrapArgsValidation(c *cobra.Command) {
+	if validate := c.Args; validate != nil {
+		c.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return usageError("%v", err)
+			}
+			return nil
+		}
+	}
+	for _, child := range c.Commands() {
+		wrapArgsValidation(child)
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var configCmd = &cobra.Command{
@@ -19,40 +18,61 @@ var configCmd = &cobra.Command{
 var configAllCmd = &cobra.Command{
 	Use:   "all",
 	Short: "Show all configurations",
-	Run: func(cmd *cobra.Command, args []string) {
-		showAllConfig()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showAllConfig()
 	},
 }
 
 var configPostgresCmd = &cobra.Command{
 	Use:   "postgres",
 	Short: "Show PostgreSQL configuration",
-	Run: func(cmd *cobra.Command, args []string) {
-		showPostgresConfig()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showPostgresConfig()
 	},
 }
 
 var configRabbitCmd = &cobra.Command{
 	Use:   "rabbitmq",
 	Short: "Show RabbitMQ configuration",
-	Run: func(cmd *cobra.Command, args []string) {
-		showRabbitConfig()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showRabbitConfig()
 	},
 }
 
 var configMinioCmd = &cobra.Command{
 	Use:   "minio",
 	Short: "Show MinIO configuration",
-	Run: func(cmd *cobra.Command, args []string) {
-		showMinioConfig()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showMinioConfig()
+	},
+}
+
+var configProfilesCmd = &cobra.Command{
+	Use:     "profiles",
+	Aliases: []string{"list"},
+	Short:   "List connection profiles from the config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listProfiles()
+	},
+}
+
+var configUseCmd = &cobra.Command{
+	Use:   "use [profile-name]",
+	Short: "Set the default connection profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := useProfile(args[0]); err != nil {
+			return wrapError(err, "failed to switch profile")
+		}
+		return reportAction("use", args[0], "active", "✓ Now using profile '%s'", args[0])
 	},
 }
 
 var configEnvCmd = &cobra.Command{
 	Use:   "env",
 	Short: "Show environment variables",
-	Run: func(cmd *cobra.Command, args []string) {
-		showEnvironmentVariables()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showEnvironmentVariables()
 	},
 }
 
@@ -63,9 +83,84 @@ func init() {
 	configCmd.AddCommand(configRabbitCmd)
 	configCmd.AddCommand(configMinioCmd)
 	configCmd.AddCommand(configEnvCmd)
+	configCmd.AddCommand(configProfilesCmd)
+	configCmd.AddCommand(configUseCmd)
+}
+
+type postgresConfigRecord struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+}
+
+type rabbitConfigRecord struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	VHost    string `json:"vhost"`
+}
+
+type minioConfigRecord struct {
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	UseSSL    bool   `json:"use_ssl"`
+}
+
+type allConfigRecord struct {
+	Profile  string               `json:"profile"`
+	Postgres postgresConfigRecord `json:"postgres"`
+	RabbitMQ rabbitConfigRecord   `json:"rabbitmq"`
+	MinIO    minioConfigRecord    `json:"minio"`
+}
+
+type profileRecord struct {
+	Name     string `json:"name"`
+	Active   bool   `json:"active"`
+	Postgres string `json:"postgres"`
+	RabbitMQ string `json:"rabbitmq"`
+	MinIO    string `json:"minio"`
+}
+
+// Passwords and secret keys are always masked in structured output.
+func postgresConfig() postgresConfigRecord {
+	return postgresConfigRecord{
+		Host:     configString("postgres.host"),
+		Port:     configString("postgres.port"),
+		User:     configString("postgres.user"),
+		Password: maskPassword(configString("postgres.password")),
+		Database: configString("postgres.database"),
+	}
+}
+
+func rabbitConfig() rabbitConfigRecord {
+	return rabbitConfigRecord{
+		Host:     configString("rabbitmq.host"),
+		Port:     configString("rabbitmq.port"),
+		User:     configString("rabbitmq.user"),
+		Password: maskPassword(configString("rabbitmq.password")),
+		VHost:    configString("rabbitmq.vhost"),
+	}
+}
+
+func minioConfig() minioConfigRecord {
+	return minioConfigRecord{
+		Endpoint:  configString("minio.endpoint"),
+		AccessKey: configString("minio.access_key"),
+		SecretKey: maskPassword(configString("minio.secret_key")),
+		UseSSL:    configBool("minio.use_ssl"),
+	}
 }
 
 func getConfigSource(key string, envVars ...string) string {
+	// Check if value comes from the active profile
+	if _, ok := profileKey(key); ok {
+		return fmt.Sprintf("(from profile %s)", activeProfile())
+	}
+
 	// Check if value comes from environment variable
 	for _, env := range envVars {
 		if os.Getenv(env) != "" {
@@ -92,15 +187,19 @@ func maskPassword(password string) string {
 	return password[:2] + strings.Repeat("*", len(password)-4) + password[len(password)-2:]
 }
 
-func showPostgresConfig() {
+func showPostgresConfig() error {
+	if ok, err := renderOutput(postgresConfig()); ok {
+		return err
+	}
+
 	color.Green("=== PostgreSQL Configuration ===")
 	fmt.Println()
 
-	host := viper.GetString("postgres.host")
-	port := viper.GetString("postgres.port")
-	user := viper.GetString("postgres.user")
-	password := viper.GetString("postgres.password")
-	database := viper.GetString("postgres.database")
+	host := configString("postgres.host")
+	port := configString("postgres.port")
+	user := configString("postgres.user")
+	password := configString("postgres.password")
+	database := configString("postgres.database")
 
 	fmt.Printf("Host:     %s %s\n", host, getConfigSource("postgres.host", "PGHOST"))
 	fmt.Printf("Port:     %s %s\n", port, getConfigSource("postgres.port", "PGPORT"))
@@ -120,17 +219,22 @@ func showPostgresConfig() {
 	// Test connection command
 	color.Yellow("\nTest Connection:")
 	fmt.Printf("psql -h %s -p %s -U %s -d %s -c \"SELECT version();\"\n", host, port, user, database)
+	return nil
 }
 
-func showRabbitConfig() {
+func showRabbitConfig() error {
+	if ok, err := renderOutput(rabbitConfig()); ok {
+		return err
+	}
+
 	color.Green("=== RabbitMQ Configuration ===")
 	fmt.Println()
 
-	host := viper.GetString("rabbitmq.host")
-	port := viper.GetString("rabbitmq.port")
-	user := viper.GetString("rabbitmq.user")
-	password := viper.GetString("rabbitmq.password")
-	vhost := viper.GetString("rabbitmq.vhost")
+	host := configString("rabbitmq.host")
+	port := configString("rabbitmq.port")
+	user := configString("rabbitmq.user")
+	password := configString("rabbitmq.password")
+	vhost := configString("rabbitmq.vhost")
 
 	fmt.Printf("Host:            %s %s\n", host, getConfigSource("rabbitmq.host", "RABBITMQ_HOST"))
 	fmt.Printf("Management Port: %s %s\n", port, getConfigSource("rabbitmq.port", "RABBITMQ_MANAGEMENT_PORT"))
@@ -156,16 +260,21 @@ func showRabbitConfig() {
 	// Test connection
 	color.Yellow("\nTest Connection:")
 	fmt.Printf("curl -u %s:%s http://%s:%s/api/overview\n", user, maskPassword(password), host, port)
+	return nil
 }
 
-func showMinioConfig() {
+func showMinioConfig() error {
+	if ok, err := renderOutput(minioConfig()); ok {
+		return err
+	}
+
 	color.Green("=== MinIO Configuration ===")
 	fmt.Println()
 
-	endpoint := viper.GetString("minio.endpoint")
-	accessKey := viper.GetString("minio.access_key")
-	secretKey := viper.GetString("minio.secret_key")
-	useSSL := viper.GetBool("minio.use_ssl")
+	endpoint := configString("minio.endpoint")
+	accessKey := configString("minio.access_key")
+	secretKey := configString("minio.secret_key")
+	useSSL := configBool("minio.use_ssl")
 
 	fmt.Printf("Endpoint:    %s %s\n", endpoint, getConfigSource("minio.endpoint", "MINIO_ENDPOINT"))
 	fmt.Printf("Access Key:  %s %s\n", accessKey, getConfigSource("minio.access_key", "MINIO_ACCESS_KEY", "MINIO_ROOT_USER"))
@@ -198,20 +307,49 @@ func showMinioConfig() {
 	color.Yellow("\nTest Connection (using mc):")
 	fmt.Printf("mc alias set myminio %s://%s %s %s\n", protocol, endpoint, accessKey, maskPassword(secretKey))
 	fmt.Printf("mc ls myminio\n")
+	return nil
 }
 
-func showAllConfig() {
+func showAllConfig() error {
+	all := allConfigRecord{
+		Profile:  activeProfile(),
+		Postgres: postgresConfig(),
+		RabbitMQ: rabbitConfig(),
+		MinIO:    minioConfig(),
+	}
+	if ok, err := renderOutput(all); ok {
+		return err
+	}
+
+	if profile := activeProfile(); profile != "" {
+		color.Cyan("Active profile: %s", profile)
+		fmt.Println()
+	}
 	showPostgresConfig()
 	fmt.Println()
 	showRabbitConfig()
 	fmt.Println()
 	showMinioConfig()
+	return nil
 }
 
-func showEnvironmentVariables() {
-	color.Green("=== Environment Variables ===")
-	fmt.Println()
+type envVarRecord struct {
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
 
+// maskEnvValue masks passwords and secrets
+func maskEnvValue(env, value string) string {
+	if strings.Contains(strings.ToLower(env), "password") ||
+		strings.Contains(strings.ToLower(env), "secret") ||
+		strings.Contains(strings.ToLower(env), "pass") {
+		return maskPassword(value)
+	}
+	return value
+}
+
+func showEnvironmentVariables() error {
 	envVars := []struct {
 		category string
 		vars     []string
@@ -239,18 +377,29 @@ func showEnvironmentVariables() {
 		},
 	}
 
+	if outputFormat() != outputText {
+		records := []envVarRecord{}
+		for _, group := range envVars {
+			for _, env := range group.vars {
+				if value := os.Getenv(env); value != "" {
+					records = append(records, envVarRecord{Service: group.category, Name: env, Value: maskEnvValue(env, value)})
+				}
+			}
+		}
+		_, err := renderOutput(records)
+		return err
+	}
+
+	color.Green("=== Environment Variables ===")
+	fmt.Println()
+
 	for _, group := range envVars {
 		color.Yellow("%s:\n", group.category)
 		hasValue := false
 		for _, env := range group.vars {
 			value := os.Getenv(env)
 			if value != "" {
-				// Mask passwords
-				if strings.Contains(strings.ToLower(env), "password") ||
-					strings.Contains(strings.ToLower(env), "secret") ||
-					strings.Contains(strings.ToLower(env), "pass") {
-					value = maskPassword(value)
-				}
+				value = maskEnvValue(env, value)
 				fmt.Printf("  %s = %s\n", env, value)
 				hasValue = true
 			}
@@ -275,6 +424,68 @@ func showEnvironmentVariables() {
 			fmt.Printf("  Found at: %s\n", envPath)
 		}
 	}
+	return nil
+}
+
+func listProfiles() error {
+	active := activeProfile()
+	profiles := []profileRecord{}
+	for _, name := range profileNames() {
+		prefix := "profiles." + name + "."
+		profile := profileRecord{Name: name, Active: name == active}
+		if profileStore.IsSet(prefix + "postgres") {
+			profile.Postgres = fmt.Sprintf("%s@%s:%s",
+				profileStore.GetString(prefix+"postgres.user"),
+				profileStore.GetString(prefix+"postgres.host"),
+				profileStore.GetString(prefix+"postgres.port"))
+		}
+		if profileStore.IsSet(prefix + "rabbitmq") {
+			profile.RabbitMQ = fmt.Sprintf("%s@%s:%s",
+				profileStore.GetString(prefix+"rabbitmq.user"),
+				profileStore.GetString(prefix+"rabbitmq.host"),
+				profileStore.GetString(prefix+"rabbitmq.port"))
+		}
+		if profileStore.IsSet(prefix + "minio") {
+			profile.MinIO = profileStore.GetString(prefix + "minio.endpoint")
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if ok, err := renderOutput(profiles); ok {
+		return err
+	}
+
+	color.Green("=== Connection Profiles ===")
+	fmt.Println()
+
+	configFile := profileStore.ConfigFileUsed()
+	if configFile == "" || len(profiles) == 0 {
+		fmt.Println("No profiles defined")
+		fmt.Println("Create tools.yaml (or tools.toml) in the current directory, next to the binary,")
+		fmt.Println("or in your user config directory under tools/. See tools.example.yaml.")
+		return nil
+	}
+
+	fmt.Printf("Config file: %s\n\n", configFile)
+
+	for _, profile := range profiles {
+		marker := "  "
+		if profile.Active {
+			marker = "* "
+		}
+
+		fmt.Printf("%s%s\n", marker, profile.Name)
+		if profile.Postgres != "" {
+			fmt.Printf("    postgres: %s\n", profile.Postgres)
+		}
+		if profile.RabbitMQ != "" {
+			fmt.Printf("    rabbitmq: %s\n", profile.RabbitMQ)
+		}
+		if profile.MinIO != "" {
+			fmt.Printf("    minio:    %s\n", profile.MinIO)
+		}
+	}
+	return nil
 }
 
 func GetConfigCommand() *cobra.Command {
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled database backups in the foreground",
+	Long: `Run the backup schedules from the config file until interrupted.
+
+Each job takes a backup, applies its retention and appends the outcome to
+the run log. A job is skipped while its previous run is still going, also
+when that run belongs to another tools process. Use --run to run jobs once
+right away instead, e.g. from cron or Task Scheduler.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		run, _ := cmd.Flags().GetStringSlice("run")
+		if len(run) > 0 {
+			return runJobsOnce(run)
+		}
+		return runDaemon()
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringSlice("run", nil, "Run the named jobs once and exit")
+}
+
+// daemonLog prints a timestamped line, colored by outcome.
+func daemonLog(c *color.Color, format string, args ...any) {
+	c.Printf("%s %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// executeJob runs job, logs its outcome and appends it to the run log.
+func executeJob(dir string, job backupJob) runRecord {
+	daemonLog(color.New(color.FgCyan), "starting job '%s' (database '%s')", job.Name, job.Database)
+	record := runBackupJob(dir, job)
+
+	switch record.Status {
+	case runSuccess:
+		daemonLog(color.New(color.FgGreen), "job '%s' succeeded in %s", job.Name, record.FinishedAt.Sub(record.StartedAt).Round(time.Second))
+	case runSkipped:
+		daemonLog(color.New(color.FgYellow), "job '%s' skipped: %s", job.Name, record.Error)
+	default:
+		daemonLog(color.New(color.FgRed), "job '%s' failed: %s", job.Name, record.Error)
+	}
+
+	if err := appendRunLog(runLogPath(dir), record); err != nil {
+		printError("failed to write run log: %v", err)
+	}
+	return record
+}
+
+func runDaemon() error {
+	jobs, err := loadBackupJobs()
+	if err != nil {
+		return err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return wrapError(err, "failed to open state directory")
+	}
+
+	// SkipIfStillRunning keeps runs of one job from overlapping inside this
+	// process, the job lock does the same across processes.
+	scheduler := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	for _, job := range jobs {
+		scheduler.Schedule(job.Schedule, cron.FuncJob(func() {
+			executeJob(dir, job)
+		}))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	color.Green("Backup daemon started with %d jobs, run log: %s", len(jobs), runLogPath(dir))
+	for _, job := range jobs {
+		fmt.Printf("  %-20s %-15s next run %s\n", job.Name, job.Spec, job.Schedule.Next(time.Now()).Format("2006-01-02 15:04"))
+	}
+
+	scheduler.Start()
+	<-ctx.Done()
+
+	color.Yellow("Stopping, waiting for running jobs to finish...")
+	<-scheduler.Stop().Done()
+	return nil
+}
+
+// runJobsOnce runs the named jobs one after another and fails when any of
+// them did not succeed.
+func runJobsOnce(names []string) error {
+	jobs, err := loadBackupJobs()
+	if err != nil {
+		return err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return wrapError(err, "failed to open state directory")
+	}
+
+	byName := make(map[string]backupJob, len(jobs))
+	for _, job := range jobs {
+		byName[job.Name] = job
+	}
+
+	failed := 0
+	for _, name := range names {
+		job, ok := byName[name]
+		if !ok {
+			return notFoundError("job '%s' not found in schedules", name)
+		}
+		if record := executeJob(dir, job); record.Status != runSuccess {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return newError(KindGeneral, "%d of %d jobs did not succeed", failed, len(names))
+	}
+	return nil
+}
+
+func GetDaemonCommand() *cobra.Command {
+	return daemonCmd
+}
@@ -1,14 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var dbCmd = &cobra.Command{
@@ -20,8 +22,8 @@ var dbCmd = &cobra.Command{
 var dbListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all PostgreSQL databases",
-	Run: func(cmd *cobra.Command, args []string) {
-		listDatabases()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listDatabases()
 	},
 }
 
@@ -29,8 +31,8 @@ var dbCreateCmd = &cobra.Command{
 	Use:   "create [database-name]",
 	Short: "Create a new PostgreSQL database",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		createDatabase(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return createDatabase(args[0])
 	},
 }
 
@@ -38,35 +40,114 @@ var dbDropCmd = &cobra.Command{
 	Use:   "drop [database-name]",
 	Short: "Drop a PostgreSQL database",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		dropDatabase(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return dropDatabase(args[0])
 	},
 }
 
 var dbBackupCmd = &cobra.Command{
 	Use:   "backup [database-name] [output-file]",
 	Short: "Backup a PostgreSQL database",
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		backupDatabase(args[0], args[1])
+	Long: `Backup a PostgreSQL database with pg_dump.
+
+Plain and tar backups are compressed while streaming, custom and directory
+archives use pg_dump's own compression. The extension matching the format
+and compression is added to output-file when missing.
+
+With --to s3://bucket/prefix/ the dump is streamed into MinIO with a
+multipart upload instead of being written to disk. output-file then names
+the object under the prefix and defaults to <database>_<timestamp>.
+
+With --encrypt the backup is encrypted while streaming, for age recipients
+or with an AES-256-GCM key derived from a passphrase. Keys default to
+backups.encryption in the config file.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		compression, _ := cmd.Flags().GetString("compress")
+		jobs, _ := cmd.Flags().GetInt("jobs")
+		target, _ := cmd.Flags().GetString("to")
+		opts := backupOptions{Format: format, Compression: compression, Jobs: jobs}
+		opts.Encryption, _ = cmd.Flags().GetString("encrypt")
+		opts.Recipients, _ = cmd.Flags().GetStringSlice("recipient")
+		opts.KeyFile, _ = cmd.Flags().GetString("key-file")
+
+		if target != "" {
+			name := ""
+			if len(args) == 2 {
+				name = args[1]
+			}
+			return backupToS3(args[0], target, name, opts)
+		}
+		if len(args) < 2 {
+			return usageError("output-file is required unless --to is given")
+		}
+		return backupDatabase(args[0], args[1], opts)
 	},
 }
 
 var dbRestoreCmd = &cobra.Command{
 	Use:   "restore [database-name] [backup-file]",
 	Short: "Restore a PostgreSQL database from backup",
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		restoreDatabase(args[0], args[1])
+	Long: `Restore a PostgreSQL database from a backup made with db backup or pg_dump.
+
+The format (plain, custom, directory, tar) and compression (gzip, zstd) are
+detected from the backup itself, as is encryption; encrypted backups need
+the age identity or passphrase from --key-file or backups.encryption in the
+config file. Plain SQL goes through psql, archives through pg_restore. With --from s3://bucket/key the backup is streamed
+from MinIO without a temporary file.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts restoreOptions
+		opts.Clean, _ = cmd.Flags().GetBool("clean")
+		opts.IfExists, _ = cmd.Flags().GetBool("if-exists")
+		opts.SingleTransaction, _ = cmd.Flags().GetBool("single-transaction")
+		opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+		opts.KeyFile, _ = cmd.Flags().GetString("key-file")
+		source, _ := cmd.Flags().GetString("from")
+
+		switch {
+		case source != "" && len(args) == 2:
+			return usageError("give either backup-file or --from, not both")
+		case source != "":
+			return restoreFromS3(args[0], source, opts)
+		case len(args) < 2:
+			return usageError("backup-file is required unless --from is given")
+		}
+		return restoreDatabase(args[0], args[1], opts)
 	},
 }
 
 var dbExecCmd = &cobra.Command{
 	Use:   "exec [database-name] [sql-query]",
 	Short: "Execute SQL query on a PostgreSQL database",
-	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		executeQuery(args[0], args[1])
+	Long: `Execute a SQL query given as an argument, or a script with --file.
+
+A script runs statement by statement, printing progress and a summary of the
+rows each statement affected. --var name=value sets psql-style variables:
+:name is replaced by the value as is, :'name' by it as a string literal and
+:"name" as an identifier. --single-transaction wraps the script in one
+transaction that is rolled back if it stops on an error. --on-error continue
+runs the remaining statements after a failure (inside a single transaction
+each statement gets a savepoint); the command still exits non-zero.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if file == "" {
+			if len(args) != 2 {
+				return usageError("pass a SQL query or --file")
+			}
+			return executeQuery(args[0], args[1])
+		}
+		if len(args) != 1 {
+			return usageError("pass either a SQL query or --file, not both")
+		}
+
+		var opts scriptOptions
+		opts.Vars, _ = cmd.Flags().GetStringArray("var")
+		opts.SingleTransaction, _ = cmd.Flags().GetBool("single-transaction")
+		opts.OnError, _ = cmd.Flags().GetString("on-error")
+		return runScript(args[0], file, opts)
 	},
 }
 
@@ -74,12 +155,11 @@ var dbSizeCmd = &cobra.Command{
 	Use:   "size [database-name]",
 	Short: "Show PostgreSQL database size",
 	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			showAllDatabaseSizes()
-		} else {
-			showDatabaseSize(args[0])
+			return showAllDatabaseSizes()
 		}
+		return showDatabaseSize(args[0])
 	},
 }
 
@@ -87,8 +167,8 @@ var dbTablesCmd = &cobra.Command{
 	Use:   "tables [database-name]",
 	Short: "List all tables in a PostgreSQL database",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		listTables(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listTables(args[0])
 	},
 }
 
@@ -96,9 +176,9 @@ var dbTableCmd = &cobra.Command{
 	Use:   "table [database-name] [table-name]",
 	Short: "Show table structure and recent records",
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		limit, _ := cmd.Flags().GetInt("limit")
-		showTableDetails(args[0], args[1], limit)
+		return showTableDetails(args[0], args[1], limit)
 	},
 }
 
@@ -109,6 +189,27 @@ func init() {
 	dbCmd.PersistentFlags().StringP("password", "P", "", "Database password (env: PGPASSWORD)")
 	dbCmd.PersistentFlags().StringP("database", "d", "", "Default database (env: PGDATABASE)")
 
+	dbBackupCmd.Flags().StringP("format", "F", formatPlain, "Backup format: plain, custom, directory, tar")
+	dbBackupCmd.Flags().StringP("compress", "Z", "", "Compression: none, gzip, zstd (default gzip for custom and directory, none otherwise)")
+	dbBackupCmd.Flags().IntP("jobs", "j", 1, "Parallel dump jobs (directory format only)")
+	dbBackupCmd.Flags().String("to", "", "Stream the backup to MinIO, e.g. s3://backups/postgres/")
+	dbBackupCmd.Flags().String("encrypt", "", "Encryption: none, age, passphrase (default backups.encryption.method)")
+	dbBackupCmd.Flags().StringSlice("recipient", nil, "age recipient public key to encrypt to (repeatable)")
+	dbBackupCmd.Flags().String("key-file", "", "age recipients or identity file, or passphrase file")
+
+	dbRestoreCmd.Flags().Bool("clean", false, "Drop database objects before recreating them")
+	dbRestoreCmd.Flags().Bool("if-exists", false, "Use IF EXISTS when dropping objects (with --clean)")
+	dbRestoreCmd.Flags().BoolP("single-transaction", "1", false, "Restore as a single transaction")
+	dbRestoreCmd.Flags().IntP("jobs", "j", 1, "Parallel restore jobs (custom and directory archives)")
+	dbRestoreCmd.Flags().String("from", "", "Stream the backup from MinIO, e.g. s3://backups/postgres/mydb.dump")
+	dbRestoreCmd.Flags().String("key-file", "", "age identity file or passphrase file for encrypted backups")
+
+	// Script flags for exec
+	dbExecCmd.Flags().StringP("file", "f", "", "Run the statements of a SQL file (- for stdin)")
+	dbExecCmd.Flags().StringArrayP("var", "v", nil, "Set a script variable (name=value)")
+	dbExecCmd.Flags().Bool("single-transaction", false, "Run the script in one transaction")
+	dbExecCmd.Flags().String("on-error", onErrorStop, "On a failed statement: stop or continue")
+
 	// Add limit flag for table command
 	dbTableCmd.Flags().IntP("limit", "l", 5, "Number of records to show")
 
@@ -123,6 +224,69 @@ func init() {
 	dbCmd.AddCommand(dbTableCmd)
 }
 
+type databaseRecord struct {
+	Name      string `json:"name" db:"name"`
+	Owner     string `json:"owner" db:"owner"`
+	Encoding  string `json:"encoding" db:"encoding"`
+	Collate   string `json:"collate" db:"collate"`
+	Template  bool   `json:"template" db:"template"`
+	SizeBytes *int64 `json:"size_bytes" db:"size_bytes"`
+}
+
+type databaseSizeRecord struct {
+	Database  string `json:"database" db:"database"`
+	SizeBytes int64  `json:"size_bytes" db:"size_bytes"`
+	Size      string `json:"size" db:"size"`
+}
+
+type tableRecord struct {
+	Schema  string  `json:"schema" db:"schema"`
+	Table   string  `json:"table" db:"table"`
+	Size    string  `json:"size" db:"size"`
+	Comment *string `json:"comment" db:"comment"`
+}
+
+type columnRecord struct {
+	Column    string  `json:"column" db:"column"`
+	Type      string  `json:"type" db:"type"`
+	MaxLength *int    `json:"max_length" db:"max_length"`
+	Nullable  string  `json:"nullable" db:"nullable"`
+	Default   *string `json:"default" db:"default"`
+	// SQLType is the type as written in DDL, e.g. character varying(40)
+	SQLType string `json:"-" db:"sql_type"`
+	// Identity is ALWAYS or BY DEFAULT for identity columns
+	Identity *string `json:"identity,omitempty" db:"identity"`
+}
+
+type indexRecord struct {
+	Name       string `json:"name" db:"name"`
+	Definition string `json:"definition" db:"definition"`
+}
+
+type foreignKeyRecord struct {
+	Constraint       string `json:"constraint" db:"constraint"`
+	Column           string `json:"column" db:"column"`
+	ReferencesTable  string `json:"references_table" db:"references_table"`
+	ReferencesColumn string `json:"references_column" db:"references_column"`
+}
+
+type tableDetailRecord struct {
+	Database    string             `json:"database"`
+	Schema      string             `json:"schema"`
+	Table       string             `json:"table"`
+	Columns     []columnRecord     `json:"columns"`
+	Indexes     []indexRecord      `json:"indexes"`
+	ForeignKeys []foreignKeyRecord `json:"foreign_keys"`
+	RowCount    int64              `json:"row_count"`
+	Size        string             `json:"size"`
+	Records     *resultSet         `json:"records"`
+}
+
+// TableView renders only the column list in table and CSV output.
+func (t tableDetailRecord) TableView() any {
+	return t.Columns
+}
+
 func getPostgresConfig() (host, port, user, password, database string) {
 	host, _ = dbCmd.Flags().GetString("host")
 	port, _ = dbCmd.Flags().GetString("port")
@@ -131,67 +295,91 @@ func getPostgresConfig() (host, port, user, password, database string) {
 	database, _ = dbCmd.Flags().GetString("database")
 
 	if host == "" {
-		host = viper.GetString("postgres.host")
+		host = configString("postgres.host")
 	}
 	if port == "" {
-		port = viper.GetString("postgres.port")
+		port = configString("postgres.port")
 	}
 	if user == "" {
-		user = viper.GetString("postgres.user")
+		user = configString("postgres.user")
 	}
 	if password == "" {
-		password = viper.GetString("postgres.password")
+		password = configString("postgres.password")
 	}
 	if database == "" {
-		database = viper.GetString("postgres.database")
+		database = configString("postgres.database")
 	}
 
 	return
 }
 
-func listDatabases() {
-	host, port, user, password, _ := getPostgresConfig()
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-l")
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+// toolOutputError attaches the combined output of a failed client tool to
+// its error so the cause can be reported and classified.
+func toolOutputError(err error, output []byte) error {
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("%v: %s", err, message)
 	}
+	return err
+}
 
-	output, err := cmd.CombinedOutput()
+func listDatabases() error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
 	if err != nil {
-		color.Red("Error: %v", err)
-		color.Yellow("Make sure PostgreSQL client tools are installed and accessible.")
-		color.Yellow("Install with: apt-get install postgresql-client (Linux) or download from postgresql.org (Windows)")
-		return
+		return err
 	}
-	fmt.Println(string(output))
-}
+	defer conn.Close(ctx)
 
-func createDatabase(dbName string) {
-	host, port, user, password, _ := getPostgresConfig()
+	query := `
+		SELECT
+			datname AS name,
+			pg_get_userbyid(datdba) AS owner,
+			pg_encoding_to_char(encoding) AS encoding,
+			datcollate AS collate,
+			datistemplate AS template,
+			CASE WHEN has_database_privilege(datname, 'CONNECT')
+				THEN pg_database_size(datname) END AS size_bytes
+		FROM pg_database
+		ORDER BY datname`
+
+	databases, err := queryRecords[databaseRecord](ctx, conn, query)
+	if err != nil {
+		return wrapError(err, "failed to list databases")
+	}
+	if ok, err := renderOutput(databases); ok {
+		return err
+	}
 
-	cmd := exec.Command("createdb", "-h", host, "-p", port, "-U", user, dbName)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	color.Green("Databases:")
+	var rows [][]string
+	for _, db := range databases {
+		size := ""
+		if db.SizeBytes != nil {
+			size = formatBytes(*db.SizeBytes)
+		}
+		rows = append(rows, []string{db.Name, db.Owner, db.Encoding, db.Collate, size})
 	}
+	return writeTable(os.Stdout, []string{"name", "owner", "encoding", "collate", "size"}, rows)
+}
 
-	output, err := cmd.CombinedOutput()
+func createDatabase(dbName string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
 	if err != nil {
-		color.Red("Error creating database: %v\n%s", err, output)
-		return
+		return err
 	}
-	color.Green("✓ Database '%s' created successfully", dbName)
-}
+	defer conn.Close(ctx)
 
-func dropDatabase(dbName string) {
-	host, port, user, password, _ := getPostgresConfig()
+	if _, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{dbName}.Sanitize()); err != nil {
+		return wrapError(err, "failed to create database '%s'", dbName)
+	}
+	return reportAction("create", dbName, "created", "✓ Database '%s' created successfully", dbName)
+}
 
+func dropDatabase(dbName string) error {
 	// Safety check
 	if dbName == "postgres" || dbName == "template0" || dbName == "template1" {
-		color.Red("Cannot drop system database '%s'", dbName)
-		return
+		return usageError("cannot drop system database '%s'", dbName)
 	}
 
 	// Confirm deletion
@@ -200,158 +388,145 @@ func dropDatabase(dbName string) {
 	var confirm string
 	fmt.Scanln(&confirm)
 	if confirm != dbName {
-		color.Yellow("Deletion cancelled")
-		return
+		return cancelledError("deletion cancelled")
 	}
 
-	cmd := exec.Command("dropdb", "-h", host, "-p", port, "-U", user, dbName)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
-
-	output, err := cmd.CombinedOutput()
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
 	if err != nil {
-		color.Red("Error dropping database: %v\n%s", err, output)
-		return
-	}
-	color.Green("✓ Database '%s' dropped successfully", dbName)
-}
-
-func backupDatabase(dbName, outputFile string) {
-	host, port, user, password, _ := getPostgresConfig()
-
-	// Add .sql extension if not present
-	if len(outputFile) < 4 || outputFile[len(outputFile)-4:] != ".sql" {
-		outputFile += ".sql"
+		return err
 	}
+	defer conn.Close(ctx)
 
-	color.Yellow("Backing up database '%s' to '%s'...", dbName, outputFile)
-
-	cmd := exec.Command("pg_dump",
-		"-h", host,
-		"-p", port,
-		"-U", user,
-		"-d", dbName,
-		"-f", outputFile,
-		"--verbose",
-		"--no-owner",
-		"--no-acl")
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	if _, err := conn.Exec(ctx, "DROP DATABASE "+pgx.Identifier{dbName}.Sanitize()); err != nil {
+		return wrapError(err, "failed to drop database '%s'", dbName)
 	}
+	return reportAction("drop", dbName, "dropped", "✓ Database '%s' dropped successfully", dbName)
+}
 
-	output, err := cmd.CombinedOutput()
+// ensureDatabase creates dbName unless it already exists.
+func ensureDatabase(dbName string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
 	if err != nil {
-		color.Red("Error backing up database: %v\n%s", err, output)
-		return
+		return err
 	}
+	defer conn.Close(ctx)
 
-	// Get file size
-	if fileInfo, err := os.Stat(outputFile); err == nil {
-		size := fileInfo.Size()
-		sizeStr := formatBytes(size)
-		color.Green("✓ Database '%s' backed up to '%s' (%s)", dbName, outputFile, sizeStr)
-	} else {
-		color.Green("✓ Database '%s' backed up to '%s'", dbName, outputFile)
+	_, err = conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{dbName}.Sanitize())
+	if err != nil && pgErrorCode(err) != "42P04" {
+		return wrapError(err, "failed to create database '%s'", dbName)
 	}
+	return nil
 }
 
-func restoreDatabase(dbName, backupFile string) {
-	host, port, user, password, _ := getPostgresConfig()
-
-	// Check if backup file exists
-	if _, err := os.Stat(backupFile); os.IsNotExist(err) {
-		color.Red("Backup file '%s' not found", backupFile)
-		return
+func executeQuery(dbName, query string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
 	}
+	defer conn.Close(ctx)
 
-	color.Yellow("Restoring database '%s' from '%s'...", dbName, backupFile)
-
-	// First, create the database if it doesn't exist
-	createCmd := exec.Command("createdb", "-h", host, "-p", port, "-U", user, dbName)
-	createCmd.Env = os.Environ()
-	if password != "" {
-		createCmd.Env = append(createCmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	statements, err := execStatements(ctx, conn, query)
+	if err != nil {
+		return wrapError(err, "failed to execute query")
 	}
-	createCmd.CombinedOutput() // Ignore error if database already exists
 
-	// Restore the database
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-f", backupFile)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	if outputFormat() != outputText {
+		// Structured output gets the rows of the last statement that
+		// returned any, or its command tag otherwise.
+		for i := len(statements) - 1; i >= 0; i-- {
+			if statements[i].Rows != nil {
+				_, err := renderOutput(statements[i].Rows)
+				return err
+			}
+		}
+		status := ""
+		if len(statements) > 0 {
+			status = statements[len(statements)-1].Tag
+		}
+		_, err := renderOutput(actionResult{Action: "exec", Target: dbName, Status: status})
+		return err
 	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		color.Red("Error restoring database: %v\n%s", err, output)
-		return
-	}
-	color.Green("✓ Database '%s' restored from '%s'", dbName, backupFile)
+	return printStatements(statements, false)
 }
 
-func executeQuery(dbName, query string) {
-	host, port, user, password, _ := getPostgresConfig()
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", query)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+// printStatements prints each statement's rows as a table, or one record per
+// block if expanded, and its command tag otherwise.
+func printStatements(statements []statementResult, expanded bool) error {
+	for _, statement := range statements {
+		if statement.Rows == nil {
+			fmt.Println(statement.Tag)
+			continue
+		}
+		write := func() error { return writeTable(os.Stdout, statement.Rows.Headers(), statement.Rows.Rows()) }
+		if expanded {
+			write = func() error { return writeExpanded(os.Stdout, statement.Rows) }
+		}
+		if err := write(); err != nil {
+			return err
+		}
+		fmt.Printf("(%d rows)\n\n", len(statement.Rows.Values))
 	}
+	return nil
+}
 
-	output, err := cmd.CombinedOutput()
+func showDatabaseSize(dbName string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
 	if err != nil {
-		color.Red("Error executing query: %v", err)
-		return
+		return err
 	}
-	fmt.Println(string(output))
-}
+	defer conn.Close(ctx)
 
-func showDatabaseSize(dbName string) {
-	query := fmt.Sprintf("SELECT pg_database_size('%s'), pg_size_pretty(pg_database_size('%s'));", dbName, dbName)
-
-	host, port, user, password, _ := getPostgresConfig()
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", "postgres", "-t", "-c", query)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
+	query := `SELECT datname AS database, pg_database_size(datname) AS size_bytes,
+		pg_size_pretty(pg_database_size(datname)) AS size
+		FROM pg_database WHERE datname = $1`
 
-	output, err := cmd.CombinedOutput()
+	sizes, err := queryRecords[databaseSizeRecord](ctx, conn, query, dbName)
 	if err != nil {
-		color.Red("Error getting database size: %v", err)
-		return
+		return wrapError(err, "failed to get database size")
+	}
+	if len(sizes) == 0 {
+		return notFoundError("database '%s' not found", dbName)
+	}
+	if ok, err := renderOutput(sizes[0]); ok {
+		return err
 	}
 
-	color.Green("Database '%s' size: %s", dbName, string(output))
+	color.Green("Database '%s' size: %s", dbName, sizes[0].Size)
+	return nil
 }
 
-func showAllDatabaseSizes() {
-	query := `SELECT
-		datname AS database_name,
-		pg_size_pretty(pg_database_size(datname)) AS size
-	FROM pg_database
-	WHERE datistemplate = false
-	ORDER BY pg_database_size(datname) DESC;`
-
-	host, port, user, password, _ := getPostgresConfig()
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", "postgres", "-c", query)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+func showAllDatabaseSizes() error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
 	}
+	defer conn.Close(ctx)
+
+	query := `SELECT datname AS database, pg_database_size(datname) AS size_bytes,
+		pg_size_pretty(pg_database_size(datname)) AS size
+		FROM pg_database
+		WHERE datistemplate = false
+		ORDER BY pg_database_size(datname) DESC`
 
-	output, err := cmd.CombinedOutput()
+	sizes, err := queryRecords[databaseSizeRecord](ctx, conn, query)
 	if err != nil {
-		color.Red("Error getting database sizes: %v", err)
-		return
+		return wrapError(err, "failed to get database sizes")
+	}
+	if ok, err := renderOutput(sizes); ok {
+		return err
 	}
 
-	fmt.Println(string(output))
+	var rows [][]string
+	for _, size := range sizes {
+		rows = append(rows, []string{size.Database, size.Size})
+	}
+	return writeTable(os.Stdout, []string{"database", "size"}, rows)
 }
 
 func formatBytes(bytes int64) string {
@@ -367,70 +542,77 @@ func formatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-func listTables(dbName string) {
-	host, port, user, password, _ := getPostgresConfig()
+func listTables(dbName string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	return printTables(ctx, conn, dbName)
+}
 
-	// Query to get all tables with additional information
+// printTables lists the user tables of the database conn is connected to.
+func printTables(ctx context.Context, conn *pgx.Conn, dbName string) error {
 	query := `
 		SELECT
 			schemaname AS schema,
-			tablename AS table_name,
-			pg_size_pretty(pg_total_relation_size(schemaname||'.'||tablename)) AS size,
-			obj_description((schemaname||'.'||tablename)::regclass, 'pg_class') AS comment
+			tablename AS table,
+			pg_size_pretty(pg_total_relation_size(format('%I.%I', schemaname, tablename))) AS size,
+			obj_description(format('%I.%I', schemaname, tablename)::regclass, 'pg_class') AS comment
 		FROM pg_tables
 		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
-		ORDER BY schemaname, tablename;`
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", query)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
+		ORDER BY schemaname, tablename`
 
-	output, err := cmd.CombinedOutput()
+	tables, err := queryRecords[tableRecord](ctx, conn, query)
 	if err != nil {
-		// If the first query fails, try a simpler one
-		simpleQuery := `SELECT schemaname, tablename FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema') ORDER BY schemaname, tablename;`
-		cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", simpleQuery)
-		cmd.Env = os.Environ()
-		if password != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-		}
-
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			color.Red("Error listing tables: %v", err)
-			return
-		}
+		return wrapError(err, "failed to list tables")
+	}
+	if ok, err := renderOutput(tables); ok {
+		return err
 	}
 
 	color.Green("Tables in database '%s':", dbName)
-	fmt.Println(string(output))
+	var rows [][]string
+	for _, table := range tables {
+		comment := ""
+		if table.Comment != nil {
+			comment = *table.Comment
+		}
+		rows = append(rows, []string{table.Schema, table.Table, table.Size, comment})
+	}
+	if err := writeTable(os.Stdout, []string{"schema", "table", "size", "comment"}, rows); err != nil {
+		return err
+	}
+	fmt.Println()
+	color.Yellow("Total tables: %d", len(tables))
+	return nil
+}
 
-	// Also show count
-	countQuery := `SELECT COUNT(*) as table_count FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema');`
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-t", "-c", countQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+func showTableDetails(dbName, tableName string, limit int) error {
+	schema, table, err := parseQualifiedName(tableName)
+	if err != nil {
+		return usageError("invalid table name '%s': %w", tableName, err)
 	}
 
-	if countOutput, err := cmd.CombinedOutput(); err == nil {
-		count := strings.TrimSpace(string(countOutput))
-		color.Yellow("Total tables: %s", count)
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
 	}
+	defer conn.Close(ctx)
+	return printTableDetails(ctx, conn, dbName, schema, table, limit)
 }
 
-func showTableDetails(dbName, tableName string, limit int) {
-	host, port, user, password, _ := getPostgresConfig()
-
-	// Parse schema and table name
-	schema := "public"
-	table := tableName
-	if strings.Contains(tableName, ".") {
-		parts := strings.Split(tableName, ".")
-		schema = parts[0]
-		table = parts[1]
+// printTableDetails shows the structure, statistics and latest rows of a
+// table in the database conn is connected to.
+func printTableDetails(ctx context.Context, conn *pgx.Conn, dbName, schema, table string, limit int) error {
+	detail, err := getTableDetailRecord(ctx, conn, dbName, schema, table, limit)
+	if err != nil {
+		return wrapError(err, "failed to get table details")
+	}
+	if ok, err := renderOutput(detail); ok {
+		return err
 	}
 
 	color.Green("=== Table: %s.%s in database: %s ===", schema, table, dbName)
@@ -438,57 +620,78 @@ func showTableDetails(dbName, tableName string, limit int) {
 
 	// 1. Show table structure
 	color.Cyan("Table Structure:")
-	structureQuery := fmt.Sprintf(`
-		SELECT
-			column_name AS "Column",
-			data_type AS "Type",
-			character_maximum_length AS "Max Length",
-			is_nullable AS "Nullable",
-			column_default AS "Default"
-		FROM information_schema.columns
-		WHERE table_schema = '%s' AND table_name = '%s'
-		ORDER BY ordinal_position;`, schema, table)
-
-	cmd := exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", structureQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		color.Red("Error getting table structure: %v", err)
-		return
+	if err := writeOutput(os.Stdout, outputTable, detail.Columns); err != nil {
+		return err
 	}
-	fmt.Println(string(output))
+	fmt.Println()
 
 	// 2. Show indexes
 	color.Cyan("Indexes:")
-	indexQuery := fmt.Sprintf(`
-		SELECT
-			indexname AS "Index Name",
-			indexdef AS "Definition"
-		FROM pg_indexes
-		WHERE schemaname = '%s' AND tablename = '%s';`, schema, table)
+	if len(detail.Indexes) == 0 {
+		fmt.Println(" No indexes")
+	} else if err := writeOutput(os.Stdout, outputTable, detail.Indexes); err != nil {
+		return err
+	}
+	fmt.Println()
 
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", indexQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	// 3. Show foreign keys
+	color.Cyan("Foreign Keys:")
+	if len(detail.ForeignKeys) == 0 {
+		fmt.Println(" No foreign keys")
+	} else if err := writeOutput(os.Stdout, outputTable, detail.ForeignKeys); err != nil {
+		return err
 	}
+	fmt.Println()
 
-	if output, err := cmd.CombinedOutput(); err == nil {
-		fmt.Println(string(output))
+	// 4. Show row count and size
+	color.Cyan("Statistics:")
+	fmt.Printf(" Total rows: %d\n", detail.RowCount)
+	fmt.Printf(" Table size: %s\n\n", detail.Size)
+
+	// 5. Show recent records, one field per line like psql's expanded display
+	color.Cyan("Recent %d Records:", limit)
+	if len(detail.Records.Values) == 0 {
+		fmt.Println("(0 rows)")
+		return nil
 	}
+	return writeExpanded(os.Stdout, detail.Records)
+}
 
-	// 3. Show foreign keys
-	color.Cyan("Foreign Keys:")
-	fkQuery := fmt.Sprintf(`
+// writeExpanded prints each row of result as a block of column/value lines.
+func writeExpanded(w io.Writer, result *resultSet) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for i, row := range result.Rows() {
+		fmt.Fprintf(tw, "-[ RECORD %d ]-\n", i+1)
+		for j, value := range row {
+			fmt.Fprintf(tw, "%s\t| %s\n", result.Columns[j], value)
+		}
+	}
+	return tw.Flush()
+}
+
+func getTableDetailRecord(ctx context.Context, conn *pgx.Conn, dbName, schema, table string, limit int) (*tableDetailRecord, error) {
+	detail := &tableDetailRecord{Database: dbName, Schema: schema, Table: table}
+	qualified := pgx.Identifier{schema, table}.Sanitize()
+
+	columns, err := tableColumns(ctx, conn, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, notFoundError("table %s not found", qualified)
+	}
+	detail.Columns = columns
+
+	if detail.Indexes, err = tableIndexes(ctx, conn, schema, table); err != nil {
+		return nil, err
+	}
+
+	fkQuery := `
 		SELECT
-			tc.constraint_name AS "Constraint",
-			kcu.column_name AS "Column",
-			ccu.table_name AS "References Table",
-			ccu.column_name AS "References Column"
+			tc.constraint_name AS constraint,
+			kcu.column_name AS column,
+			ccu.table_name AS references_table,
+			ccu.column_name AS references_column
 		FROM information_schema.table_constraints AS tc
 		JOIN information_schema.key_column_usage AS kcu
 			ON tc.constraint_name = kcu.constraint_name
@@ -497,110 +700,70 @@ func showTableDetails(dbName, tableName string, limit int) {
 			ON ccu.constraint_name = tc.constraint_name
 			AND ccu.table_schema = tc.table_schema
 		WHERE tc.constraint_type = 'FOREIGN KEY'
-			AND tc.table_schema = '%s'
-			AND tc.table_name = '%s';`, schema, table)
-
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", fkQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
-
-	if output, err := cmd.CombinedOutput(); err == nil {
-		outputStr := string(output)
-		if strings.Contains(outputStr, "(0 rows)") {
-			fmt.Println(" No foreign keys")
-		} else {
-			fmt.Println(outputStr)
-		}
-	}
-
-	// 4. Show row count
-	color.Cyan("Statistics:")
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s.%s;`, schema, table)
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-t", "-c", countQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
-
-	if output, err := cmd.CombinedOutput(); err == nil {
-		count := strings.TrimSpace(string(output))
-		fmt.Printf(" Total rows: %s\n", count)
+			AND tc.table_schema = $1
+			AND tc.table_name = $2`
+	if detail.ForeignKeys, err = queryRecords[foreignKeyRecord](ctx, conn, fkQuery, schema, table); err != nil {
+		return nil, err
 	}
 
-	// Show table size
-	sizeQuery := fmt.Sprintf(`SELECT pg_size_pretty(pg_total_relation_size('%s.%s'));`, schema, table)
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-t", "-c", sizeQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	// The table name can only be an identifier here, never a parameter.
+	statsQuery := `SELECT (SELECT COUNT(*) FROM ` + qualified + `),
+		pg_size_pretty(pg_total_relation_size($1::regclass))`
+	if err := conn.QueryRow(ctx, statsQuery, qualified).Scan(&detail.RowCount, &detail.Size); err != nil {
+		return nil, err
 	}
 
-	if output, err := cmd.CombinedOutput(); err == nil {
-		size := strings.TrimSpace(string(output))
-		fmt.Printf(" Table size: %s\n\n", size)
+	dataQuery := `SELECT * FROM ` + qualified
+	for _, column := range []string{"id", "created_at", "created", "updated_at", "timestamp"} {
+		if hasColumn(detail.Columns, column) {
+			dataQuery += ` ORDER BY ` + pgx.Identifier{column}.Sanitize() + ` DESC`
+			break
+		}
 	}
-
-	// 5. Show recent records
-	color.Cyan("Recent %d Records:", limit)
-
-	// Try to find a column to order by (prefer id, created_at, or primary key)
-	orderColumn := ""
-	orderQuery := fmt.Sprintf(`
-		SELECT column_name
-		FROM information_schema.columns
-		WHERE table_schema = '%s' AND table_name = '%s'
-		AND column_name IN ('id', 'created_at', 'updated_at', 'created', 'timestamp')
-		ORDER BY
-			CASE column_name
-				WHEN 'id' THEN 1
-				WHEN 'created_at' THEN 2
-				WHEN 'created' THEN 3
-				WHEN 'updated_at' THEN 4
-				WHEN 'timestamp' THEN 5
-			END
-		LIMIT 1;`, schema, table)
-
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-t", "-c", orderQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	dataQuery += ` LIMIT $1`
+	if detail.Records, err = queryResultSet(ctx, conn, dataQuery, limit); err != nil {
+		return nil, err
 	}
 
-	if output, err := cmd.CombinedOutput(); err == nil {
-		orderColumn = strings.TrimSpace(string(output))
-	}
+	return detail, nil
+}
 
-	// Build the data query
-	dataQuery := fmt.Sprintf(`SELECT * FROM %s.%s`, schema, table)
-	if orderColumn != "" {
-		dataQuery += fmt.Sprintf(` ORDER BY %s DESC`, orderColumn)
-	}
-	dataQuery += fmt.Sprintf(` LIMIT %d;`, limit)
+// tableColumns returns the columns of a table or view in definition order.
+func tableColumns(ctx context.Context, conn *pgx.Conn, schema, table string) ([]columnRecord, error) {
+	query := `
+		SELECT
+			c.column_name AS column,
+			c.data_type AS type,
+			c.character_maximum_length AS max_length,
+			c.is_nullable AS nullable,
+			c.column_default AS default,
+			format_type(a.atttypid, a.atttypmod) AS sql_type,
+			c.identity_generation AS identity
+		FROM information_schema.columns AS c
+		JOIN pg_attribute AS a
+			ON a.attrelid = format('%I.%I', c.table_schema, c.table_name)::regclass
+			AND a.attname = c.column_name
+		WHERE c.table_schema = $1 AND c.table_name = $2
+		ORDER BY c.ordinal_position`
+	return queryRecords[columnRecord](ctx, conn, query, schema, table)
+}
 
-	cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-x", "-c", dataQuery)
-	cmd.Env = os.Environ()
-	if password != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-	}
+func tableIndexes(ctx context.Context, conn *pgx.Conn, schema, table string) ([]indexRecord, error) {
+	query := `
+		SELECT indexname AS name, indexdef AS definition
+		FROM pg_indexes
+		WHERE schemaname = $1 AND tablename = $2
+		ORDER BY indexname`
+	return queryRecords[indexRecord](ctx, conn, query, schema, table)
+}
 
-	output, err = cmd.CombinedOutput()
-	if err != nil {
-		// If expanded display fails, try normal display
-		cmd = exec.Command("psql", "-h", host, "-p", port, "-U", user, "-d", dbName, "-c", dataQuery)
-		cmd.Env = os.Environ()
-		if password != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
-		}
-		output, err = cmd.CombinedOutput()
-		if err != nil {
-			color.Red("Error getting table data: %v", err)
-			return
+func hasColumn(columns []columnRecord, name string) bool {
+	for _, column := range columns {
+		if column.Column == name {
+			return true
 		}
 	}
-
-	fmt.Println(string(output))
+	return false
 }
 
 func GetDBCommand() *cobra.Command {
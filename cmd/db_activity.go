@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var dbActivityCmd = &cobra.Command{
+	Use:   "activity [database-name]",
+	Short: "Show live sessions with their state, waits and blockers",
+	Long: `Show the client sessions of the server, or of one database, refreshed every
+--interval until Ctrl-C. Idle sessions are hidden unless --all is given.
+Sessions are listed longest-running first; blocked_by names the pids holding
+the locks a session waits for.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts activityOptions
+		if len(args) == 1 {
+			opts.Database = args[0]
+		}
+		opts.All, _ = cmd.Flags().GetBool("all")
+		opts.Once, _ = cmd.Flags().GetBool("once")
+		opts.Interval, _ = cmd.Flags().GetDuration("interval")
+		return showActivity(opts)
+	},
+}
+
+var dbLocksCmd = &cobra.Command{
+	Use:   "locks [database-name]",
+	Short: "Show which sessions block which as a tree",
+	Long: `Show blocked sessions under the sessions blocking them, with the lock each
+one waits for. The sessions at the top of the tree are the ones to look at,
+often idle in transaction.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbName := ""
+		if len(args) == 1 {
+			dbName = args[0]
+		}
+		return showLocks(dbName)
+	},
+}
+
+var dbKillCmd = &cobra.Command{
+	Use:   "kill [pid...]",
+	Short: "Terminate sessions, or cancel their current query",
+	Long: `Terminate the sessions with the given pids with pg_terminate_backend, or only
+cancel their running query with --cancel. The sessions are shown and must be
+confirmed unless --yes is given.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cancel, _ := cmd.Flags().GetBool("cancel")
+		yes, _ := cmd.Flags().GetBool("yes")
+		return killSessions(args, cancel, yes)
+	},
+}
+
+func init() {
+	dbActivityCmd.Flags().BoolP("all", "a", false, "Include idle sessions")
+	dbActivityCmd.Flags().Bool("once", false, "Show one snapshot instead of refreshing")
+	dbActivityCmd.Flags().Duration("interval", 2*time.Second, "Refresh interval")
+	dbKillCmd.Flags().Bool("cancel", false, "Cancel the running query instead of terminating the session")
+	dbKillCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	dbCmd.AddCommand(dbActivityCmd)
+	dbCmd.AddCommand(dbLocksCmd)
+	dbCmd.AddCommand(dbKillCmd)
+}
+
+type activityOptions struct {
+	Database string
+	All      bool
+	Once     bool
+	Interval time.Duration
+}
+
+type sessionRecord struct {
+	PID             int      `json:"pid" db:"pid"`
+	User            string   `json:"user" db:"user"`
+	Database        string   `json:"database" db:"database"`
+	Application     string   `json:"application" db:"application"`
+	Client          string   `json:"client" db:"client"`
+	State           string   `json:"state" db:"state"`
+	Wait            string   `json:"wait" db:"wait"`
+	QueryAgeSeconds *float64 `json:"query_age_seconds" db:"query_age_seconds"`
+	XactAgeSeconds  *float64 `json:"xact_age_seconds" db:"xact_age_seconds"`
+	BlockedBy       []int    `json:"blocked_by" db:"blocked_by"`
+	Query           string   `json:"query" db:"query"`
+}
+
+// lockRecord is a session in the blocking tree with the lock it waits for.
+type lockRecord struct {
+	sessionRecord
+	LockType string `json:"lock_type" db:"lock_type"`
+	LockMode string `json:"lock_mode" db:"lock_mode"`
+	Relation string `json:"relation" db:"relation"`
+}
+
+// sessionColumns selects a sessionRecord from pg_stat_activity.
+const sessionColumns = `
+	a.pid,
+	coalesce(a.usename, '') AS user,
+	coalesce(a.datname, '') AS database,
+	a.application_name AS application,
+	coalesce(host(a.client_addr), 'local') AS client,
+	coalesce(a.state, '') AS state,
+	coalesce(a.wait_event_type || ':' || a.wait_event, '') AS wait,
+	extract(epoch FROM now() - a.query_start)::float8 AS query_age_seconds,
+	extract(epoch FROM now() - a.xact_start)::float8 AS xact_age_seconds,
+	pg_blocking_pids(a.pid) AS blocked_by,
+	coalesce(a.query, '') AS query`
+
+func listSessions(ctx context.Context, conn *pgx.Conn, dbName string, all bool) ([]sessionRecord, error) {
+	query := `SELECT` + sessionColumns + `
+		FROM pg_stat_activity AS a
+		WHERE a.backend_type = 'client backend' AND a.pid <> pg_backend_pid()
+			AND ($1 = '' OR a.datname = $1)
+			AND ($2 OR a.state IS DISTINCT FROM 'idle')
+		ORDER BY a.query_start NULLS LAST`
+	return queryRecords[sessionRecord](ctx, conn, query, dbName, all)
+}
+
+// formatAge prints a duration in seconds compactly, e.g. 4m05s.
+func formatAge(seconds *float64) string {
+	if seconds == nil {
+		return ""
+	}
+	d := time.Duration(*seconds * float64(time.Second))
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%02dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+// oneLine collapses a query to a single line of at most width characters,
+// counted in runes so multi-byte text is never cut in half.
+func oneLine(query string, width int) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if runes := []rune(query); len(runes) > width {
+		query = string(runes[:width-3]) + "..."
+	}
+	return query
+}
+
+func formatPIDs(pids []int) string {
+	parts := make([]string, len(pids))
+	for i, pid := range pids {
+		parts[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(parts, ",")
+}
+
+func showActivity(opts activityOptions) error {
+	if opts.Interval < 100*time.Millisecond {
+		return usageError("--interval must be at least 100ms")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	// Structured output and pipes get a single snapshot
+	refresh := !opts.Once && outputFormat() == outputText && isatty.IsTerminal(os.Stdout.Fd())
+	for {
+		sessions, err := listSessions(ctx, conn, opts.Database, opts.All)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return wrapError(err, "failed to read sessions")
+		}
+		if ok, err := renderOutput(sessions); ok {
+			return err
+		}
+
+		if refresh {
+			// Clear the screen and start at the top
+			fmt.Print("\033[H\033[2J")
+		}
+		printSessions(sessions, opts, refresh)
+		if !refresh {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+func printSessions(sessions []sessionRecord, opts activityOptions, refresh bool) {
+	scope := "all databases"
+	if opts.Database != "" {
+		scope = fmt.Sprintf("database '%s'", opts.Database)
+	}
+	color.Green("Sessions on %s at %s", scope, time.Now().Format(time.TimeOnly))
+	if refresh {
+		color.Yellow("Refreshing every %s, Ctrl-C to quit", opts.Interval)
+	}
+
+	var rows [][]string
+	blocked := 0
+	for _, session := range sessions {
+		if len(session.BlockedBy) > 0 {
+			blocked++
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(session.PID), session.User, session.Database, session.Client, session.State, session.Wait,
+			formatAge(session.QueryAgeSeconds), formatAge(session.XactAgeSeconds), formatPIDs(session.BlockedBy),
+			oneLine(session.Query, 60),
+		})
+	}
+	writeTable(os.Stdout, []string{"pid", "user", "database", "client", "state", "wait", "query age", "xact age", "blocked by", "query"}, rows)
+	fmt.Println()
+	summary := fmt.Sprintf("Total sessions: %d", len(sessions))
+	if blocked > 0 {
+		summary += fmt.Sprintf(", %d blocked (see 'tools db locks')", blocked)
+	}
+	color.Yellow("%s", summary)
+}
+
+func showLocks(dbName string) error {
+	// Relation OIDs only resolve to names in the database they belong to
+	connectTo := dbName
+	if connectTo == "" {
+		connectTo = maintenanceDB
+	}
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, connectTo)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	query := `
+		WITH sessions AS (
+			SELECT` + sessionColumns + `
+			FROM pg_stat_activity AS a
+			WHERE a.backend_type = 'client backend' AND ($1 = '' OR a.datname = $1)
+		)
+		SELECT s.*,
+			coalesce(l.locktype, '') AS lock_type,
+			coalesce(l.mode, '') AS lock_mode,
+			CASE
+				WHEN l.relation IS NULL THEN ''
+				WHEN l.database IN (0, (SELECT oid FROM pg_database WHERE datname = current_database()))
+					THEN l.relation::regclass::text
+				ELSE format('oid %s in %s', l.relation,
+					coalesce((SELECT datname FROM pg_database WHERE oid = l.database), l.database::text))
+			END AS relation
+		FROM sessions AS s
+		LEFT JOIN LATERAL (
+			SELECT locktype, mode, database, relation FROM pg_locks
+			WHERE pid = s.pid AND NOT granted
+			LIMIT 1
+		) AS l ON true
+		WHERE cardinality(s.blocked_by) > 0
+			OR s.pid IN (SELECT unnest(blocked_by) FROM sessions)
+		ORDER BY s.query_start NULLS LAST`
+	locks, err := queryRecords[lockRecord](ctx, conn, query, dbName)
+	if err != nil {
+		return wrapError(err, "failed to read locks")
+	}
+	if ok, err := renderOutput(locks); ok {
+		return err
+	}
+
+	if len(locks) == 0 {
+		color.Green("No blocked sessions")
+		return nil
+	}
+	printLockTree(locks)
+	return nil
+}
+
+// printLockTree prints every blocking session with the sessions waiting for
+// it indented below.
+func printLockTree(locks []lockRecord) {
+	byPID := make(map[int]lockRecord, len(locks))
+	children := map[int][]int{}
+	var roots []int
+	for _, lock := range locks {
+		byPID[lock.PID] = lock
+		for _, blocker := range lock.BlockedBy {
+			children[blocker] = append(children[blocker], lock.PID)
+		}
+	}
+	for _, lock := range locks {
+		// Roots block others without waiting themselves; a session blocked
+		// only by sessions outside the result (another database) is one too.
+		if !slices.ContainsFunc(lock.BlockedBy, func(pid int) bool { _, ok := byPID[pid]; return ok }) {
+			roots = append(roots, lock.PID)
+		}
+	}
+
+	var printTree func(pid int, prefix string, last, top bool, path []int)
+	printTree = func(pid int, prefix string, last, top bool, path []int) {
+		lock := byPID[pid]
+		branch, indent := "", ""
+		if !top {
+			branch, indent = "├─ ", "│  "
+			if last {
+				branch, indent = "└─ ", "   "
+			}
+		}
+		line := fmt.Sprintf("%d %s@%s %s", lock.PID, lock.User, lock.Database, lock.State)
+		if age := formatAge(lock.XactAgeSeconds); age != "" {
+			line += " xact " + age
+		}
+		if lock.LockMode != "" {
+			waits := lock.LockMode + " on " + lock.LockType
+			if lock.Relation != "" {
+				waits += " " + lock.Relation
+			}
+			line += " waits " + formatAge(lock.QueryAgeSeconds) + " for " + waits
+		}
+		if top {
+			color.New(color.FgRed).Printf("%s%s", prefix, line)
+		} else {
+			fmt.Printf("%s%s%s", prefix, branch, line)
+		}
+		fmt.Printf("  %s\n", oneLine(lock.Query, 60))
+
+		if slices.Contains(path, pid) {
+			return // deadlock cycle, the server will break it
+		}
+		kids := children[pid]
+		for i, child := range kids {
+			printTree(child, prefix+indent, i == len(kids)-1, false, append(path, pid))
+		}
+	}
+	blocked := 0
+	for _, root := range roots {
+		printTree(root, "", true, true, nil)
+		fmt.Println()
+	}
+	for _, lock := range locks {
+		if len(lock.BlockedBy) > 0 {
+			blocked++
+		}
+	}
+	color.Yellow("Blocked sessions: %d. End a blocker with 'tools db kill <pid>'.", blocked)
+}
+
+func killSessions(args []string, cancel, yes bool) error {
+	pids := make([]int, len(args))
+	for i, arg := range args {
+		pid, err := strconv.Atoi(arg)
+		if err != nil || pid <= 0 {
+			return usageError("invalid pid '%s'", arg)
+		}
+		pids[i] = pid
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	query := `SELECT` + sessionColumns + `
+		FROM pg_stat_activity AS a
+		WHERE a.pid = ANY($1)
+		ORDER BY a.pid`
+	sessions, err := queryRecords[sessionRecord](ctx, conn, query, pids)
+	if err != nil {
+		return wrapError(err, "failed to read sessions")
+	}
+	for _, pid := range pids {
+		if !slices.ContainsFunc(sessions, func(s sessionRecord) bool { return s.PID == pid }) {
+			return notFoundError("no session with pid %d", pid)
+		}
+	}
+
+	action, verb, function := "kill", "terminated", "pg_terminate_backend"
+	if cancel {
+		action, verb, function = "cancel", "cancelled", "pg_cancel_backend"
+	}
+	if !yes {
+		if cancel {
+			color.Yellow("WARNING: This will cancel the running query of:")
+		} else {
+			color.Yellow("WARNING: This will terminate:")
+		}
+		for _, session := range sessions {
+			fmt.Printf("  %d %s@%s %s  %s\n", session.PID, session.User, session.Database, session.State, oneLine(session.Query, 60))
+		}
+		fmt.Print("Continue? [y/N]: ")
+		var confirm string
+		fmt.Scanln(&confirm)
+		if confirm != "y" && confirm != "Y" && confirm != "yes" {
+			return cancelledError("%s cancelled", action)
+		}
+	}
+
+	var results []actionResult
+	for _, session := range sessions {
+		var signalled bool
+		if err := conn.QueryRow(ctx, "SELECT "+function+"($1)", session.PID).Scan(&signalled); err != nil {
+			return wrapError(err, "failed to signal session %d", session.PID)
+		}
+		status := verb
+		if !signalled {
+			status = "gone"
+		}
+		results = append(results, actionResult{Action: action, Target: strconv.Itoa(session.PID), Status: status})
+	}
+	if ok, err := renderOutput(results); ok {
+		return err
+	}
+	for _, result := range results {
+		if result.Status == "gone" {
+			color.Yellow("Session %s had already ended", result.Target)
+			continue
+		}
+		color.Green("✓ Session %s %s", result.Target, result.Status)
+	}
+	return nil
+}
@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbAnonymizeCmd = &cobra.Command{
+	Use:   "anonymize [database-name]",
+	Short: "Mask personal data in a database using the masking rules",
+	Long: `Overwrite sensitive columns in place according to the masking rules in the
+config file, e.g. after restoring a production dump into dev.
+
+Rules are listed per table under masking.rules:
+
+  hash          deterministic hash of the value (joins on it still match)
+  name          fake "First Last" name derived from the value
+  email         fake user_<hash>@example.com address derived from the value
+  null          NULL (also written as a bare YAML null)
+  fixed:VALUE   the given value, cast to the column type
+  keep          leave the column as it is
+
+Tables are updated in batches of --batch-size rows along the primary key,
+each batch in its own transaction, so large tables are never locked for
+long. Tables without a primary key are updated in one statement.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts maskOptions
+		opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+		return anonymizeDatabase("", args[0], opts)
+	},
+}
+
+func init() {
+	dbAnonymizeCmd.Flags().Int("batch-size", 0, "Rows per batch (default masking.batch_size or 10000)")
+	dbAnonymizeCmd.Flags().Bool("dry-run", false, "Show the masking statement and batching of each table without changing data")
+	dbCmd.AddCommand(dbAnonymizeCmd)
+}
+
+const defaultMaskBatchSize = 10000
+
+// Masking rule kinds.
+const (
+	maskKeep  = "keep"
+	maskNull  = "null"
+	maskHash  = "hash"
+	maskName  = "name"
+	maskEmail = "email"
+	maskFixed = "fixed"
+)
+
+var (
+	fakeFirstNames = []string{"Alex", "Blake", "Casey", "Dana", "Eli", "Frankie", "Gray", "Harper", "Indy", "Jordan",
+		"Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker", "Quinn", "Riley", "Sam", "Taylor"}
+	fakeLastNames = []string{"Adams", "Baker", "Clark", "Diaz", "Evans", "Foster", "Garcia", "Hughes", "Irwin", "Jones",
+		"King", "Lopez", "Miller", "Nguyen", "Owens", "Patel", "Reed", "Smith", "Turner", "Walker"}
+)
+
+type maskOptions struct {
+	BatchSize int
+	DryRun    bool
+}
+
+// maskingConfig is the masking section of the config file. Columns are a
+// list rather than a map because viper lower-cases map keys, which would lose
+// mixed-case column names.
+type maskingConfig struct {
+	Salt      string `mapstructure:"salt"`
+	BatchSize int    `mapstructure:"batch_size"`
+	Rules     []struct {
+		Table   string `mapstructure:"table"`
+		Columns []struct {
+			Column string `mapstructure:"column"`
+			Rule   string `mapstructure:"rule"`
+		} `mapstructure:"columns"`
+	} `mapstructure:"rules"`
+}
+
+type maskRule struct {
+	Kind  string
+	Value string
+}
+
+type columnMask struct {
+	Column string
+	Rule   maskRule
+}
+
+type tableMask struct {
+	Schema  string
+	Table   string
+	Columns []columnMask
+}
+
+type maskRecord struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+	Batches int      `json:"batches"`
+	// The statement and batch condition, filled in by --dry-run
+	Statement string `json:"statement,omitempty"`
+	Batch     string `json:"batch,omitempty"`
+}
+
+func parseMaskRule(rule string) (maskRule, error) {
+	kind, value, hasValue := strings.Cut(rule, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	switch kind {
+	case "":
+		// A bare YAML null decodes to an empty string
+		return maskRule{Kind: maskNull}, nil
+	case maskKeep, maskNull, maskHash, maskName, maskEmail:
+		if hasValue {
+			return maskRule{}, fmt.Errorf("rule '%s' takes no value", kind)
+		}
+		return maskRule{Kind: kind}, nil
+	case maskFixed:
+		if !hasValue {
+			return maskRule{}, fmt.Errorf("rule fixed needs a value, e.g. fixed:redacted")
+		}
+		return maskRule{Kind: maskFixed, Value: value}, nil
+	}
+	return maskRule{}, fmt.Errorf("unknown rule '%s' (valid: keep, null, hash, name, email, fixed:VALUE)", rule)
+}
+
+// loadMaskingRules reads and parses the masking rules from the config file.
+func loadMaskingRules() (maskingConfig, []tableMask, error) {
+	var config maskingConfig
+	if err := settingUnmarshal("masking", &config); err != nil {
+		return config, nil, usageError("invalid masking section in config file: %w", err)
+	}
+	if len(config.Rules) == 0 {
+		return config, nil, notFoundError("no masking rules configured, add masking.rules to the config file")
+	}
+
+	masks := make([]tableMask, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		schema, table, err := parseQualifiedName(rule.Table)
+		if err != nil {
+			return config, nil, usageError("invalid masking table '%s': %w", rule.Table, err)
+		}
+		mask := tableMask{Schema: schema, Table: table}
+		for _, column := range rule.Columns {
+			if column.Column == "" {
+				return config, nil, usageError("masking rule for %s is missing a column", rule.Table)
+			}
+			if slices.ContainsFunc(mask.Columns, func(c columnMask) bool { return c.Column == column.Column }) {
+				return config, nil, usageError("column %s of %s has more than one masking rule", column.Column, rule.Table)
+			}
+			parsed, err := parseMaskRule(column.Rule)
+			if err != nil {
+				return config, nil, usageError("masking rule for %s.%s: %w", rule.Table, column.Column, err)
+			}
+			mask.Columns = append(mask.Columns, columnMask{Column: column.Column, Rule: parsed})
+		}
+		masks = append(masks, mask)
+	}
+	return config, masks, nil
+}
+
+// maskParams collects the parameters of a masking statement.
+type maskParams struct {
+	args []any
+}
+
+func (p *maskParams) add(value any) string {
+	p.args = append(p.args, value)
+	return "$" + strconv.Itoa(len(p.args))
+}
+
+// textColumnTypes are the column types hash, name and email can write.
+var textColumnTypes = []string{"text", "character varying", "character", "citext"}
+
+// expression returns the SQL computing the masked value of column.
+func (r maskRule) expression(column columnRecord, salt string, params *maskParams) (string, error) {
+	name := pgx.Identifier{column.Column}.Sanitize()
+	if r.Kind == maskHash || r.Kind == maskName || r.Kind == maskEmail {
+		if !slices.Contains(textColumnTypes, column.Type) && column.SQLType != "citext" {
+			return "", fmt.Errorf("rule %s needs a text column, %s is %s", r.Kind, column.Column, column.SQLType)
+		}
+	}
+
+	switch r.Kind {
+	case maskNull:
+		return "NULL", nil
+	case maskFixed:
+		return fmt.Sprintf("%s::%s", params.add(r.Value), column.SQLType), nil
+	}
+
+	// Derived values hash the salted original, so equal inputs mask to equal
+	// outputs and NULL stays NULL.
+	digest := fmt.Sprintf("md5(%s::text || %s)", name, params.add(salt))
+	switch r.Kind {
+	case maskHash:
+		return fmt.Sprintf("%s::%s", digest, column.SQLType), nil
+	case maskName:
+		return fmt.Sprintf("(CASE WHEN %s IS NOT NULL THEN (%s::text[])[1 + abs(hashtext(%s)::bigint) %% %d] || ' ' || (%s::text[])[1 + abs(hashtext(reverse(%s))::bigint) %% %d] END)::%s",
+			name, params.add(fakeFirstNames), digest, len(fakeFirstNames),
+			params.add(fakeLastNames), digest, len(fakeLastNames), column.SQLType), nil
+	case maskEmail:
+		return fmt.Sprintf("('user_' || left(%s, 12) || '@example.com')::%s", digest, column.SQLType), nil
+	}
+	return "", fmt.Errorf("rule %s has no expression", r.Kind)
+}
+
+// primaryKeyColumns returns the primary key columns of a table in key order.
+func primaryKeyColumns(ctx context.Context, conn *pgx.Conn, qualified string) ([]string, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT a.attname
+		FROM pg_index AS i
+		JOIN pg_attribute AS a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::regclass AND i.indisprimary
+		ORDER BY array_position(i.indkey::int2[], a.attnum)`, qualified)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func anonymizeDatabase(profile, dbName string, opts maskOptions) error {
+	records, err := maskDatabase(profile, dbName, opts)
+	if err != nil {
+		return err
+	}
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+	if opts.DryRun {
+		for _, record := range records {
+			color.Cyan("%s: %s", record.Table, strings.Join(record.Columns, ", "))
+			fmt.Printf("  %s\n", record.Statement)
+			if record.Batch != "" {
+				fmt.Printf("  %s\n", record.Batch)
+			} else {
+				color.Yellow("  no primary key, updated in a single statement")
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+	var total int64
+	for _, record := range records {
+		total += record.Rows
+	}
+	color.Green("✓ Masked %d rows in %d tables of database '%s'", total, len(records), dbName)
+	return nil
+}
+
+// maskDatabase applies the masking rules to dbName on the server of profile.
+func maskDatabase(profile, dbName string, opts maskOptions) ([]maskRecord, error) {
+	config, masks, err := loadMaskingRules()
+	if err != nil {
+		return nil, err
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = config.BatchSize
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultMaskBatchSize
+	}
+	if opts.BatchSize < 1 {
+		return nil, usageError("--batch-size must be at least 1")
+	}
+	if config.Salt == "" {
+		printError("Warning: masking.salt is not set, hashed values can be matched against guessed inputs")
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgresProfile(ctx, profile, dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	records := []maskRecord{}
+	for _, mask := range masks {
+		record, err := maskTable(ctx, conn, mask, config.Salt, opts)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			records = append(records, *record)
+		}
+	}
+	return records, nil
+}
+
+// maskTable masks the columns of one table batch by batch along its primary
+// key. It returns nil when every column is kept.
+func maskTable(ctx context.Context, conn *pgx.Conn, mask tableMask, salt string, opts maskOptions) (*maskRecord, error) {
+	qualified := qualifiedName(mask.Schema, mask.Table)
+	columns, err := tableColumns(ctx, conn, mask.Schema, mask.Table)
+	if err != nil {
+		return nil, wrapError(err, "failed to read columns of %s", qualified)
+	}
+	if len(columns) == 0 {
+		return nil, notFoundError("table %s from the masking rules not found", qualified)
+	}
+	keys, err := primaryKeyColumns(ctx, conn, qualified)
+	if err != nil {
+		return nil, wrapError(err, "failed to read primary key of %s", qualified)
+	}
+
+	var params maskParams
+	var assignments []string
+	record := &maskRecord{Table: qualified}
+	for _, columnRule := range mask.Columns {
+		index := slices.IndexFunc(columns, func(c columnRecord) bool { return c.Column == columnRule.Column })
+		if index < 0 {
+			return nil, notFoundError("column %s of %s from the masking rules not found", columnRule.Column, qualified)
+		}
+		if columnRule.Rule.Kind == maskKeep {
+			continue
+		}
+		// Batches walk the primary key, and foreign keys point at it
+		if slices.Contains(keys, columnRule.Column) {
+			return nil, usageError("%s: column %s is part of the primary key and cannot be masked", qualified, columnRule.Column)
+		}
+		expression, err := columnRule.Rule.expression(columns[index], salt, &params)
+		if err != nil {
+			return nil, usageError("%s: %w", qualified, err)
+		}
+		assignments = append(assignments, pgx.Identifier{columnRule.Column}.Sanitize()+" = "+expression)
+		record.Columns = append(record.Columns, columnRule.Column)
+	}
+	if len(assignments) == 0 {
+		return nil, nil
+	}
+	update := "UPDATE " + qualified + " SET " + strings.Join(assignments, ", ")
+
+	keyList := make([]string, len(keys))
+	for i, key := range keys {
+		keyList[i] = pgx.Identifier{key}.Sanitize()
+	}
+	keyTuple := "(" + strings.Join(keyList, ", ") + ")"
+
+	if opts.DryRun {
+		record.Statement = update
+		if len(keys) > 0 {
+			record.Batch = fmt.Sprintf("WHERE %s > <end of previous batch> AND %s <= <end of this batch>, %d rows per batch",
+				keyTuple, keyTuple, opts.BatchSize)
+		}
+		return record, nil
+	}
+
+	started := time.Now()
+	if len(keys) == 0 {
+		printProgress("Masking %s (no primary key, single statement)...", qualified)
+		tag, err := conn.Exec(ctx, update, params.args...)
+		if err != nil {
+			return nil, wrapError(err, "failed to mask %s", qualified)
+		}
+		record.Rows, record.Batches = tag.RowsAffected(), 1
+		return record, nil
+	}
+
+	printProgress("Masking %s in batches of %d...", qualified, opts.BatchSize)
+
+	var last []any
+	for {
+		// Find where this batch ends, then update everything up to there
+		var batchParams maskParams
+		lower := ""
+		if last != nil {
+			lower = " WHERE " + keyTuple + " > " + paramTuple(&batchParams, last)
+		}
+		boundQuery := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s OFFSET %d LIMIT 1",
+			strings.Join(keyList, ", "), qualified, lower, strings.Join(keyList, ", "), opts.BatchSize-1)
+		end, err := queryKey(ctx, conn, boundQuery, batchParams.args)
+		if err != nil {
+			return nil, wrapError(err, "failed to batch %s", qualified)
+		}
+
+		statementParams := maskParams{args: slices.Clone(params.args)}
+		var conditions []string
+		if last != nil {
+			conditions = append(conditions, keyTuple+" > "+paramTuple(&statementParams, last))
+		}
+		if end != nil {
+			conditions = append(conditions, keyTuple+" <= "+paramTuple(&statementParams, end))
+		}
+		statement := update
+		if len(conditions) > 0 {
+			statement += " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		tag, err := conn.Exec(ctx, statement, statementParams.args...)
+		if err != nil {
+			return nil, wrapError(err, "failed to mask %s", qualified)
+		}
+		record.Rows += tag.RowsAffected()
+		record.Batches++
+		if end == nil {
+			break
+		}
+		last = end
+		if record.Batches%10 == 0 {
+			printProgress("  %s: %d rows masked", qualified, record.Rows)
+		}
+	}
+	printProgress("  %s: %d rows masked in %s", qualified, record.Rows, time.Since(started).Round(time.Millisecond))
+	return record, nil
+}
+
+// paramTuple adds values as parameters and returns them as a row constructor.
+func paramTuple(params *maskParams, values []any) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = params.add(value)
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")"
+}
+
+// queryKey returns the key values of the single row query finds, or nil.
+func queryKey(ctx context.Context, conn *pgx.Conn, query string, args []any) ([]any, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var key []any
+	if rows.Next() {
+		if key, err = rows.Values(); err != nil {
+			return nil, err
+		}
+	}
+	return key, rows.Err()
+}
@@ -0,0 +1,540 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/fatih/color"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Archive formats understood by pg_dump and pg_restore.
+const (
+	formatPlain     = "plain"
+	formatCustom    = "custom"
+	formatDirectory = "directory"
+	formatTar       = "tar"
+)
+
+// Compression methods for backups.
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+var backupFormats = []string{formatPlain, formatCustom, formatDirectory, formatTar}
+
+var compressionMethods = []string{compressNone, compressGzip, compressZstd}
+
+type backupOptions struct {
+	Format      string
+	Compression string
+	Jobs        int
+	Encryption  string
+	Recipients  []string
+	KeyFile     string
+
+	// Keys resolved by validate
+	recipients []age.Recipient
+	passphrase []byte
+}
+
+type restoreOptions struct {
+	Clean             bool
+	IfExists          bool
+	SingleTransaction bool
+	Jobs              int
+	KeyFile           string
+}
+
+type backupRecord struct {
+	Database    string `json:"database"`
+	Path        string `json:"path"`
+	Format      string `json:"format"`
+	Compression string `json:"compression"`
+	Encryption  string `json:"encryption"`
+	SizeBytes   int64  `json:"size_bytes"`
+	Checksum    string `json:"checksum"`
+}
+
+// validate fills in the defaults of pg_dump and rejects combinations it
+// does not support.
+func (o *backupOptions) validate() error {
+	o.Format = strings.ToLower(o.Format)
+	if !slices.Contains(backupFormats, o.Format) {
+		return usageError("unknown backup format '%s' (valid: %s)", o.Format, strings.Join(backupFormats, ", "))
+	}
+
+	o.Compression = strings.ToLower(o.Compression)
+	if o.Compression == "" {
+		// pg_dump compresses custom and directory archives by default
+		o.Compression = compressNone
+		if o.Format == formatCustom || o.Format == formatDirectory {
+			o.Compression = compressGzip
+		}
+	}
+	if !slices.Contains(compressionMethods, o.Compression) {
+		return usageError("unknown compression '%s' (valid: %s)", o.Compression, strings.Join(compressionMethods, ", "))
+	}
+
+	if o.Jobs < 1 {
+		return usageError("--jobs must be at least 1")
+	}
+	if o.Jobs > 1 && o.Format != formatDirectory {
+		return usageError("--jobs requires --format directory")
+	}
+	return o.resolveEncryption()
+}
+
+func (o *restoreOptions) validate() error {
+	if o.IfExists && !o.Clean {
+		return usageError("--if-exists requires --clean")
+	}
+	if o.Jobs < 1 {
+		return usageError("--jobs must be at least 1")
+	}
+	if o.Jobs > 1 && o.SingleTransaction {
+		return usageError("--jobs cannot be combined with --single-transaction")
+	}
+	return nil
+}
+
+// streamed reports whether the dump goes through stdout, where plain and tar
+// output get compressed by us. Custom and directory archives are compressed
+// by pg_dump itself.
+func (o *backupOptions) streamed() bool {
+	return o.Format != formatDirectory
+}
+
+// seekable reports whether a local backup is written by pg_dump itself with
+// --file. Custom archives written that way record the offset of each table's
+// data, which pg_restore --jobs needs to restore them in parallel; piped
+// through stdout they have none.
+func (o *backupOptions) seekable() bool {
+	return o.Format == formatDirectory || (o.Format == formatCustom && o.Encryption == encryptNone)
+}
+
+// backupPath adds the conventional extension for the format, compression and
+// encryption to outputFile unless it is already there.
+func backupPath(outputFile string, opts backupOptions) string {
+	var ext string
+	switch opts.Format {
+	case formatPlain:
+		ext = ".sql"
+	case formatCustom:
+		ext = ".dump"
+	case formatTar:
+		ext = ".tar"
+	case formatDirectory:
+		return outputFile
+	}
+	if opts.Format != formatCustom {
+		ext += compressionExtension(opts.Compression)
+	}
+	ext += encryptionExtension(opts.Encryption)
+
+	if strings.HasSuffix(outputFile, ext) {
+		return outputFile
+	}
+	return strings.TrimSuffix(outputFile, ".sql") + ext
+}
+
+// describeBackup summarizes format, compression and encryption for progress
+// messages, e.g. "custom, gzip, age".
+func describeBackup(opts backupOptions) string {
+	desc := opts.Format + ", " + opts.Compression
+	if opts.Encryption != "" && opts.Encryption != encryptNone {
+		desc += ", " + opts.Encryption
+	}
+	return desc
+}
+
+func compressionExtension(method string) string {
+	switch method {
+	case compressGzip:
+		return ".gz"
+	case compressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// pgTool builds an invocation of a PostgreSQL client tool with the
+// configured connection settings.
+func pgTool(name string, args ...string) *exec.Cmd {
+	return pgToolProfile("", name, args...)
+}
+
+// pgToolProfile is pgTool for the server of the named profile.
+func pgToolProfile(profile, name string, args ...string) *exec.Cmd {
+	host, port, user, password := postgresSettings(profile)
+
+	var connArgs []string
+	if host != "" {
+		connArgs = append(connArgs, "-h", host)
+	}
+	if port != "" {
+		connArgs = append(connArgs, "-p", port)
+	}
+	if user != "" {
+		connArgs = append(connArgs, "-U", user)
+	}
+
+	cmd := exec.Command(name, append(connArgs, args...)...)
+	cmd.Env = os.Environ()
+	if password != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPASSWORD=%s", password))
+	}
+	return cmd
+}
+
+// runTool runs cmd and attaches its stderr to the error when it fails.
+func runTool(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return toolOutputError(err, stderr.Bytes())
+	}
+	return nil
+}
+
+func pgDumpArgs(dbName string, opts backupOptions) []string {
+	args := []string{"-d", dbName, "--no-owner", "--no-acl", "--format", opts.Format}
+
+	if !opts.streamed() || opts.Format == formatCustom {
+		switch opts.Compression {
+		case compressNone:
+			args = append(args, "--compress", "0")
+		case compressGzip:
+			args = append(args, "--compress", "6")
+		case compressZstd:
+			// zstd needs pg_dump 16 or later
+			args = append(args, "--compress", "zstd")
+		}
+	}
+	if opts.Jobs > 1 {
+		args = append(args, "--jobs", strconv.Itoa(opts.Jobs))
+	}
+	return args
+}
+
+// streamDump runs pg_dump for a plain, custom or tar backup and writes the
+// dump into w, compressing plain and tar output and then encrypting it as
+// requested.
+func streamDump(dbName string, w io.Writer, opts backupOptions) error {
+	compression := opts.Compression
+	if opts.Format == formatCustom {
+		compression = compressNone
+	}
+
+	encrypted, err := encryptWriter(w, opts)
+	if err != nil {
+		return fmt.Errorf("failed to set up encryption: %w", err)
+	}
+	out, err := compressWriter(encrypted, compression)
+	if err != nil {
+		return err
+	}
+
+	cmd := pgTool("pg_dump", pgDumpArgs(dbName, opts)...)
+	cmd.Stdout = out
+	if err := runTool(cmd); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return encrypted.Close()
+}
+
+func backupDatabase(dbName, outputFile string, opts backupOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	outputFile = backupPath(outputFile, opts)
+
+	printProgress("Backing up database '%s' to '%s' (%s)...", dbName, outputFile, describeBackup(opts))
+
+	var checksum string
+	var err error
+	if !opts.seekable() {
+		checksum, err = dumpToFile(dbName, outputFile, opts)
+		if err != nil {
+			return wrapError(err, "failed to back up database '%s'", dbName)
+		}
+	} else {
+		args := append(pgDumpArgs(dbName, opts), "--file", outputFile)
+		if err := runTool(pgTool("pg_dump", args...)); err != nil {
+			if opts.Format == formatCustom {
+				os.Remove(outputFile)
+			}
+			return wrapError(err, "failed to back up database '%s'", dbName)
+		}
+		if checksum, err = fileChecksum(outputFile); err != nil {
+			return wrapError(err, "failed to checksum backup '%s'", outputFile)
+		}
+	}
+
+	size, _ := pathSize(outputFile)
+	entry := backupEntry{
+		Database:    dbName,
+		Name:        filepath.Base(outputFile),
+		Format:      opts.Format,
+		Compression: opts.Compression,
+		Encryption:  opts.Encryption,
+		SizeBytes:   size,
+		Checksum:    checksum,
+		CreatedAt:   time.Now().UTC(),
+	}
+	store := &localStore{dir: filepath.Dir(outputFile)}
+	if err := recordBackup(context.Background(), store, entry); err != nil {
+		return wrapError(err, "backup written but failed to update manifest in '%s'", store.location())
+	}
+
+	record := backupRecord{
+		Database:    dbName,
+		Path:        outputFile,
+		Format:      opts.Format,
+		Compression: opts.Compression,
+		Encryption:  opts.Encryption,
+		SizeBytes:   size,
+		Checksum:    checksum,
+	}
+	if ok, err := renderOutput(record); ok {
+		return err
+	}
+
+	color.Green("✓ Database '%s' backed up to '%s' (%s)", dbName, outputFile, formatBytes(size))
+	return nil
+}
+
+// dumpToFile streams a dump into outputFile and returns its checksum,
+// removing the partial file if pg_dump fails.
+func dumpToFile(dbName, outputFile string, opts backupOptions) (string, error) {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return "", err
+	}
+
+	h := newChecksum()
+	if err := streamDump(dbName, io.MultiWriter(file, h), opts); err != nil {
+		file.Close()
+		os.Remove(outputFile)
+		return "", err
+	}
+	return formatChecksum(h), file.Close()
+}
+
+// pathSize returns the size of a file, or the total size of the files in a
+// directory archive.
+func pathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func restoreDatabase(dbName, backupFile string, opts restoreOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(backupFile)
+	if os.IsNotExist(err) {
+		return notFoundError("backup file '%s' not found", backupFile)
+	} else if err != nil {
+		return wrapError(err, "failed to read backup '%s'", backupFile)
+	}
+
+	var format string
+	if info.IsDir() {
+		if _, err := os.Stat(filepath.Join(backupFile, "toc.dat")); err != nil {
+			return usageError("'%s' is not a directory archive (no toc.dat)", backupFile)
+		}
+		format = formatDirectory
+	}
+
+	// First, create the database if it doesn't exist
+	if err := ensureDatabase(dbName); err != nil {
+		return err
+	}
+
+	if format == formatDirectory {
+		printProgress("Restoring database '%s' from '%s' (directory)...", dbName, backupFile)
+		args := append(pgRestoreArgs(dbName, formatDirectory, opts), backupFile)
+		if err := runTool(pgTool("pg_restore", args...)); err != nil {
+			return wrapError(err, "failed to restore database '%s'", dbName)
+		}
+	} else {
+		file, err := os.Open(backupFile)
+		if err != nil {
+			return wrapError(err, "failed to open backup '%s'", backupFile)
+		}
+		defer file.Close()
+
+		if format, err = restoreStream(dbName, backupFile, backupFile, file, opts); err != nil {
+			return wrapError(err, "failed to restore database '%s'", dbName)
+		}
+	}
+
+	return reportAction("restore", dbName, "restored", "✓ Database '%s' restored from '%s' (%s)", dbName, backupFile, format)
+}
+
+// restoreStream detects the encryption, compression and format of a backup
+// read from r and feeds it to pg_restore or psql. When the backup is an
+// unencrypted, uncompressed custom archive at localPath, pg_restore reads
+// the file itself so --jobs works. source only names the backup in
+// messages. It returns the detected format.
+func restoreStream(dbName, source, localPath string, r io.Reader, opts restoreOptions) (string, error) {
+	encryption, decrypted, err := detectEncryption(bufio.NewReader(r), opts.KeyFile)
+	if err != nil {
+		return "", err
+	}
+	compression, reader, err := detectCompression(decrypted)
+	if err != nil {
+		return "", err
+	}
+	format, err := detectFormat(reader)
+	if err != nil {
+		return "", err
+	}
+
+	detected := backupOptions{Format: format, Compression: compression, Encryption: encryption}
+	printProgress("Restoring database '%s' from '%s' (%s)...", dbName, source, describeBackup(detected))
+
+	if format == formatPlain {
+		if opts.Clean {
+			return format, usageError("--clean only applies to custom, directory and tar archives; dump with pg_dump --clean instead")
+		}
+		if opts.Jobs > 1 {
+			return format, usageError("--jobs only applies to custom and directory archives")
+		}
+		// Stop at the first failed statement so a broken script exits non-zero
+		args := []string{"-d", dbName, "-X", "-q", "-v", "ON_ERROR_STOP=1"}
+		if opts.SingleTransaction {
+			args = append(args, "--single-transaction")
+		}
+		cmd := pgTool("psql", args...)
+		cmd.Stdin = reader
+		cmd.Stdout = io.Discard
+		return format, runTool(cmd)
+	}
+
+	args := pgRestoreArgs(dbName, format, opts)
+	if format == formatCustom && compression == compressNone && encryption == encryptNone && localPath != "" {
+		return format, runTool(pgTool("pg_restore", append(args, localPath)...))
+	}
+
+	if opts.Jobs > 1 {
+		return format, usageError("--jobs needs an unencrypted, uncompressed custom archive on disk or a directory archive")
+	}
+	cmd := pgTool("pg_restore", args...)
+	cmd.Stdin = reader
+	return format, runTool(cmd)
+}
+
+func pgRestoreArgs(dbName, format string, opts restoreOptions) []string {
+	args := []string{"-d", dbName, "--no-owner", "--no-acl", "--format", format}
+	if opts.Clean {
+		args = append(args, "--clean")
+	}
+	if opts.IfExists {
+		args = append(args, "--if-exists")
+	}
+	if opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	if opts.Jobs > 1 {
+		args = append(args, "--jobs", strconv.Itoa(opts.Jobs))
+	}
+	return args
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// detectCompression sniffs the first bytes of r and returns a reader of the
+// decompressed stream.
+func detectCompression(r *bufio.Reader) (string, *bufio.Reader, error) {
+	head, err := r.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return "", nil, err
+		}
+		return compressGzip, bufio.NewReader(gz), nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return "", nil, err
+		}
+		return compressZstd, bufio.NewReader(zr.IOReadCloser()), nil
+	}
+	return compressNone, r, nil
+}
+
+// detectFormat tells custom archives (PGDMP magic) and tar archives (ustar
+// header) apart from plain SQL scripts.
+func detectFormat(r *bufio.Reader) (string, error) {
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PGDMP")):
+		return formatCustom, nil
+	case len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return formatTar, nil
+	}
+	return formatPlain, nil
+}
+
+func compressWriter(w io.Writer, method string) (io.WriteCloser, error) {
+	switch method {
+	case compressGzip:
+		return gzip.NewWriter(w), nil
+	case compressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/minio/minio-go/v7"
+)
+
+// backupPartSize is the multipart chunk size for streamed backups. The size
+// of a dump is unknown up front, so minio-go would otherwise size parts for
+// the 5 TiB object limit and buffer that much per part.
+const backupPartSize = 16 << 20
+
+// Object metadata recorded with every streamed backup.
+const (
+	metaDatabase      = "Database"
+	metaServerVersion = "Server-Version"
+	metaCreatedAt     = "Created-At"
+	metaFormat        = "Format"
+	metaCompression   = "Compression"
+	metaEncryption    = "Encryption"
+)
+
+// parseS3URL splits s3://bucket/key into its bucket and key.
+func parseS3URL(raw string) (bucket, key string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", usageError("invalid S3 location '%s' (expected s3://bucket/key)", raw)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// serverVersion returns the version of the PostgreSQL server hosting dbName.
+func serverVersion(ctx context.Context, dbName string) (string, error) {
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx)
+
+	var version string
+	if err := conn.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		return "", wrapError(err, "failed to read server version")
+	}
+	return version, nil
+}
+
+// backupObjectKey picks the object key for a backup sent to prefix. A prefix
+// ending in "/" gets name appended, or a timestamped name when name is empty.
+func backupObjectKey(prefix, name, dbName string, opts backupOptions) string {
+	key := prefix
+	if key == "" || strings.HasSuffix(key, "/") {
+		if name == "" {
+			name = timestampedName(dbName, time.Now())
+		}
+		key += name
+	}
+	return backupPath(key, opts)
+}
+
+// timestampedName is the default name of a backup taken at t.
+func timestampedName(dbName string, t time.Time) string {
+	return fmt.Sprintf("%s_%s", dbName, t.UTC().Format("20060102T150405Z"))
+}
+
+// backupToS3 streams pg_dump output through a multipart upload, so the dump
+// never touches local disk.
+func backupToS3(dbName, target, name string, opts backupOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if !opts.streamed() {
+		return usageError("directory archives cannot be streamed to S3, use --format custom or tar")
+	}
+
+	bucket, prefix, err := parseS3URL(target)
+	if err != nil {
+		return err
+	}
+	key := backupObjectKey(prefix, name, dbName, opts)
+	location := fmt.Sprintf("s3://%s/%s", bucket, key)
+
+	ctx := context.Background()
+	version, err := serverVersion(ctx, dbName)
+	if err != nil {
+		return err
+	}
+
+	client, err := getMinIOClient()
+	if err != nil {
+		return connectionError("failed to connect to MinIO: %w", err)
+	}
+
+	printProgress("Streaming backup of database '%s' to '%s' (%s)...", dbName, location, describeBackup(opts))
+
+	reader, writer := io.Pipe()
+	dumpDone := make(chan error, 1)
+	go func() {
+		err := streamDump(dbName, writer, opts)
+		// Report before closing the pipe so the upload never sees the
+		// end of the dump before dumpDone does.
+		dumpDone <- err
+		writer.CloseWithError(err)
+	}()
+
+	h := newChecksum()
+	info, uploadErr := client.PutObject(ctx, bucket, key, io.TeeReader(reader, h), -1, minio.PutObjectOptions{
+		PartSize:    backupPartSize,
+		ContentType: "application/octet-stream",
+		UserMetadata: map[string]string{
+			metaDatabase:      dbName,
+			metaServerVersion: version,
+			metaCreatedAt:     time.Now().UTC().Format(time.RFC3339),
+			metaFormat:        opts.Format,
+			metaCompression:   opts.Compression,
+			metaEncryption:    opts.Encryption,
+		},
+	})
+	select {
+	case dumpErr := <-dumpDone:
+		// The dump ended first, so a failed dump is what broke the upload.
+		if dumpErr != nil {
+			return wrapError(dumpErr, "failed to back up database '%s'", dbName)
+		}
+	default:
+		// The upload gave up while pg_dump was still writing; stop it.
+		reader.CloseWithError(uploadErr)
+		<-dumpDone
+	}
+	if uploadErr != nil {
+		return wrapError(uploadErr, "failed to upload backup to '%s'", location)
+	}
+
+	checksum := formatChecksum(h)
+	store := &s3Store{client: client, bucket: bucket, prefix: path.Dir(key) + "/"}
+	if !strings.Contains(key, "/") {
+		store.prefix = ""
+	}
+	entry := backupEntry{
+		Database:    dbName,
+		Name:        path.Base(key),
+		Format:      opts.Format,
+		Compression: opts.Compression,
+		Encryption:  opts.Encryption,
+		SizeBytes:   info.Size,
+		Checksum:    checksum,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := recordBackup(ctx, store, entry); err != nil {
+		return wrapError(err, "backup uploaded but failed to update manifest in '%s'", store.location())
+	}
+
+	record := backupRecord{
+		Database:    dbName,
+		Path:        location,
+		Format:      opts.Format,
+		Compression: opts.Compression,
+		Encryption:  opts.Encryption,
+		SizeBytes:   info.Size,
+		Checksum:    checksum,
+	}
+	if ok, err := renderOutput(record); ok {
+		return err
+	}
+
+	color.Green("✓ Database '%s' streamed to '%s' (%s)", dbName, location, formatBytes(info.Size))
+	return nil
+}
+
+// restoreFromS3 streams a backup object into pg_restore or psql without a
+// temporary file.
+func restoreFromS3(dbName, source string, opts restoreOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	bucket, key, err := parseS3URL(source)
+	if err != nil {
+		return err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return usageError("'%s' names a prefix, not a backup object", source)
+	}
+
+	client, err := getMinIOClient()
+	if err != nil {
+		return connectionError("failed to connect to MinIO: %w", err)
+	}
+
+	ctx := context.Background()
+	stat, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return wrapError(err, "failed to find backup '%s'", source)
+	}
+	if database := stat.UserMetadata[metaDatabase]; database != "" {
+		printProgress("Backup of '%s' (PostgreSQL %s) taken %s, %s",
+			database, stat.UserMetadata[metaServerVersion], stat.UserMetadata[metaCreatedAt], formatBytes(stat.Size))
+	}
+
+	// First, create the database if it doesn't exist
+	if err := ensureDatabase(dbName); err != nil {
+		return err
+	}
+
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return wrapError(err, "failed to download backup '%s'", source)
+	}
+	defer object.Close()
+
+	format, err := restoreStream(dbName, source, "", object, opts)
+	if err != nil {
+		return wrapError(err, "failed to restore database '%s'", dbName)
+	}
+
+	return reportAction("restore", dbName, "restored", "✓ Database '%s' restored from '%s' (%s)", dbName, source, format)
+}
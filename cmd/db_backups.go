@@ -0,0 +1,439 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/cobra"
+)
+
+var dbBackupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage the catalog of database backups",
+	Long: `List, verify and prune backups recorded in the manifest.json that db backup
+keeps next to its backups, in a local directory or a MinIO prefix.
+
+The location defaults to backups.location in the config file.`,
+}
+
+var dbBackupsListCmd = &cobra.Command{
+	Use:   "list [location]",
+	Short: "List backups recorded in a manifest",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _ := cmd.Flags().GetString("database")
+		return listBackups(backupLocation(args), database)
+	},
+}
+
+var dbBackupsVerifyCmd = &cobra.Command{
+	Use:   "verify [location]",
+	Short: "Check that recorded backups exist and match their size and checksum",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _ := cmd.Flags().GetString("database")
+		return verifyBackups(backupLocation(args), database)
+	},
+}
+
+var dbBackupsPruneCmd = &cobra.Command{
+	Use:   "prune [location]",
+	Short: "Delete backups outside the retention policy",
+	Long: `Delete backups that no retention rule keeps. Rules apply per database and a
+backup is kept when any rule selects it:
+
+  --keep-last N      the N most recent backups
+  --keep-daily N     the newest backup of each of the last N days with backups
+  --keep-weekly N    the newest backup of each of the last N ISO weeks
+  --keep-monthly N   the newest backup of each of the last N months
+
+Rules default to backups.retention.keep_last, keep_daily, keep_weekly and
+keep_monthly in the config file. Only backups listed in the manifest are
+ever deleted. Use --dry-run to preview.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _ := cmd.Flags().GetString("database")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		policy := retentionPolicy{
+			Last:    retentionFlag(cmd, "keep-last", "backups.retention.keep_last"),
+			Daily:   retentionFlag(cmd, "keep-daily", "backups.retention.keep_daily"),
+			Weekly:  retentionFlag(cmd, "keep-weekly", "backups.retention.keep_weekly"),
+			Monthly: retentionFlag(cmd, "keep-monthly", "backups.retention.keep_monthly"),
+		}
+		return pruneBackups(backupLocation(args), database, policy, dryRun)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{dbBackupsListCmd, dbBackupsVerifyCmd, dbBackupsPruneCmd} {
+		c.Flags().String("database", "", "Only consider backups of this database")
+		dbBackupsCmd.AddCommand(c)
+	}
+
+	dbBackupsPruneCmd.Flags().Int("keep-last", 0, "Keep the N most recent backups")
+	dbBackupsPruneCmd.Flags().Int("keep-daily", 0, "Keep the newest backup of the last N days")
+	dbBackupsPruneCmd.Flags().Int("keep-weekly", 0, "Keep the newest backup of the last N weeks")
+	dbBackupsPruneCmd.Flags().Int("keep-monthly", 0, "Keep the newest backup of the last N months")
+	dbBackupsPruneCmd.Flags().Bool("dry-run", false, "Show what would be deleted without deleting")
+
+	dbCmd.AddCommand(dbBackupsCmd)
+}
+
+type backupVerifyRecord struct {
+	Database string `json:"database"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail"`
+}
+
+type pruneRecord struct {
+	Database  string    `json:"database"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	SizeBytes int64     `json:"size_bytes"`
+	Action    string    `json:"action"`
+	Reason    string    `json:"reason"`
+}
+
+// retentionPolicy holds keep-last and grandfather-father-son rules.
+type retentionPolicy struct {
+	Last    int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+func (p retentionPolicy) empty() bool {
+	return p.Last <= 0 && p.Daily <= 0 && p.Weekly <= 0 && p.Monthly <= 0
+}
+
+// keep returns, for the backups of one database, why each kept backup is
+// kept, keyed by name. Backups missing from the result have expired.
+func (p retentionPolicy) keep(entries []backupEntry) map[string]string {
+	newestFirst := make([]backupEntry, len(entries))
+	copy(newestFirst, entries)
+	sortNewestFirst(newestFirst)
+
+	reasons := make(map[string]string)
+	mark := func(name, reason string) {
+		if _, ok := reasons[name]; !ok {
+			reasons[name] = reason
+		}
+	}
+
+	for i, entry := range newestFirst {
+		if i < p.Last {
+			mark(entry.Name, "last")
+		}
+	}
+
+	periods := []struct {
+		reason string
+		count  int
+		period func(time.Time) string
+	}{
+		{"daily", p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{"monthly", p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, rule := range periods {
+		seen := make(map[string]bool)
+		for _, entry := range newestFirst {
+			if len(seen) >= rule.count {
+				break
+			}
+			key := rule.period(entry.CreatedAt.UTC())
+			if !seen[key] {
+				seen[key] = true
+				mark(entry.Name, rule.reason)
+			}
+		}
+	}
+	return reasons
+}
+
+func sortNewestFirst(entries []backupEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+}
+
+// retentionFlag reads a retention flag, falling back to the config file
+// when the flag was not given.
+func retentionFlag(cmd *cobra.Command, flag, key string) int {
+	if cmd.Flags().Changed(flag) {
+		value, _ := cmd.Flags().GetInt(flag)
+		return value
+	}
+	return settingInt(key)
+}
+
+func backupLocation(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return settingString("backups.location")
+}
+
+// loadManifest opens the store at location and reads its manifest, keeping
+// only the backups of database when it is set.
+func loadManifest(ctx context.Context, location, database string) (backupStore, *backupManifest, []backupEntry, error) {
+	if location == "" {
+		return nil, nil, nil, usageError("no backup location given and backups.location is not set in the config file")
+	}
+
+	store, err := openBackupStore(location)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	manifest, err := store.readManifest(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "failed to read manifest in '%s'", store.location())
+	}
+
+	entries := []backupEntry{}
+	for _, entry := range manifest.Backups {
+		if database == "" || entry.Database == database {
+			entries = append(entries, entry)
+		}
+	}
+	return store, manifest, entries, nil
+}
+
+func listBackups(location, database string) error {
+	ctx := context.Background()
+	store, _, entries, err := loadManifest(ctx, location, database)
+	if err != nil {
+		return err
+	}
+	sortNewestFirst(entries)
+
+	if ok, err := renderOutput(entries); ok {
+		return err
+	}
+
+	if len(entries) == 0 {
+		color.Yellow("No backups recorded in '%s'", store.location())
+		return nil
+	}
+
+	color.Green("Backups in '%s':", store.location())
+	var rows [][]string
+	var total int64
+	for _, entry := range entries {
+		encryption := entry.Encryption
+		if encryption == "" {
+			encryption = encryptNone
+		}
+		rows = append(rows, []string{
+			entry.Name,
+			entry.Database,
+			entry.Format,
+			entry.Compression,
+			encryption,
+			formatBytes(entry.SizeBytes),
+			entry.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		})
+		total += entry.SizeBytes
+	}
+	if err := writeTable(os.Stdout, []string{"name", "database", "format", "compression", "encryption", "size", "created"}, rows); err != nil {
+		return err
+	}
+	fmt.Println()
+	color.Yellow("Total: %d backups, %s", len(entries), formatBytes(total))
+	return nil
+}
+
+func verifyBackups(location, database string) error {
+	ctx := context.Background()
+	store, _, entries, err := loadManifest(ctx, location, database)
+	if err != nil {
+		return err
+	}
+
+	results := []backupVerifyRecord{}
+	failed := 0
+	for _, entry := range entries {
+		result := verifyBackup(ctx, store, entry)
+		if result.Status != "ok" {
+			failed++
+		}
+		results = append(results, result)
+
+		if outputFormat() == outputText {
+			if result.Status == "ok" {
+				color.Green("✓ %s", entry.Name)
+			} else {
+				color.Red("✗ %s: %s", entry.Name, result.Detail)
+			}
+		}
+	}
+
+	if ok, err := renderOutput(results); ok && err != nil {
+		return err
+	}
+	if failed > 0 {
+		return newError(KindGeneral, "%d of %d backups failed verification", failed, len(results))
+	}
+	if outputFormat() == outputText {
+		color.Green("All %d backups verified", len(results))
+	}
+	return nil
+}
+
+func verifyBackup(ctx context.Context, store backupStore, entry backupEntry) backupVerifyRecord {
+	result := backupVerifyRecord{Database: entry.Database, Name: entry.Name, Status: "ok"}
+
+	size, err := store.size(ctx, entry.Name)
+	if isMissing(err) {
+		result.Status, result.Detail = "missing", "backup not found"
+		return result
+	} else if err != nil {
+		result.Status, result.Detail = "error", err.Error()
+		return result
+	}
+	if size != entry.SizeBytes {
+		result.Status = "size_mismatch"
+		result.Detail = fmt.Sprintf("size is %d bytes, manifest says %d", size, entry.SizeBytes)
+		return result
+	}
+
+	checksum, err := store.checksum(ctx, entry.Name)
+	if err != nil {
+		result.Status, result.Detail = "error", err.Error()
+		return result
+	}
+	if checksum != entry.Checksum {
+		result.Status = "checksum_mismatch"
+		result.Detail = fmt.Sprintf("checksum is %s, manifest says %s", checksum, entry.Checksum)
+	}
+	return result
+}
+
+func isMissing(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NoSuchObject"
+}
+
+func pruneBackups(location, database string, policy retentionPolicy, dryRun bool) error {
+	if policy.empty() {
+		return usageError("no retention rule given, use --keep-last, --keep-daily, --keep-weekly or --keep-monthly " +
+			"(or backups.retention in the config file)")
+	}
+
+	ctx := context.Background()
+	store, manifest, entries, err := loadManifest(ctx, location, database)
+	if err != nil {
+		return err
+	}
+
+	byDatabase := make(map[string][]backupEntry)
+	for _, entry := range entries {
+		byDatabase[entry.Database] = append(byDatabase[entry.Database], entry)
+	}
+
+	records := []pruneRecord{}
+	for _, group := range byDatabase {
+		reasons := policy.keep(group)
+		for _, entry := range group {
+			record := pruneRecord{
+				Database:  entry.Database,
+				Name:      entry.Name,
+				CreatedAt: entry.CreatedAt,
+				SizeBytes: entry.SizeBytes,
+				Action:    "keep",
+				Reason:    reasons[entry.Name],
+			}
+			if record.Reason == "" {
+				record.Action = "delete"
+				record.Reason = "expired"
+			}
+			records = append(records, record)
+		}
+	}
+	sortPruneRecords(records)
+
+	var deleted int
+	var freed int64
+	var deleteErr error
+	if !dryRun {
+		for i, record := range records {
+			if record.Action != "delete" {
+				continue
+			}
+			err := store.remove(ctx, record.Name)
+			if err != nil && !isMissing(err) {
+				deleteErr = wrapError(err, "failed to delete backup '%s'", record.Name)
+				break
+			}
+			manifest.remove(record.Name)
+			records[i].Action = "deleted"
+			deleted++
+			freed += record.SizeBytes
+		}
+		if deleted > 0 {
+			if err := store.writeManifest(ctx, manifest); err != nil {
+				return wrapError(err, "failed to update manifest in '%s'", store.location())
+			}
+		}
+	}
+
+	if ok, err := renderOutput(records); ok {
+		if err != nil {
+			return err
+		}
+		return deleteErr
+	}
+
+	for _, record := range records {
+		switch record.Action {
+		case "keep":
+			fmt.Printf("  keep    %s (%s)\n", record.Name, record.Reason)
+		case "delete":
+			color.Yellow("  delete  %s (would be deleted)", record.Name)
+		case "deleted":
+			color.Red("  deleted %s", record.Name)
+		}
+	}
+	if deleteErr != nil {
+		return deleteErr
+	}
+
+	if dryRun {
+		expired := 0
+		var size int64
+		for _, record := range records {
+			if record.Action == "delete" {
+				expired++
+				size += record.SizeBytes
+			}
+		}
+		color.Yellow("Dry run: %d backups (%s) would be deleted from '%s'", expired, formatBytes(size), store.location())
+		return nil
+	}
+	color.Green("✓ Deleted %d backups, freed %s", deleted, formatBytes(freed))
+	return nil
+}
+
+// sortPruneRecords groups records by database, newest first.
+func sortPruneRecords(records []pruneRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Database != records[j].Database {
+			return records[i].Database < records[j].Database
+		}
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+}
@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbBrowseCmd = &cobra.Command{
+	Use:   "browse [database-name] [table-name]",
+	Short: "Page through the rows of a table, optionally in an interactive view",
+	Long: `Show a page of rows from a table or view, filtered with --where (an SQL
+condition), sorted with --order-by ("column [asc|desc], ...") and limited to
+--columns. Paging is keyset based, so pages stay fast deep into large tables:
+every page ends with --after and --before cursors for the next and previous
+page. Rows are ordered by the primary key after the --order-by columns, so
+paging never skips or repeats rows; views without one are paged by their
+order columns alone.
+
+With --interactive the rows open in a full-screen view:
+
+  arrows, hjkl      move between rows and columns
+  n, space / p, b   next / previous page; g first page
+  s                 sort by the current column, again to reverse
+  c                 choose the columns to show
+  w                 wrap long values instead of cutting them off
+  enter             show the current row with every value in full
+  q, esc            quit
+
+The session is read-only, so --where cannot change data.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts browseOptions
+		opts.Where, _ = cmd.Flags().GetString("where")
+		opts.OrderBy, _ = cmd.Flags().GetString("order-by")
+		opts.Columns, _ = cmd.Flags().GetString("columns")
+		opts.Limit, _ = cmd.Flags().GetInt("limit")
+		opts.After, _ = cmd.Flags().GetString("after")
+		opts.Before, _ = cmd.Flags().GetString("before")
+		opts.Interactive, _ = cmd.Flags().GetBool("interactive")
+		return browseTable(args[0], args[1], opts)
+	},
+}
+
+func init() {
+	dbBrowseCmd.Flags().String("where", "", "SQL condition rows must match")
+	dbBrowseCmd.Flags().String("order-by", "", "Sort columns, e.g. \"created_at desc, name\"")
+	dbBrowseCmd.Flags().String("columns", "", "Comma-separated columns to show (default all)")
+	dbBrowseCmd.Flags().IntP("limit", "l", 50, "Rows per page")
+	dbBrowseCmd.Flags().String("after", "", "Show the page after this cursor")
+	dbBrowseCmd.Flags().String("before", "", "Show the page before this cursor")
+	dbBrowseCmd.Flags().BoolP("interactive", "i", false, "Open the interactive view")
+	dbCmd.AddCommand(dbBrowseCmd)
+}
+
+type browseOptions struct {
+	Where       string
+	OrderBy     string
+	Columns     string
+	Limit       int
+	After       string
+	Before      string
+	Interactive bool
+}
+
+// orderKey is one column of the keyset a table is paged by.
+type orderKey struct {
+	Column  string
+	SQLType string
+	Desc    bool
+}
+
+// tableBrowser fetches pages of a table in a fixed order.
+type tableBrowser struct {
+	conn      *pgx.Conn
+	label     string
+	qualified string
+	columns   []columnRecord
+	// Columns fetched, in table order
+	selected []string
+	where    string
+	// order is the requested sort, keys adds the tiebreakers that make it
+	// unique
+	order    []orderKey
+	keys     []orderKey
+	unique   []orderKey
+	pageSize int
+}
+
+type browsePage struct {
+	Rows *resultSet
+	// Key values of each row, as text
+	keys    [][]*string
+	HasNext bool
+	HasPrev bool
+}
+
+// browseRecord is a page in structured output.
+type browseRecord struct {
+	Rows       *resultSet `json:"rows"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+}
+
+// TableView renders only the rows in table and CSV output.
+func (b browseRecord) TableView() any {
+	return b.Rows
+}
+
+func (b *tableBrowser) column(name string) (columnRecord, bool) {
+	for _, column := range b.columns {
+		if column.Column == name {
+			return column, true
+		}
+	}
+	// Unquoted names fold to lower case in PostgreSQL
+	for _, column := range b.columns {
+		if column.Column == strings.ToLower(name) {
+			return column, true
+		}
+	}
+	return columnRecord{}, false
+}
+
+// parseOrderBy reads "column [asc|desc], ..." into order keys.
+func (b *tableBrowser) parseOrderBy(spec string) ([]orderKey, error) {
+	var order []orderKey
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, usageError("invalid --order-by '%s' (expected column [asc|desc])", strings.TrimSpace(part))
+		}
+		column, ok := b.column(fields[0])
+		if !ok {
+			return nil, usageError("table %s has no column '%s'", b.label, fields[0])
+		}
+		key := orderKey{Column: column.Column, SQLType: column.SQLType}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, usageError("invalid sort direction '%s' (valid: asc, desc)", fields[1])
+			}
+		}
+		order = append(order, key)
+	}
+	return order, nil
+}
+
+// setOrder sorts by order, then by the tiebreaker keys not already in it.
+func (b *tableBrowser) setOrder(order []orderKey) {
+	b.order = order
+	b.keys = slices.Clone(order)
+	for _, key := range b.unique {
+		if !slices.ContainsFunc(order, func(k orderKey) bool { return k.Column == key.Column }) {
+			b.keys = append(b.keys, key)
+		}
+	}
+}
+
+// keysetCondition selects the rows after cursor in the key order, or before
+// it when backward. NULLs sort last ascending and first descending, as
+// PostgreSQL does by default, so a backward page is the exact reverse.
+func (b *tableBrowser) keysetCondition(cursor []*string, backward bool, args *[]any) string {
+	param := func(value string, key orderKey) string {
+		*args = append(*args, value)
+		return fmt.Sprintf("$%d::%s", len(*args), key.SQLType)
+	}
+	var terms []string
+	for i, key := range b.keys {
+		desc := key.Desc != backward
+		if !desc && cursor[i] == nil {
+			continue // nothing sorts after NULL
+		}
+		conditions := []string{}
+		for j, previous := range b.keys[:i] {
+			previousColumn := pgx.Identifier{previous.Column}.Sanitize()
+			if cursor[j] == nil {
+				conditions = append(conditions, previousColumn+" IS NULL")
+			} else {
+				conditions = append(conditions, previousColumn+" = "+param(*cursor[j], previous))
+			}
+		}
+
+		column := pgx.Identifier{key.Column}.Sanitize()
+		var after string
+		switch {
+		case !desc:
+			after = fmt.Sprintf("(%s > %s OR %s IS NULL)", column, param(*cursor[i], key), column)
+		case cursor[i] == nil:
+			after = column + " IS NOT NULL"
+		default:
+			after = fmt.Sprintf("%s < %s", column, param(*cursor[i], key))
+		}
+		terms = append(terms, "("+strings.Join(append(conditions, after), " AND ")+")")
+	}
+	if len(terms) == 0 {
+		return "FALSE"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// fetch reads the page after cursor, the first page when it is nil, or the
+// page before it when backward.
+func (b *tableBrowser) fetch(ctx context.Context, cursor []*string, backward bool) (*browsePage, error) {
+	var selectList, orderBy, conditions []string
+	for _, name := range b.selected {
+		selectList = append(selectList, pgx.Identifier{name}.Sanitize())
+	}
+	for i, key := range b.keys {
+		column := pgx.Identifier{key.Column}.Sanitize()
+		selectList = append(selectList, fmt.Sprintf("%s::text AS \"tools_key_%d\"", column, i))
+		if key.Desc != backward {
+			orderBy = append(orderBy, column+" DESC NULLS FIRST")
+		} else {
+			orderBy = append(orderBy, column+" ASC NULLS LAST")
+		}
+	}
+
+	var args []any
+	if b.where != "" {
+		conditions = append(conditions, "("+b.where+")")
+	}
+	if cursor != nil {
+		conditions = append(conditions, b.keysetCondition(cursor, backward, &args))
+	}
+	query := "SELECT " + strings.Join(selectList, ", ") + " FROM " + b.qualified
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, b.pageSize+1)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d", strings.Join(orderBy, ", "), len(args))
+
+	result, err := queryResultSet(ctx, b.conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	more := len(result.Values) > b.pageSize
+	if more {
+		result.Values = result.Values[:b.pageSize]
+	}
+	if backward {
+		slices.Reverse(result.Values)
+	}
+
+	page := &browsePage{Rows: &resultSet{Columns: result.Columns[:len(b.selected)]}}
+	for _, values := range result.Values {
+		page.Rows.Values = append(page.Rows.Values, values[:len(b.selected)])
+		key := make([]*string, len(b.keys))
+		for i, value := range values[len(b.selected):] {
+			if text, ok := value.(string); ok {
+				key[i] = &text
+			}
+		}
+		page.keys = append(page.keys, key)
+	}
+	if backward {
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		page.HasNext, page.HasPrev = more, cursor != nil
+	}
+	return page, nil
+}
+
+func encodeCursor(key []*string) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (b *tableBrowser) decodeCursor(cursor string) ([]*string, error) {
+	var key []*string
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &key)
+	}
+	if err != nil || len(key) != len(b.keys) {
+		return nil, usageError("invalid cursor, cursors only work with the --order-by they were printed with")
+	}
+	return key, nil
+}
+
+// newTableBrowser checks the table, columns and order of a browse.
+func newTableBrowser(ctx context.Context, conn *pgx.Conn, table string, opts browseOptions) (*tableBrowser, error) {
+	schema, name, err := parseQualifiedName(table)
+	if err != nil {
+		return nil, err
+	}
+	b := &tableBrowser{
+		conn:      conn,
+		label:     erdLabel(schema, name),
+		qualified: qualifiedName(schema, name),
+		where:     strings.TrimSpace(opts.Where),
+		pageSize:  opts.Limit,
+	}
+	if b.columns, err = tableColumns(ctx, conn, schema, name); err != nil {
+		return nil, wrapError(err, "failed to read columns of %s", b.label)
+	}
+	if len(b.columns) == 0 {
+		return nil, notFoundError("table %s not found", b.label)
+	}
+
+	for _, column := range b.columns {
+		b.selected = append(b.selected, column.Column)
+	}
+	if opts.Columns != "" {
+		b.selected = nil
+		for _, name := range strings.Split(opts.Columns, ",") {
+			column, ok := b.column(strings.TrimSpace(name))
+			if !ok {
+				return nil, usageError("table %s has no column '%s'", b.label, strings.TrimSpace(name))
+			}
+			if !slices.Contains(b.selected, column.Column) {
+				b.selected = append(b.selected, column.Column)
+			}
+		}
+	}
+
+	// The primary key, or the row's physical position for tables without
+	// one, makes the order unique
+	primaryKey, err := primaryKeyColumns(ctx, conn, b.qualified)
+	if err != nil {
+		return nil, wrapError(err, "failed to read primary key of %s", b.label)
+	}
+	for _, name := range primaryKey {
+		column, _ := b.column(name)
+		b.unique = append(b.unique, orderKey{Column: column.Column, SQLType: column.SQLType})
+	}
+	if len(b.unique) == 0 {
+		var kind string
+		if err := conn.QueryRow(ctx, "SELECT relkind::text FROM pg_class WHERE oid = $1::regclass", b.qualified).Scan(&kind); err != nil {
+			return nil, wrapError(err, "failed to look up %s", b.label)
+		}
+		if kind == "r" || kind == "m" {
+			b.unique = []orderKey{{Column: "ctid", SQLType: "tid"}}
+		}
+	}
+
+	order, err := b.parseOrderBy(opts.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) == 0 && len(b.unique) == 0 {
+		return nil, usageError("%s has no primary key, pass --order-by", b.label)
+	}
+	b.setOrder(order)
+	return b, nil
+}
+
+func browseTable(dbName, table string, opts browseOptions) error {
+	if opts.Limit < 1 {
+		return usageError("--limit must be at least 1")
+	}
+	if opts.After != "" && opts.Before != "" {
+		return usageError("--after and --before cannot be combined")
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	// Browsing never writes, whatever --where calls
+	if _, err := conn.Exec(ctx, "SET default_transaction_read_only = on"); err != nil {
+		return wrapError(err, "failed to make the session read-only")
+	}
+	b, err := newTableBrowser(ctx, conn, table, opts)
+	if err != nil {
+		return err
+	}
+
+	var cursor []*string
+	backward := opts.Before != ""
+	if opts.After != "" || opts.Before != "" {
+		if cursor, err = b.decodeCursor(opts.After + opts.Before); err != nil {
+			return err
+		}
+	}
+
+	if opts.Interactive {
+		if outputFormat() != outputText {
+			return usageError("--interactive cannot be combined with --output %s", outputFormat())
+		}
+		return b.runInteractive(ctx, cursor, backward)
+	}
+
+	page, err := b.fetch(ctx, cursor, backward)
+	if err != nil {
+		return wrapError(err, "failed to read %s", b.label)
+	}
+	record := browseRecord{Rows: page.Rows}
+	if page.HasNext && len(page.keys) > 0 {
+		record.NextCursor = encodeCursor(page.keys[len(page.keys)-1])
+	}
+	if page.HasPrev && len(page.keys) > 0 {
+		record.PrevCursor = encodeCursor(page.keys[0])
+	}
+	if ok, err := renderOutput(record); ok {
+		return err
+	}
+
+	if len(page.Rows.Values) == 0 {
+		fmt.Println("(0 rows)")
+		return nil
+	}
+	rows := page.Rows.Rows()
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = clipCell(cell, 60)
+		}
+	}
+	if err := writeTable(os.Stdout, page.Rows.Columns, rows); err != nil {
+		return err
+	}
+	fmt.Printf("(%d rows)\n", len(rows))
+	if record.NextCursor != "" {
+		color.Cyan("Next page:     --after %s", record.NextCursor)
+	}
+	if record.PrevCursor != "" {
+		color.Cyan("Previous page: --before %s", record.PrevCursor)
+	}
+	return nil
+}
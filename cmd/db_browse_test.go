@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	value := func(s string) *string { return &s }
+	b := &tableBrowser{keys: []orderKey{
+		{Column: "created_at", SQLType: "timestamp", Desc: true},
+		{Column: "name", SQLType: "text"},
+		{Column: "id", SQLType: "integer"},
+	}}
+	tests := []struct {
+		name     string
+		cursor   []*string
+		backward bool
+		want     string
+		args     []any
+	}{
+		{
+			name:   "forward",
+			cursor: []*string{value("2024-01-01"), nil, value("7")},
+			want:   `(("created_at" < $1::timestamp) OR ("created_at" = $2::timestamp AND "name" IS NULL AND ("id" > $3::integer OR "id" IS NULL)))`,
+			args:   []any{"2024-01-01", "2024-01-01", "7"},
+		},
+		{
+			name:     "backward",
+			cursor:   []*string{value("2024-01-01"), nil, value("7")},
+			backward: true,
+			want: `((("created_at" > $1::timestamp OR "created_at" IS NULL)) OR ("created_at" = $2::timestamp AND "name" IS NOT NULL)` +
+				` OR ("created_at" = $3::timestamp AND "name" IS NULL AND "id" < $4::integer))`,
+			args: []any{"2024-01-01", "2024-01-01", "2024-01-01", "7"},
+		},
+		{
+			name:   "null first key",
+			cursor: []*string{nil, value("bob"), value("7")},
+			want: `(("created_at" IS NOT NULL) OR ("created_at" IS NULL AND ("name" > $1::text OR "name" IS NULL))` +
+				` OR ("created_at" IS NULL AND "name" = $2::text AND ("id" > $3::integer OR "id" IS NULL)))`,
+			args: []any{"bob", "bob", "7"},
+		},
+		{
+			name:     "nothing before the first row",
+			cursor:   []*string{nil, value("bob"), value("7")},
+			backward: true,
+			want: `(("created_at" IS NULL AND "name" < $1::text)` +
+				` OR ("created_at" IS NULL AND "name" = $2::text AND "id" < $3::integer))`,
+			args: []any{"bob", "bob", "7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []any
+			got := b.keysetCondition(tt.cursor, tt.backward, &args)
+			if got != tt.want {
+				t.Errorf("condition =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+		})
+	}
+
+	var args []any
+	last := &tableBrowser{keys: []orderKey{{Column: "id", SQLType: "integer"}}}
+	if got := last.keysetCondition([]*string{nil}, false, &args); got != "FALSE" || len(args) != 0 {
+		t.Errorf("after a NULL key = %q with args %q, want FALSE and no args", got, args)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	value := func(s string) *string { return &s }
+	b := &tableBrowser{keys: []orderKey{{Column: "name"}, {Column: "id"}}}
+	key := []*string{nil, value("42")}
+
+	got, err := b.decodeCursor(encodeCursor(key))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if len(got) != 2 || got[0] != nil || got[1] == nil || *got[1] != "42" {
+		t.Errorf("decodeCursor = %v, want [nil 42]", got)
+	}
+
+	for _, cursor := range []string{"", "not base64!", encodeCursor([]*string{value("42")})} {
+		if _, err := b.decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded, want an error", cursor)
+		}
+	}
+}
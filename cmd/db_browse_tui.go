@@ -0,0 +1,558 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// Widest a column is drawn in the table; the record view shows the rest
+	browseCellWidth = 32
+	// Most lines a wrapped cell takes up
+	browseWrapLines = 4
+)
+
+type browseMode int
+
+const (
+	browseTableMode browseMode = iota
+	browseRecordMode
+	browseColumnsMode
+)
+
+// browseView is the state of the interactive browser.
+type browseView struct {
+	ctx     context.Context
+	b       *tableBrowser
+	screen  tcell.Screen
+	page    *browsePage
+	visible map[string]bool
+
+	// The cursor the current page was fetched with, for reloading
+	cursor   []*string
+	backward bool
+
+	mode    browseMode
+	wrap    bool
+	row     int
+	col     int
+	topRow  int
+	leftCol int
+	// Line the record view is scrolled to, and the column picker position
+	scroll int
+	picker int
+	status string
+}
+
+var (
+	browseTitleStyle  = tcell.StyleDefault.Reverse(true)
+	browseHeaderStyle = tcell.StyleDefault.Bold(true).Underline(true)
+	browseRowStyle    = tcell.StyleDefault.Reverse(true)
+	browseCellStyle   = tcell.StyleDefault.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+	browseDimStyle    = tcell.StyleDefault.Dim(true)
+	browseErrorStyle  = tcell.StyleDefault.Foreground(tcell.ColorRed)
+)
+
+// runInteractive opens the full-screen browser at cursor. All columns are
+// fetched so they can be toggled without a reload; the selected ones are
+// shown at first.
+func (b *tableBrowser) runInteractive(ctx context.Context, cursor []*string, backward bool) error {
+	v := &browseView{ctx: ctx, b: b, visible: map[string]bool{}}
+	for _, name := range b.selected {
+		v.visible[name] = true
+	}
+	b.selected = nil
+	for _, column := range b.columns {
+		b.selected = append(b.selected, column.Column)
+	}
+
+	// Fail before taking over the terminal if the query is wrong
+	page, err := b.fetch(ctx, cursor, backward)
+	if err != nil {
+		return wrapError(err, "failed to read %s", b.label)
+	}
+	v.page, v.cursor, v.backward = page, cursor, backward
+
+	if v.screen, err = tcell.NewScreen(); err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	if err := v.screen.Init(); err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	defer v.screen.Fini()
+
+	for {
+		v.draw()
+		switch ev := v.screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			v.screen.Sync()
+		case *tcell.EventKey:
+			if v.handleKey(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// load replaces the page with the one at cursor, keeping the current page
+// when it fails or comes back empty.
+func (v *browseView) load(cursor []*string, backward bool) {
+	page, err := v.b.fetch(v.ctx, cursor, backward)
+	if err != nil {
+		v.status = err.Error()
+		return
+	}
+	if len(page.Rows.Values) == 0 && cursor != nil {
+		v.status = "no more rows"
+		return
+	}
+	v.page, v.cursor, v.backward = page, cursor, backward
+	v.topRow, v.status = 0, ""
+	if backward {
+		v.row = len(page.Rows.Values) - 1
+	} else {
+		v.row = 0
+	}
+}
+
+func (v *browseView) nextPage() {
+	if !v.page.HasNext || len(v.page.keys) == 0 {
+		v.status = "this is the last page"
+		return
+	}
+	v.load(v.page.keys[len(v.page.keys)-1], false)
+}
+
+func (v *browseView) prevPage() {
+	if !v.page.HasPrev || len(v.page.keys) == 0 {
+		v.status = "this is the first page"
+		return
+	}
+	v.load(v.page.keys[0], true)
+}
+
+// sortBy orders the table by column, reversing the order when it already
+// is the only sort column.
+func (v *browseView) sortBy(name string) {
+	column, _ := v.b.column(name)
+	key := orderKey{Column: column.Column, SQLType: column.SQLType}
+	if len(v.b.order) == 1 && v.b.order[0].Column == key.Column {
+		key.Desc = !v.b.order[0].Desc
+	}
+	previous := v.b.order
+	v.b.setOrder([]orderKey{key})
+	v.load(nil, false)
+	if v.status != "" {
+		v.b.setOrder(previous)
+	}
+}
+
+// columns returns the indexes of the visible columns.
+func (v *browseView) columns() []int {
+	var columns []int
+	for i, name := range v.b.selected {
+		if v.visible[name] {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+func (v *browseView) handleKey(ev *tcell.EventKey) bool {
+	if ev.Key() == tcell.KeyCtrlC {
+		return true
+	}
+	v.status = ""
+	switch v.mode {
+	case browseRecordMode:
+		v.recordKey(ev)
+		return false
+	case browseColumnsMode:
+		v.columnsKey(ev)
+		return false
+	}
+
+	rows, columns := len(v.page.Rows.Values), v.columns()
+	_, height := v.screen.Size()
+	switch key, r := ev.Key(), ev.Rune(); {
+	case key == tcell.KeyEscape, r == 'q':
+		return true
+	case key == tcell.KeyUp, r == 'k':
+		v.row = max(v.row-1, 0)
+	case key == tcell.KeyDown, r == 'j':
+		v.row = max(min(v.row+1, rows-1), 0)
+	case key == tcell.KeyLeft, r == 'h':
+		v.col = max(v.col-1, 0)
+	case key == tcell.KeyRight, r == 'l':
+		v.col = max(min(v.col+1, len(columns)-1), 0)
+	case key == tcell.KeyHome, r == '0':
+		v.col = 0
+	case key == tcell.KeyEnd, r == '$':
+		v.col = max(len(columns)-1, 0)
+	case key == tcell.KeyCtrlD:
+		v.row = max(min(v.row+height/2, rows-1), 0)
+	case key == tcell.KeyCtrlU:
+		v.row = max(v.row-height/2, 0)
+	case key == tcell.KeyPgDn, r == 'n', r == ' ':
+		v.nextPage()
+	case key == tcell.KeyPgUp, r == 'p', r == 'b':
+		v.prevPage()
+	case r == 'g':
+		v.load(nil, false)
+	case r == 'r':
+		v.load(v.cursor, v.backward)
+	case r == 's':
+		if len(columns) > 0 {
+			v.sortBy(v.b.selected[columns[v.col]])
+		}
+	case r == 'w':
+		v.wrap = !v.wrap
+	case r == 'c':
+		v.mode, v.picker = browseColumnsMode, 0
+	case key == tcell.KeyEnter:
+		if rows > 0 {
+			v.mode, v.scroll = browseRecordMode, 0
+		}
+	}
+	return false
+}
+
+func (v *browseView) recordKey(ev *tcell.EventKey) {
+	rows := len(v.page.Rows.Values)
+	switch key, r := ev.Key(), ev.Rune(); {
+	case key == tcell.KeyEscape, key == tcell.KeyEnter, r == 'q':
+		v.mode = browseTableMode
+	case key == tcell.KeyUp, r == 'k':
+		v.scroll = max(v.scroll-1, 0)
+	case key == tcell.KeyDown, r == 'j':
+		v.scroll++
+	case key == tcell.KeyLeft, r == 'h':
+		v.row, v.scroll = max(v.row-1, 0), 0
+	case key == tcell.KeyRight, r == 'l':
+		v.row, v.scroll = max(min(v.row+1, rows-1), 0), 0
+	}
+}
+
+func (v *browseView) columnsKey(ev *tcell.EventKey) {
+	names := v.b.selected
+	switch key, r := ev.Key(), ev.Rune(); {
+	case key == tcell.KeyEscape, key == tcell.KeyEnter, r == 'q', r == 'c':
+		v.mode = browseTableMode
+		v.col = min(v.col, max(len(v.columns())-1, 0))
+	case key == tcell.KeyUp, r == 'k':
+		v.picker = max(v.picker-1, 0)
+	case key == tcell.KeyDown, r == 'j':
+		v.picker = min(v.picker+1, len(names)-1)
+	case r == ' ', r == 'x':
+		name := names[v.picker]
+		if v.visible[name] && len(v.columns()) == 1 {
+			v.status = "at least one column must be shown"
+			return
+		}
+		v.visible[name] = !v.visible[name]
+	case r == 'a':
+		for _, name := range names {
+			v.visible[name] = true
+		}
+	}
+}
+
+func (v *browseView) draw() {
+	v.screen.Clear()
+	width, height := v.screen.Size()
+	switch v.mode {
+	case browseRecordMode:
+		v.drawRecord(width, height)
+	case browseColumnsMode:
+		v.drawColumns(width, height)
+	default:
+		v.drawTable(width, height)
+	}
+	v.screen.Show()
+}
+
+func (v *browseView) drawStatus(width, height int, help string) {
+	if v.status != "" {
+		drawText(v.screen, 0, height-1, width, v.status, browseErrorStyle)
+		return
+	}
+	drawText(v.screen, 0, height-1, width, help, browseDimStyle)
+}
+
+func (v *browseView) title() string {
+	title := v.b.label
+	if len(v.b.order) > 0 {
+		var order []string
+		for _, key := range v.b.order {
+			if key.Desc {
+				order = append(order, key.Column+" desc")
+			} else {
+				order = append(order, key.Column)
+			}
+		}
+		title += " · order by " + strings.Join(order, ", ")
+	}
+	if v.b.where != "" {
+		title += " · where " + strings.Join(strings.Fields(v.b.where), " ")
+	}
+	title += fmt.Sprintf(" · %d rows", len(v.page.Rows.Values))
+	if v.page.HasPrev {
+		title += " · ◀ prev"
+	}
+	if v.page.HasNext {
+		title += " · next ▶"
+	}
+	return title
+}
+
+func (v *browseView) drawTable(width, height int) {
+	drawText(v.screen, 0, 0, width, v.title(), browseTitleStyle)
+	v.drawStatus(width, height, "q quit  n/p page  g first  s sort  c columns  w wrap  enter record  r reload")
+	rows, columns := v.page.Rows.Rows(), v.columns()
+	if len(rows) == 0 {
+		drawText(v.screen, 0, 2, width, "(0 rows)", browseDimStyle)
+		return
+	}
+	v.row = min(v.row, len(rows)-1)
+
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = min(runewidth.StringWidth(v.b.selected[column]), browseCellWidth)
+		for _, row := range rows {
+			widths[i] = max(widths[i], min(runewidth.StringWidth(row[column]), browseCellWidth))
+		}
+		widths[i] = max(widths[i], 4)
+	}
+
+	// Scroll so the current column is fully on screen
+	v.leftCol = min(v.leftCol, v.col)
+	for v.leftCol < v.col {
+		used := 0
+		for i := v.leftCol; i <= v.col; i++ {
+			used += widths[i] + 3
+		}
+		if used <= width {
+			break
+		}
+		v.leftCol++
+	}
+
+	// Rows are one line, or up to browseWrapLines when wrapping
+	lines := make([][][]string, len(rows))
+	for i, row := range rows {
+		lines[i] = make([][]string, len(columns))
+		for j, column := range columns {
+			if v.wrap {
+				lines[i][j] = wrapCell(row[column], widths[j], browseWrapLines)
+			} else {
+				lines[i][j] = []string{clipCell(row[column], widths[j])}
+			}
+		}
+	}
+	rowHeight := func(i int) int {
+		h := 1
+		for _, cell := range lines[i] {
+			h = max(h, len(cell))
+		}
+		return h
+	}
+
+	// Scroll so the current row is fully on screen
+	available := height - 3
+	v.topRow = min(v.topRow, v.row)
+	for v.topRow < v.row {
+		used := 0
+		for i := v.topRow; i <= v.row; i++ {
+			used += rowHeight(i)
+		}
+		if used <= available {
+			break
+		}
+		v.topRow++
+	}
+
+	x := 0
+	for j := v.leftCol; j < len(columns) && x < width; j++ {
+		name := v.b.selected[columns[j]]
+		drawText(v.screen, x, 1, min(widths[j], width-x), clipCell(name, widths[j]), browseHeaderStyle)
+		x += widths[j] + 3
+	}
+
+	y := 2
+	for i := v.topRow; i < len(rows) && y < height-1; i++ {
+		h := min(rowHeight(i), height-1-y)
+		x := 0
+		for j := v.leftCol; j < len(columns) && x < width; j++ {
+			style := tcell.StyleDefault
+			value := v.page.Rows.Values[i][columns[j]]
+			switch {
+			case i == v.row && j == v.col:
+				style = browseCellStyle
+			case i == v.row:
+				style = browseRowStyle
+			case value == nil:
+				style = browseDimStyle
+			}
+			cell := lines[i][j]
+			if value == nil {
+				cell = []string{"NULL"}
+			}
+			for line := range h {
+				text := ""
+				if line < len(cell) {
+					text = cell[line]
+				}
+				drawText(v.screen, x, y+line, min(widths[j], width-x), text, style)
+				if i == v.row && x+widths[j] < width {
+					drawText(v.screen, x+widths[j], y+line, min(3, width-x-widths[j]), " │ ", browseRowStyle)
+				} else {
+					drawText(v.screen, x+widths[j], y+line, min(3, width-x-widths[j]), " │ ", browseDimStyle)
+				}
+			}
+			x += widths[j] + 3
+		}
+		y += h
+	}
+}
+
+// drawRecord shows every column of the current row with its full value.
+func (v *browseView) drawRecord(width, height int) {
+	drawText(v.screen, 0, 0, width, fmt.Sprintf("%s · row %d of %d", v.b.label, v.row+1, len(v.page.Rows.Values)), browseTitleStyle)
+	v.drawStatus(width, height, "esc back  ↑/↓ scroll  ←/→ previous/next row")
+
+	labelWidth := 0
+	for _, name := range v.b.selected {
+		labelWidth = max(labelWidth, min(runewidth.StringWidth(name), browseCellWidth))
+	}
+	valueWidth := max(width-labelWidth-2, 10)
+
+	type line struct {
+		label, text string
+		null        bool
+	}
+	var lines []line
+	row := v.page.Rows.Rows()[v.row]
+	for i, name := range v.b.selected {
+		if v.page.Rows.Values[v.row][i] == nil {
+			lines = append(lines, line{name, "NULL", true})
+			continue
+		}
+		for j, text := range wrapCell(row[i], valueWidth, 0) {
+			if j > 0 {
+				name = ""
+			}
+			lines = append(lines, line{name, text, false})
+		}
+	}
+
+	v.scroll = max(min(v.scroll, len(lines)-(height-2)), 0)
+	for y, l := range lines[v.scroll:] {
+		if y+1 >= height-1 {
+			break
+		}
+		drawText(v.screen, 0, y+1, labelWidth, clipCell(l.label, labelWidth), browseHeaderStyle.Underline(false))
+		style := tcell.StyleDefault
+		if l.null {
+			style = browseDimStyle
+		}
+		drawText(v.screen, labelWidth+2, y+1, width-labelWidth-2, l.text, style)
+	}
+}
+
+func (v *browseView) drawColumns(width, height int) {
+	drawText(v.screen, 0, 0, width, v.b.label+" · columns", browseTitleStyle)
+	v.drawStatus(width, height, "space toggle  a show all  enter/esc done")
+
+	top := max(v.picker-(height-3), 0)
+	for i, name := range v.b.selected[top:] {
+		y := i + 1
+		if y >= height-1 {
+			break
+		}
+		mark := "[ ] "
+		if v.visible[name] {
+			mark = "[x] "
+		}
+		column, _ := v.b.column(name)
+		style := tcell.StyleDefault
+		if top+i == v.picker {
+			style = browseRowStyle
+		}
+		drawText(v.screen, 0, y, width, mark+name+"  "+column.SQLType, style)
+	}
+}
+
+// drawText writes text at x, y, cut off and padded to width cells.
+func drawText(screen tcell.Screen, x, y, width int, text string, style tcell.Style) {
+	end := x + width
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += max(w, 1)
+	}
+	for ; x < end; x++ {
+		screen.SetContent(x, y, ' ', nil, style)
+	}
+}
+
+// cleanCell replaces control characters, which would corrupt the screen.
+func cleanCell(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// clipCell fits a value on one line of width cells.
+func clipCell(text string, width int) string {
+	text = cleanCell(text)
+	if runewidth.StringWidth(text) <= width {
+		return text
+	}
+	return runewidth.Truncate(text, width, "…")
+}
+
+// wrapCell breaks a value into lines of width cells, keeping its line
+// breaks. With maxLines above zero the last line is cut off with "…".
+func wrapCell(text string, width, maxLines int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		var line strings.Builder
+		used := 0
+		for _, r := range cleanCell(paragraph) {
+			w := runewidth.RuneWidth(r)
+			if used+w > width && used > 0 {
+				lines = append(lines, line.String())
+				line.Reset()
+				used = 0
+			}
+			line.WriteRune(r)
+			used += w
+		}
+		lines = append(lines, line.String())
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = slices.Clip(lines[:maxLines])
+		last := lines[maxLines-1]
+		if runewidth.StringWidth(last) >= width {
+			last = runewidth.Truncate(last, width-1, "")
+		}
+		lines[maxLines-1] = last + "…"
+	}
+	return lines
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbCloneCmd = &cobra.Command{
+	Use:   "clone [source-database] [target-database]",
+	Short: "Copy a database on the same server or to another server",
+	Long: `Create target-database as a copy of source-database.
+
+On the same server the copy is made with CREATE DATABASE ... TEMPLATE, which
+is fast but needs source-database to have no other connections; --terminate
+ends them first. Between servers, e.g. --from-profile prod --to-profile dev,
+pg_dump is piped straight into pg_restore without a temporary file.
+
+--mask applies the masking rules from the config file to the copy, as
+'tools db anonymize' does, before it is reported as done.
+
+target-database must not exist yet. If the copy or the masking fails it is
+dropped again, so no unmasked copy is left behind.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts cloneOptions
+		opts.FromProfile, _ = cmd.Flags().GetString("from-profile")
+		opts.ToProfile, _ = cmd.Flags().GetString("to-profile")
+		opts.Terminate, _ = cmd.Flags().GetBool("terminate")
+		opts.Mask, _ = cmd.Flags().GetBool("mask")
+		return cloneDatabase(args[0], args[1], opts)
+	},
+}
+
+func init() {
+	dbCloneCmd.Flags().String("from-profile", "", "Profile of the source server (default active profile)")
+	dbCloneCmd.Flags().String("to-profile", "", "Profile of the target server (default active profile)")
+	dbCloneCmd.Flags().Bool("terminate", false, "Terminate other connections to the source database (same server only)")
+	dbCloneCmd.Flags().Bool("mask", false, "Apply the masking rules to the copy")
+	dbCmd.AddCommand(dbCloneCmd)
+}
+
+type cloneOptions struct {
+	FromProfile string
+	ToProfile   string
+	Terminate   bool
+	Mask        bool
+}
+
+// cloneProgressInterval is how often a streamed clone reports progress.
+const cloneProgressInterval = 5 * time.Second
+
+// sameServer reports whether two profiles point at the same PostgreSQL
+// server, in which case a template copy works.
+func sameServer(fromProfile, toProfile string) bool {
+	fromHost, fromPort, _, _ := postgresSettings(fromProfile)
+	toHost, toPort, _, _ := postgresSettings(toProfile)
+	return fromHost == toHost && fromPort == toPort
+}
+
+func cloneDatabase(source, target string, opts cloneOptions) error {
+	for _, profile := range []string{opts.FromProfile, opts.ToProfile} {
+		if profile != "" && !profileExists(profile) {
+			return notFoundError("profile '%s' not found in config file", profile)
+		}
+	}
+	if opts.Mask {
+		// Catch bad rules before spending time on the copy
+		if _, _, err := loadMaskingRules(); err != nil {
+			return err
+		}
+	}
+
+	if sameServer(opts.FromProfile, opts.ToProfile) {
+		if source == target {
+			return usageError("source and target database are the same")
+		}
+		return cloneFromTemplate(source, target, opts)
+	}
+	if opts.Terminate {
+		return usageError("--terminate only applies when cloning on the same server")
+	}
+	return cloneStreamed(source, target, opts)
+}
+
+// cloneFromTemplate copies source with CREATE DATABASE ... TEMPLATE.
+func cloneFromTemplate(source, target string, opts cloneOptions) error {
+	ctx := context.Background()
+	conn, err := connectPostgresProfile(ctx, opts.FromProfile, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if opts.Terminate {
+		var terminated int
+		err := conn.QueryRow(ctx, `SELECT count(pg_terminate_backend(pid)) FROM pg_stat_activity
+			WHERE datname = $1 AND pid <> pg_backend_pid()`, source).Scan(&terminated)
+		if err != nil {
+			return wrapError(err, "failed to terminate connections to '%s'", source)
+		}
+		if terminated > 0 {
+			printProgress("Terminated %d connections to '%s'", terminated, source)
+		}
+	}
+
+	printProgress("Cloning database '%s' to '%s' from template...", source, target)
+	started := time.Now()
+	query := "CREATE DATABASE " + pgx.Identifier{target}.Sanitize() + " TEMPLATE " + pgx.Identifier{source}.Sanitize()
+	if _, err := conn.Exec(ctx, query); err != nil {
+		if pgErrorCode(err) == "55006" {
+			return conflictError("database '%s' has other connections, close them or pass --terminate", source)
+		}
+		return wrapError(err, "failed to clone database '%s' to '%s'", source, target)
+	}
+	if err := maskClone(ctx, conn, target, opts); err != nil {
+		return err
+	}
+
+	return reportAction("clone", target, "cloned", "✓ Database '%s' cloned to '%s' in %s", source, target, time.Since(started).Round(time.Millisecond))
+}
+
+// cloneStreamed pipes pg_dump on the source server into pg_restore on the
+// target server.
+func cloneStreamed(source, target string, opts cloneOptions) error {
+	ctx := context.Background()
+	conn, err := connectPostgresProfile(ctx, opts.ToProfile, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{target}.Sanitize()); err != nil {
+		return wrapError(err, "failed to create database '%s'", target)
+	}
+
+	printProgress("Cloning database '%s' to '%s' through pg_dump | pg_restore...",
+		diffLabel(opts.FromProfile, source), diffLabel(opts.ToProfile, target))
+	started := time.Now()
+	copied, err := pipeDumpToRestore(source, target, opts)
+	if err != nil {
+		// Leave no half-restored copy behind
+		if _, dropErr := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{target}.Sanitize()); dropErr != nil {
+			printError("failed to drop incomplete database '%s': %v", target, dropErr)
+		}
+		return wrapError(err, "failed to clone database '%s' to '%s'", source, target)
+	}
+	if err := maskClone(ctx, conn, target, opts); err != nil {
+		return err
+	}
+
+	return reportAction("clone", target, "cloned", "✓ Database '%s' cloned to '%s' (%s in %s)",
+		source, target, formatBytes(copied), time.Since(started).Round(time.Second))
+}
+
+// maskClone applies the masking rules to a fresh copy when --mask is set.
+// conn is a maintenance connection to the target server, used to drop the
+// copy if masking fails.
+func maskClone(ctx context.Context, conn *pgx.Conn, target string, opts cloneOptions) error {
+	if !opts.Mask {
+		return nil
+	}
+	records, err := maskDatabase(opts.ToProfile, target, maskOptions{})
+	if err != nil {
+		if _, dropErr := conn.Exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{target}.Sanitize()); dropErr != nil {
+			printError("failed to drop unmasked database '%s': %v", target, dropErr)
+		}
+		return wrapError(err, "failed to mask database '%s'", target)
+	}
+
+	var total int64
+	for _, record := range records {
+		total += record.Rows
+	}
+	printProgress("Masked %d rows in %d tables", total, len(records))
+	return nil
+}
+
+// pipeDumpToRestore runs pg_dump and pg_restore connected by a pipe,
+// reporting how much has been copied while they run, and returns the size
+// of the dump.
+func pipeDumpToRestore(source, target string, opts cloneOptions) (int64, error) {
+	dumpArgs := []string{"-d", source, "--no-owner", "--no-acl", "--format", formatCustom, "--compress", "0"}
+	restoreArgs := pgRestoreArgs(target, formatCustom, restoreOptions{Jobs: 1})
+
+	reader, writer := io.Pipe()
+	counter := &countingWriter{w: writer}
+
+	dump := pgToolProfile(opts.FromProfile, "pg_dump", dumpArgs...)
+	dump.Stdout = counter
+	restore := pgToolProfile(opts.ToProfile, "pg_restore", restoreArgs...)
+	restore.Stdin = reader
+
+	stopProgress := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(cloneProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				printProgress("  %s copied", formatBytes(counter.n.Load()))
+			case <-stopProgress:
+				return
+			}
+		}
+	}()
+	defer close(stopProgress)
+
+	dumpDone := make(chan error, 1)
+	go func() {
+		err := runTool(dump)
+		dumpDone <- err
+		writer.CloseWithError(err)
+	}()
+
+	restoreErr := runTool(restore)
+	select {
+	case dumpErr := <-dumpDone:
+		// pg_dump ended first, so a failed dump is what broke the restore.
+		if dumpErr != nil {
+			return 0, wrapError(dumpErr, "pg_dump failed")
+		}
+	default:
+		// pg_restore gave up while pg_dump was still writing; stop it.
+		reader.CloseWithError(io.ErrClosedPipe)
+		<-dumpDone
+	}
+	if restoreErr != nil {
+		return 0, wrapError(restoreErr, "pg_restore failed")
+	}
+	return counter.n.Load(), nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
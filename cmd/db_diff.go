@@ -0,0 +1,664 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbDiffCmd = &cobra.Command{
+	Use:   "diff [database-a] [database-b]",
+	Short: "Compare the schemas of two databases",
+	Long: `Compare tables, columns, indexes, constraints, sequences and views of two
+databases and report what database-b has that database-a lacks (added),
+what only database-a has (removed) and what differs (changed).
+
+Both databases are on the server of the active profile unless --profile-a
+or --profile-b picks another one, e.g. to compare dev with staging.
+
+With --sql the report is turned into a script that migrates database-a to
+the schema of database-b. Review it before running it: dropped columns and
+tables lose their data and type changes may need a better USING clause.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts diffOptions
+		opts.ProfileA, _ = cmd.Flags().GetString("profile-a")
+		opts.ProfileB, _ = cmd.Flags().GetString("profile-b")
+		opts.Schemas, _ = cmd.Flags().GetStringSlice("schema")
+		opts.SQLFile, _ = cmd.Flags().GetString("sql")
+		return diffDatabases(args[0], args[1], opts)
+	},
+}
+
+func init() {
+	dbDiffCmd.Flags().String("profile-a", "", "Profile of the server holding database-a (default active profile)")
+	dbDiffCmd.Flags().String("profile-b", "", "Profile of the server holding database-b (default active profile)")
+	dbDiffCmd.Flags().StringSlice("schema", nil, "Only compare these schemas (default all but system schemas)")
+	dbDiffCmd.Flags().String("sql", "", "Write a script migrating database-a to database-b to this file (- for stdout)")
+	dbCmd.AddCommand(dbDiffCmd)
+}
+
+type diffOptions struct {
+	ProfileA string
+	ProfileB string
+	Schemas  []string
+	SQLFile  string
+}
+
+// Kinds of schema changes, seen from database-a towards database-b.
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// schemaChange is one difference. Columns, constraints and indexes name
+// their table; tables, sequences and views carry their qualified name.
+type schemaChange struct {
+	Object string `json:"object"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	Detail string `json:"detail"`
+}
+
+type constraintRecord struct {
+	Name       string `json:"name" db:"name"`
+	Type       string `json:"type" db:"type"`
+	Definition string `json:"definition" db:"definition"`
+}
+
+type sequenceRecord struct {
+	Schema    string `json:"schema" db:"schema"`
+	Name      string `json:"name" db:"name"`
+	Type      string `json:"type" db:"type"`
+	Start     int64  `json:"start" db:"start"`
+	Increment int64  `json:"increment" db:"increment"`
+	Min       int64  `json:"min" db:"min"`
+	Max       int64  `json:"max" db:"max"`
+	Cycle     bool   `json:"cycle" db:"cycle"`
+}
+
+type viewRecord struct {
+	Schema     string `json:"schema" db:"schema"`
+	Name       string `json:"name" db:"name"`
+	Definition string `json:"definition" db:"definition"`
+}
+
+type tableSchema struct {
+	Schema      string
+	Name        string
+	Columns     []columnRecord
+	Indexes     []indexRecord
+	Constraints []constraintRecord
+}
+
+// schemaSnapshot is the comparable schema of one database, keyed by
+// qualified name.
+type schemaSnapshot struct {
+	Schemas   map[string]bool
+	Tables    map[string]*tableSchema
+	Sequences map[string]sequenceRecord
+	Views     map[string]viewRecord
+}
+
+// readSchema collects the schema of the database conn is connected to. The
+// columns and indexes of each table come from the same queries db table
+// uses.
+func readSchema(ctx context.Context, conn *pgx.Conn, schemas []string) (*schemaSnapshot, error) {
+	snapshot := &schemaSnapshot{
+		Schemas:   make(map[string]bool),
+		Tables:    make(map[string]*tableSchema),
+		Sequences: make(map[string]sequenceRecord),
+		Views:     make(map[string]viewRecord),
+	}
+
+	schemaFilter := `schemaname NOT IN ('pg_catalog', 'information_schema') AND schemaname NOT LIKE 'pg\_%'`
+	args := []any{}
+	if len(schemas) > 0 {
+		schemaFilter = `schemaname = ANY($1)`
+		args = append(args, schemas)
+	}
+
+	rows, err := conn.Query(ctx,
+		`SELECT schemaname FROM (SELECT nspname AS schemaname FROM pg_namespace) AS n WHERE `+schemaFilter, args...)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		snapshot.Schemas[pgx.Identifier{name}.Sanitize()] = true
+	}
+
+	type tableName struct {
+		Schema string `db:"schema"`
+		Name   string `db:"name"`
+	}
+	tables, err := queryRecords[tableName](ctx, conn,
+		`SELECT schemaname AS schema, tablename AS name FROM pg_tables WHERE `+schemaFilter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		table := &tableSchema{Schema: t.Schema, Name: t.Name}
+		if table.Columns, err = tableColumns(ctx, conn, t.Schema, t.Name); err != nil {
+			return nil, err
+		}
+		if table.Indexes, err = tableIndexes(ctx, conn, t.Schema, t.Name); err != nil {
+			return nil, err
+		}
+		if table.Constraints, err = tableConstraints(ctx, conn, t.Schema, t.Name); err != nil {
+			return nil, err
+		}
+		snapshot.Tables[qualifiedName(t.Schema, t.Name)] = table
+	}
+
+	sequences, err := queryRecords[sequenceRecord](ctx, conn, `
+		SELECT schemaname AS schema, sequencename AS name, data_type::text AS type,
+			start_value AS start, increment_by AS increment,
+			min_value AS min, max_value AS max, cycle
+		FROM pg_sequences WHERE `+schemaFilter+`
+			-- Identity sequences come and go with their column
+			AND NOT EXISTS (
+				SELECT 1 FROM pg_depend AS d
+				WHERE d.classid = 'pg_class'::regclass
+					AND d.objid = format('%I.%I', schemaname, sequencename)::regclass
+					AND d.deptype = 'i'
+			)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, sequence := range sequences {
+		snapshot.Sequences[qualifiedName(sequence.Schema, sequence.Name)] = sequence
+	}
+
+	views, err := queryRecords[viewRecord](ctx, conn, `
+		SELECT schemaname AS schema, viewname AS name, definition
+		FROM pg_views WHERE `+schemaFilter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range views {
+		snapshot.Views[qualifiedName(view.Schema, view.Name)] = view
+	}
+	return snapshot, nil
+}
+
+// tableConstraints returns the primary key, unique, check, exclusion and
+// foreign key constraints of a table with their definitions.
+func tableConstraints(ctx context.Context, conn *pgx.Conn, schema, table string) ([]constraintRecord, error) {
+	query := `
+		SELECT con.conname AS name, con.contype::text AS type, pg_get_constraintdef(con.oid) AS definition
+		FROM pg_constraint AS con
+		JOIN pg_class AS rel ON rel.oid = con.conrelid
+		JOIN pg_namespace AS nsp ON nsp.oid = rel.relnamespace
+		WHERE nsp.nspname = $1 AND rel.relname = $2 AND con.contype IN ('p', 'u', 'c', 'x', 'f')
+		ORDER BY con.conname`
+	return queryRecords[constraintRecord](ctx, conn, query, schema, table)
+}
+
+// qualifiedName quotes a schema-qualified name for SQL and reports.
+func qualifiedName(schema, name string) string {
+	return pgx.Identifier{schema, name}.Sanitize()
+}
+
+// Phases of the migration script. Drops of dependent objects come first and
+// foreign keys and views last, so each statement finds what it needs.
+const (
+	phaseDropViews = iota
+	phaseDropForeignKeys
+	phaseDropConstraints
+	phaseDropIndexes
+	phaseCreateSchemas
+	phaseSequences
+	phaseCreateTables
+	phaseAlterColumns
+	phaseDropColumns
+	phaseDropTables
+	phaseDropSequences
+	phaseAddConstraints
+	phaseAddForeignKeys
+	phaseCreateIndexes
+	phaseCreateViews
+	phaseCount
+)
+
+// schemaDiff accumulates the changes between two snapshots and the script
+// that applies them.
+type schemaDiff struct {
+	changes []schemaChange
+	script  [phaseCount][]string
+}
+
+func (d *schemaDiff) change(object, table, name, change, detail string) {
+	d.changes = append(d.changes, schemaChange{Object: object, Table: table, Name: name, Change: change, Detail: detail})
+}
+
+func (d *schemaDiff) sql(phase int, format string, args ...any) {
+	d.script[phase] = append(d.script[phase], fmt.Sprintf(format, args...)+";")
+}
+
+func (d *schemaDiff) statements() []string {
+	var statements []string
+	for _, phase := range d.script {
+		statements = append(statements, phase...)
+	}
+	return statements
+}
+
+// sortedKeys returns the keys of both maps, sorted.
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compareSchemas(a, b *schemaSnapshot) *schemaDiff {
+	d := &schemaDiff{}
+
+	// Schemas are only created: dropping one would take objects outside the
+	// comparison with it
+	for _, name := range sortedKeys(a.Schemas, b.Schemas) {
+		if !a.Schemas[name] {
+			d.change("schema", "", name, changeAdded, "")
+			d.sql(phaseCreateSchemas, "CREATE SCHEMA IF NOT EXISTS %s", name)
+		}
+	}
+
+	for _, name := range sortedKeys(a.Sequences, b.Sequences) {
+		seqA, inA := a.Sequences[name]
+		seqB, inB := b.Sequences[name]
+		switch {
+		case !inA:
+			d.change("sequence", "", name, changeAdded, seqB.Type)
+			d.sql(phaseSequences, "CREATE SEQUENCE %s %s", name, sequenceOptions(seqB))
+		case !inB:
+			d.change("sequence", "", name, changeRemoved, seqA.Type)
+			// Sequences owned by a dropped column or table are gone already
+			d.sql(phaseDropSequences, "DROP SEQUENCE IF EXISTS %s", name)
+		case seqA != seqB:
+			d.change("sequence", "", name, changeChanged, sequenceOptions(seqA)+" → "+sequenceOptions(seqB))
+			d.sql(phaseSequences, "ALTER SEQUENCE %s %s", name, sequenceOptions(seqB))
+		}
+	}
+
+	for _, name := range sortedKeys(a.Tables, b.Tables) {
+		tableA, inA := a.Tables[name]
+		tableB, inB := b.Tables[name]
+		switch {
+		case !inA:
+			d.change("table", "", name, changeAdded, fmt.Sprintf("%d columns", len(tableB.Columns)))
+			definitions := make([]string, len(tableB.Columns))
+			for i, column := range tableB.Columns {
+				definitions[i] = "\n    " + columnDefinition(column)
+			}
+			d.sql(phaseCreateTables, "CREATE TABLE %s (%s\n)", name, strings.Join(definitions, ","))
+			compareTableObjects(d, &tableSchema{}, tableB, tableB.Schema, name, false)
+		case !inB:
+			d.change("table", "", name, changeRemoved, fmt.Sprintf("%d columns", len(tableA.Columns)))
+			d.sql(phaseDropTables, "DROP TABLE %s", name)
+		default:
+			compareColumns(d, tableA, tableB, name)
+			compareTableObjects(d, tableA, tableB, tableA.Schema, name, true)
+		}
+	}
+
+	for _, name := range sortedKeys(a.Views, b.Views) {
+		viewA, inA := a.Views[name]
+		viewB, inB := b.Views[name]
+		switch {
+		case !inA:
+			d.change("view", "", name, changeAdded, "")
+			d.sql(phaseCreateViews, "CREATE VIEW %s AS\n%s", name, strings.TrimSuffix(strings.TrimSpace(viewB.Definition), ";"))
+		case !inB:
+			d.change("view", "", name, changeRemoved, "")
+			d.sql(phaseDropViews, "DROP VIEW %s", name)
+		case normalizeSQL(viewA.Definition) != normalizeSQL(viewB.Definition):
+			d.change("view", "", name, changeChanged, "definition differs")
+			d.sql(phaseDropViews, "DROP VIEW %s", name)
+			d.sql(phaseCreateViews, "CREATE VIEW %s AS\n%s", name, strings.TrimSuffix(strings.TrimSpace(viewB.Definition), ";"))
+		}
+	}
+	return d
+}
+
+func compareColumns(d *schemaDiff, a, b *tableSchema, table string) {
+	columnsA := make(map[string]columnRecord, len(a.Columns))
+	for _, column := range a.Columns {
+		columnsA[column.Column] = column
+	}
+	columnsB := make(map[string]columnRecord, len(b.Columns))
+	for _, column := range b.Columns {
+		columnsB[column.Column] = column
+	}
+
+	for _, name := range sortedKeys(columnsA, columnsB) {
+		colA, inA := columnsA[name]
+		colB, inB := columnsB[name]
+		column := pgx.Identifier{name}.Sanitize()
+		switch {
+		case !inA:
+			d.change("column", table, name, changeAdded, columnSummary(colB))
+			d.sql(phaseAlterColumns, "ALTER TABLE %s ADD COLUMN %s", table, columnDefinition(colB))
+		case !inB:
+			d.change("column", table, name, changeRemoved, columnSummary(colA))
+			d.sql(phaseDropColumns, "ALTER TABLE %s DROP COLUMN %s", table, column)
+		default:
+			var details []string
+			if colA.SQLType != colB.SQLType {
+				details = append(details, fmt.Sprintf("type %s → %s", colA.SQLType, colB.SQLType))
+				d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", table, column, colB.SQLType, column, colB.SQLType)
+			}
+			if defaultA, defaultB := columnDefault(colA), columnDefault(colB); defaultA != defaultB {
+				details = append(details, fmt.Sprintf("default %s → %s", displayDefault(defaultA), displayDefault(defaultB)))
+				if defaultB == "" {
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", table, column)
+				} else {
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s", table, column, defaultB)
+				}
+			}
+			if identityA, identityB := columnIdentity(colA), columnIdentity(colB); identityA != identityB {
+				details = append(details, fmt.Sprintf("identity %s → %s", displayDefault(identityA), displayDefault(identityB)))
+				switch {
+				case identityB == "":
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s DROP IDENTITY", table, column)
+				case identityA == "":
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s ADD GENERATED %s AS IDENTITY", table, column, identityB)
+				default:
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s SET GENERATED %s", table, column, identityB)
+				}
+			}
+			if colA.Nullable != colB.Nullable {
+				details = append(details, fmt.Sprintf("nullable %s → %s", colA.Nullable, colB.Nullable))
+				if colB.Nullable == "NO" {
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", table, column)
+				} else {
+					d.sql(phaseAlterColumns, "ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", table, column)
+				}
+			}
+			if len(details) > 0 {
+				d.change("column", table, name, changeChanged, strings.Join(details, ", "))
+			}
+		}
+	}
+}
+
+// compareTableObjects compares the constraints and indexes of a table. For a
+// new table report is false: the table itself is the reported change, but
+// its constraints and indexes still belong in the script.
+func compareTableObjects(d *schemaDiff, a, b *tableSchema, schema, table string, report bool) {
+	constraintsA := make(map[string]constraintRecord, len(a.Constraints))
+	for _, constraint := range a.Constraints {
+		constraintsA[constraint.Name] = constraint
+	}
+	constraintsB := make(map[string]constraintRecord, len(b.Constraints))
+	for _, constraint := range b.Constraints {
+		constraintsB[constraint.Name] = constraint
+	}
+
+	for _, name := range sortedKeys(constraintsA, constraintsB) {
+		conA, inA := constraintsA[name]
+		conB, inB := constraintsB[name]
+		dropPhase, addPhase := phaseDropConstraints, phaseAddConstraints
+		if conA.Type == "f" || conB.Type == "f" {
+			dropPhase, addPhase = phaseDropForeignKeys, phaseAddForeignKeys
+		}
+		drop := func() {
+			d.sql(dropPhase, "ALTER TABLE %s DROP CONSTRAINT %s", table, pgx.Identifier{name}.Sanitize())
+		}
+		add := func() {
+			d.sql(addPhase, "ALTER TABLE %s ADD CONSTRAINT %s %s", table, pgx.Identifier{name}.Sanitize(), conB.Definition)
+		}
+		switch {
+		case !inA:
+			if report {
+				d.change("constraint", table, name, changeAdded, conB.Definition)
+			}
+			add()
+		case !inB:
+			d.change("constraint", table, name, changeRemoved, conA.Definition)
+			drop()
+		case conA.Definition != conB.Definition:
+			d.change("constraint", table, name, changeChanged, conA.Definition+" → "+conB.Definition)
+			drop()
+			add()
+		}
+	}
+
+	// Indexes backing primary key, unique and exclusion constraints come and
+	// go with their constraint.
+	indexesA := make(map[string]indexRecord, len(a.Indexes))
+	for _, index := range a.Indexes {
+		if _, ok := constraintsA[index.Name]; !ok {
+			indexesA[index.Name] = index
+		}
+	}
+	indexesB := make(map[string]indexRecord, len(b.Indexes))
+	for _, index := range b.Indexes {
+		if _, ok := constraintsB[index.Name]; !ok {
+			indexesB[index.Name] = index
+		}
+	}
+
+	for _, name := range sortedKeys(indexesA, indexesB) {
+		indexA, inA := indexesA[name]
+		indexB, inB := indexesB[name]
+		index := qualifiedName(schema, name)
+		switch {
+		case !inA:
+			if report {
+				d.change("index", table, name, changeAdded, indexB.Definition)
+			}
+			d.sql(phaseCreateIndexes, "%s", indexB.Definition)
+		case !inB:
+			d.change("index", table, name, changeRemoved, indexA.Definition)
+			d.sql(phaseDropIndexes, "DROP INDEX %s", index)
+		case indexA.Definition != indexB.Definition:
+			d.change("index", table, name, changeChanged, indexA.Definition+" → "+indexB.Definition)
+			d.sql(phaseDropIndexes, "DROP INDEX %s", index)
+			d.sql(phaseCreateIndexes, "%s", indexB.Definition)
+		}
+	}
+}
+
+func sequenceOptions(s sequenceRecord) string {
+	cycle := "NO CYCLE"
+	if s.Cycle {
+		cycle = "CYCLE"
+	}
+	return fmt.Sprintf("AS %s INCREMENT BY %d MINVALUE %d MAXVALUE %d START WITH %d %s",
+		s.Type, s.Increment, s.Min, s.Max, s.Start, cycle)
+}
+
+func columnDefault(column columnRecord) string {
+	if column.Default == nil {
+		return ""
+	}
+	return *column.Default
+}
+
+func columnIdentity(column columnRecord) string {
+	if column.Identity == nil {
+		return ""
+	}
+	return *column.Identity
+}
+
+func displayDefault(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// columnDefinition renders a column as in CREATE TABLE.
+func columnDefinition(column columnRecord) string {
+	definition := pgx.Identifier{column.Column}.Sanitize() + " " + column.SQLType
+	if value := columnDefault(column); value != "" {
+		definition += " DEFAULT " + value
+	}
+	if identity := columnIdentity(column); identity != "" {
+		definition += " GENERATED " + identity + " AS IDENTITY"
+	}
+	if column.Nullable == "NO" {
+		definition += " NOT NULL"
+	}
+	return definition
+}
+
+func columnSummary(column columnRecord) string {
+	summary := column.SQLType
+	if column.Nullable == "NO" {
+		summary += " not null"
+	}
+	if value := columnDefault(column); value != "" {
+		summary += " default " + value
+	}
+	if identity := columnIdentity(column); identity != "" {
+		summary += " generated " + strings.ToLower(identity) + " as identity"
+	}
+	return summary
+}
+
+// normalizeSQL collapses whitespace so view definitions pretty-printed by
+// different server versions compare equal.
+func normalizeSQL(sql string) string {
+	return strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(sql), ";")), " ")
+}
+
+func diffDatabases(dbA, dbB string, opts diffOptions) error {
+	ctx := context.Background()
+
+	snapshots := make([]*schemaSnapshot, 2)
+	for i, target := range []struct{ profile, database string }{{opts.ProfileA, dbA}, {opts.ProfileB, dbB}} {
+		conn, err := connectPostgresProfile(ctx, target.profile, target.database)
+		if err != nil {
+			return err
+		}
+		snapshots[i], err = readSchema(ctx, conn, opts.Schemas)
+		conn.Close(ctx)
+		if err != nil {
+			return wrapError(err, "failed to read schema of database '%s'", target.database)
+		}
+	}
+
+	diff := compareSchemas(snapshots[0], snapshots[1])
+	labelA, labelB := diffLabel(opts.ProfileA, dbA), diffLabel(opts.ProfileB, dbB)
+
+	if opts.SQLFile != "" {
+		if err := writeDiffScript(opts.SQLFile, labelA, labelB, diff.statements()); err != nil {
+			return err
+		}
+		if opts.SQLFile == "-" {
+			return nil
+		}
+	}
+
+	if ok, err := renderOutput(diff.changes); ok {
+		return err
+	}
+
+	if len(diff.changes) == 0 {
+		color.Green("✓ No schema differences between '%s' and '%s'", labelA, labelB)
+	} else {
+		printSchemaChanges(labelA, labelB, diff.changes)
+	}
+	if opts.SQLFile != "" {
+		color.Green("✓ Migration script with %d statements written to '%s'", len(diff.statements()), opts.SQLFile)
+	}
+	return nil
+}
+
+func diffLabel(profile, database string) string {
+	if profile == "" {
+		return database
+	}
+	return profile + ":" + database
+}
+
+func printSchemaChanges(labelA, labelB string, changes []schemaChange) {
+	color.Green("Schema differences from '%s' to '%s':", labelA, labelB)
+	fmt.Println()
+
+	// Group changes by the table or object they belong to
+	objects := []string{}
+	grouped := make(map[string][]schemaChange)
+	for _, change := range changes {
+		group := change.Table
+		if group == "" {
+			group = change.Name
+		}
+		if !slices.Contains(objects, group) {
+			objects = append(objects, group)
+		}
+		grouped[group] = append(grouped[group], change)
+	}
+
+	counts := make(map[string]int)
+	for _, group := range objects {
+		color.Cyan(group)
+		for _, change := range grouped[group] {
+			counts[change.Change]++
+			line := "  " + change.Object
+			if change.Table != "" {
+				line += " " + change.Name
+			}
+			if change.Detail != "" {
+				line += ": " + change.Detail
+			}
+			switch change.Change {
+			case changeAdded:
+				color.Green("+" + line)
+			case changeRemoved:
+				color.Red("-" + line)
+			default:
+				color.Yellow("~" + line)
+			}
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", counts[changeAdded], counts[changeRemoved], counts[changeChanged])
+}
+
+// writeDiffScript writes the migration script to path, or stdout for "-".
+func writeDiffScript(path, labelA, labelB string, statements []string) error {
+	var script strings.Builder
+	fmt.Fprintf(&script, "-- Migrates the schema of %s to match %s.\n", labelA, labelB)
+	script.WriteString("-- Generated by tools db diff; review before running.\n\n")
+	if len(statements) == 0 {
+		script.WriteString("-- No differences.\n")
+	} else {
+		script.WriteString("BEGIN;\n\n")
+		for _, statement := range statements {
+			script.WriteString(statement + "\n\n")
+		}
+		script.WriteString("COMMIT;\n")
+	}
+
+	if path == "-" {
+		_, err := os.Stdout.WriteString(script.String())
+		return err
+	}
+	if err := os.WriteFile(path, []byte(script.String()), 0o644); err != nil {
+		return wrapError(err, "failed to write migration script '%s'", path)
+	}
+	return nil
+}
@@ -0,0 +1,568 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var dbDocsCmd = &cobra.Command{
+	Use:   "docs [database-name]",
+	Short: "Generate Markdown or HTML documentation of the schema",
+	Long: `Write one page per table to --out with its comment, row estimate and size,
+columns (type, nullability, default, comment), indexes, the foreign keys it
+has and those referencing it, plus an index page listing all tables with an
+ER diagram (Mermaid in Markdown, SVG in HTML).
+
+Comments come from COMMENT ON TABLE, COLUMN and INDEX. Pages are regenerated
+in place; pages of tables that no longer exist are removed, other files in
+the directory are left alone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		format, _ := cmd.Flags().GetString("format")
+		schemas, _ := cmd.Flags().GetStringArray("schema")
+		return writeSchemaDocs(args[0], out, format, schemas)
+	},
+}
+
+func init() {
+	dbDocsCmd.Flags().String("out", "", "Directory to write the pages to (required)")
+	dbDocsCmd.Flags().StringP("format", "f", docsMarkdown, "Page format: markdown, html")
+	dbDocsCmd.Flags().StringArray("schema", nil, "Only document tables of this schema (repeatable)")
+	dbCmd.AddCommand(dbDocsCmd)
+}
+
+// Page formats of db docs.
+const (
+	docsMarkdown = "markdown"
+	docsHTML     = "html"
+)
+
+// docsMarker starts every generated page, so stale pages can be told apart
+// from files someone else put in the directory.
+const docsMarker = "<!-- Generated by tools db docs, changes will be overwritten -->"
+
+type docColumn struct {
+	Name       string  `json:"name" db:"name"`
+	Type       string  `json:"type" db:"type"`
+	NotNull    bool    `json:"not_null" db:"not_null"`
+	Default    *string `json:"default" db:"default"`
+	Comment    *string `json:"comment" db:"comment"`
+	PrimaryKey bool    `json:"primary_key" db:"primary_key"`
+}
+
+type docIndex struct {
+	Name       string  `json:"name" db:"name"`
+	Definition string  `json:"definition" db:"definition"`
+	Primary    bool    `json:"primary" db:"primary"`
+	Unique     bool    `json:"unique" db:"unique"`
+	SizeBytes  int64   `json:"size_bytes" db:"size_bytes"`
+	Comment    *string `json:"comment" db:"comment"`
+}
+
+type docTable struct {
+	Schema       string        `json:"schema" db:"schema"`
+	Name         string        `json:"name" db:"name"`
+	Kind         string        `json:"kind" db:"kind"`
+	Comment      *string       `json:"comment" db:"comment"`
+	RowEstimate  *int64        `json:"row_estimate" db:"row_estimate"`
+	TableBytes   int64         `json:"table_bytes" db:"table_bytes"`
+	IndexBytes   int64         `json:"index_bytes" db:"index_bytes"`
+	Columns      []docColumn   `json:"columns" db:"-"`
+	Indexes      []docIndex    `json:"indexes" db:"-"`
+	References   []erdRelation `json:"references" db:"-"`
+	ReferencedBy []erdRelation `json:"referenced_by" db:"-"`
+}
+
+type schemaDocs struct {
+	Database  string     `json:"database"`
+	Generated time.Time  `json:"generated"`
+	Tables    []docTable `json:"tables"`
+
+	pages map[[2]string]string
+}
+
+// tables indexes the tables by schema and name.
+func (d *schemaDocs) tables() map[[2]string]*docTable {
+	tables := make(map[[2]string]*docTable, len(d.Tables))
+	for i := range d.Tables {
+		tables[[2]string{d.Tables[i].Schema, d.Tables[i].Name}] = &d.Tables[i]
+	}
+	return tables
+}
+
+// erd turns the documented tables into the model db erd draws.
+func (d *schemaDocs) erd() *erdModel {
+	model := &erdModel{Database: d.Database}
+	tables := d.tables()
+	for _, table := range d.Tables {
+		foreign := map[string]bool{}
+		for _, r := range table.References {
+			for _, column := range r.Columns {
+				foreign[column] = true
+			}
+		}
+		erd := erdTable{Schema: table.Schema, Name: table.Name}
+		for _, column := range table.Columns {
+			erd.Columns = append(erd.Columns, erdColumn{Name: column.Name, Type: column.Type, NotNull: column.NotNull,
+				PrimaryKey: column.PrimaryKey, ForeignKey: foreign[column.Name]})
+		}
+		model.Tables = append(model.Tables, erd)
+		for _, r := range table.References {
+			if tables[[2]string{r.RefSchema, r.RefTable}] != nil {
+				model.Relations = append(model.Relations, r)
+			}
+		}
+	}
+	return model
+}
+
+func loadSchemaDocs(ctx context.Context, dbName string, schemas []string) (*schemaDocs, error) {
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	version, err := catalogVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := &schemaDocs{Database: dbName, Generated: time.Now()}
+	docs.Tables, err = queryRecords[docTable](ctx, conn, `
+		SELECT
+			n.nspname AS schema,
+			c.relname AS name,
+			CASE c.relkind WHEN 'p' THEN 'partitioned table' ELSE 'table' END AS kind,
+			obj_description(c.oid, 'pg_class') AS comment,
+			CASE WHEN c.reltuples < 0 THEN NULL ELSE c.reltuples::bigint END AS row_estimate,
+			pg_table_size(c.oid) AS table_bytes,
+			pg_indexes_size(c.oid) AS index_bytes
+		FROM pg_class AS c
+		JOIN pg_namespace AS n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+schemaFilter+`
+		ORDER BY n.nspname, c.relname`, nilIfEmpty(schemas))
+	if err != nil {
+		return nil, wrapError(err, "failed to list tables")
+	}
+	tables := docs.tables()
+
+	type columnRow struct {
+		Schema string `db:"schema"`
+		Table  string `db:"table"`
+		docColumn
+	}
+	// Generated columns arrived in PostgreSQL 12
+	generated := `WHEN a.attgenerated = 's' THEN 'GENERATED ALWAYS AS (' || pg_get_expr(d.adbin, d.adrelid) || ') STORED'`
+	if version < 120000 {
+		generated = ""
+	}
+	columns, err := queryRecords[columnRow](ctx, conn, `
+		SELECT
+			n.nspname AS schema,
+			c.relname AS table,
+			a.attname AS name,
+			format_type(a.atttypid, a.atttypmod) AS type,
+			a.attnotnull AS not_null,
+			CASE
+				WHEN a.attidentity = 'a' THEN 'GENERATED ALWAYS AS IDENTITY'
+				WHEN a.attidentity = 'd' THEN 'GENERATED BY DEFAULT AS IDENTITY'
+				`+generated+`
+				ELSE pg_get_expr(d.adbin, d.adrelid)
+			END AS default,
+			col_description(c.oid, a.attnum) AS comment,
+			coalesce(a.attnum = ANY (pk.conkey), false) AS primary_key
+		FROM pg_class AS c
+		JOIN pg_namespace AS n ON n.oid = c.relnamespace
+		JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		LEFT JOIN pg_attrdef AS d ON d.adrelid = c.oid AND d.adnum = a.attnum
+		LEFT JOIN pg_constraint AS pk ON pk.conrelid = c.oid AND pk.contype = 'p'
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+schemaFilter+`
+		ORDER BY n.nspname, c.relname, a.attnum`, nilIfEmpty(schemas))
+	if err != nil {
+		return nil, wrapError(err, "failed to read columns")
+	}
+	for _, column := range columns {
+		if table := tables[[2]string{column.Schema, column.Table}]; table != nil {
+			table.Columns = append(table.Columns, column.docColumn)
+		}
+	}
+
+	type indexRow struct {
+		Schema string `db:"schema"`
+		Table  string `db:"table"`
+		docIndex
+	}
+	indexes, err := queryRecords[indexRow](ctx, conn, `
+		SELECT
+			n.nspname AS schema,
+			t.relname AS table,
+			i.relname AS name,
+			pg_get_indexdef(i.oid) AS definition,
+			x.indisprimary AS primary,
+			x.indisunique AS unique,
+			pg_relation_size(i.oid) AS size_bytes,
+			obj_description(i.oid, 'pg_class') AS comment
+		FROM pg_index AS x
+		JOIN pg_class AS i ON i.oid = x.indexrelid
+		JOIN pg_class AS t ON t.oid = x.indrelid
+		JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		WHERE t.relkind IN ('r', 'p') AND NOT t.relispartition AND `+schemaFilter+`
+		ORDER BY n.nspname, t.relname, NOT x.indisprimary, i.relname`, nilIfEmpty(schemas))
+	if err != nil {
+		return nil, wrapError(err, "failed to read indexes")
+	}
+	for _, index := range indexes {
+		if table := tables[[2]string{index.Schema, index.Table}]; table != nil {
+			table.Indexes = append(table.Indexes, index.docIndex)
+		}
+	}
+
+	relations, err := loadRelations(ctx, conn, version, schemas)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range relations {
+		if table := tables[[2]string{r.Schema, r.Table}]; table != nil {
+			table.References = append(table.References, r)
+		}
+		if table := tables[[2]string{r.RefSchema, r.RefTable}]; table != nil {
+			table.ReferencedBy = append(table.ReferencedBy, r)
+		}
+	}
+	return docs, nil
+}
+
+// docsPage names the page of a table, keeping only characters that are safe
+// in file names and URLs.
+func docsPage(schema, table, ext string) string {
+	name := []byte(schema + "." + table)
+	for i, c := range name {
+		if !isIdentifierByte(c) && c != '.' && c != '-' || c == '$' {
+			name[i] = '_'
+		}
+	}
+	return string(name) + ext
+}
+
+// page names the page of a table with docsPage, numbering the ones that
+// would collide, such as "my table" and my_table. Names differing only in
+// case collide too, for case-insensitive file systems.
+func (d *schemaDocs) page(schema, name, ext string) string {
+	if d.pages == nil {
+		d.pages = make(map[[2]string]string, len(d.Tables))
+		used := make(map[string]bool, len(d.Tables))
+		for _, table := range d.Tables {
+			base := docsPage(table.Schema, table.Name, "")
+			page := base
+			for n := 2; used[strings.ToLower(page)]; n++ {
+				page = fmt.Sprintf("%s_%d", base, n)
+			}
+			used[strings.ToLower(page)] = true
+			d.pages[[2]string{table.Schema, table.Name}] = page
+		}
+	}
+	if page, ok := d.pages[[2]string{schema, name}]; ok {
+		return page + ext
+	}
+	return docsPage(schema, name, ext)
+}
+
+func writeSchemaDocs(dbName, out, format string, schemas []string) error {
+	if out == "" {
+		return usageError("--out is required")
+	}
+	ext := ".md"
+	switch format {
+	case docsMarkdown:
+	case docsHTML:
+		ext = ".html"
+	default:
+		return usageError("invalid format '%s' (valid: markdown, html)", format)
+	}
+
+	ctx := context.Background()
+	docs, err := loadSchemaDocs(ctx, dbName, schemas)
+	if err != nil {
+		return err
+	}
+	if len(docs.Tables) == 0 {
+		return notFoundError("no tables found in database '%s'", dbName)
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return wrapError(err, "failed to create '%s'", out)
+	}
+
+	pages := map[string][]byte{}
+	for _, table := range docs.Tables {
+		var page bytes.Buffer
+		if format == docsHTML {
+			writeTableHTML(&page, docs, table)
+		} else {
+			writeTableMarkdown(&page, docs, table)
+		}
+		pages[docs.page(table.Schema, table.Name, ext)] = page.Bytes()
+	}
+	var index bytes.Buffer
+	if format == docsHTML {
+		writeIndexHTML(&index, docs)
+	} else {
+		writeIndexMarkdown(&index, docs)
+	}
+	pages["index"+ext] = index.Bytes()
+
+	for name, content := range pages {
+		if err := os.WriteFile(filepath.Join(out, name), content, 0o644); err != nil {
+			return wrapError(err, "failed to write '%s'", name)
+		}
+	}
+
+	// Remove our pages of tables that are gone
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		return wrapError(err, "failed to read '%s'", out)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := pages[name]; ok || entry.IsDir() || filepath.Ext(name) != ext {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil || !bytes.Contains(content[:min(len(content), 256)], []byte(docsMarker)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(out, name)); err == nil {
+			printProgress("Removed %s, its table no longer exists", name)
+		}
+	}
+
+	return reportAction("docs", out, "written", "✓ Documented %d tables of '%s' in %s (open %s)",
+		len(docs.Tables), dbName, out, filepath.Join(out, "index"+ext))
+}
+
+func docsTableKind(table docTable) string {
+	if table.Kind == "table" {
+		return ""
+	}
+	return " (" + table.Kind + ")"
+}
+
+func docsRows(table docTable) string {
+	if table.RowEstimate == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("~%d", *table.RowEstimate)
+}
+
+func docsNullable(column docColumn) string {
+	if column.NotNull {
+		return "not null"
+	}
+	return "null"
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// firstLine shortens a comment for the table list.
+func firstLine(comment *string) string {
+	line, _, _ := strings.Cut(deref(comment), "\n")
+	return line
+}
+
+// mdCell escapes text for a Markdown table cell.
+func mdCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "<br>")
+}
+
+// mdCode formats text as inline code in a Markdown table cell.
+func mdCode(text string) string {
+	if text == "" {
+		return ""
+	}
+	if strings.Contains(text, "`") {
+		return "``" + mdCell(text) + "``"
+	}
+	return "`" + mdCell(text) + "`"
+}
+
+func writeIndexMarkdown(w *bytes.Buffer, docs *schemaDocs) {
+	w.WriteString(docsMarker + "\n\n")
+	fmt.Fprintf(w, "# Database %s\n\n", docs.Database)
+	fmt.Fprintf(w, "Generated %s from the live schema, %d tables.\n\n", docs.Generated.Format(time.DateTime), len(docs.Tables))
+	w.WriteString("| Table | Rows | Size | Description |\n|---|---:|---:|---|\n")
+	for _, table := range docs.Tables {
+		fmt.Fprintf(w, "| [%s](%s)%s | %s | %s | %s |\n", mdCell(erdLabel(table.Schema, table.Name)),
+			docs.page(table.Schema, table.Name, ".md"), docsTableKind(table), docsRows(table),
+			formatBytes(table.TableBytes+table.IndexBytes), mdCell(firstLine(table.Comment)))
+	}
+	w.WriteString("\n## Diagram\n\n```mermaid\n")
+	writeMermaid(w, docs.erd())
+	w.WriteString("```\n")
+}
+
+func writeTableMarkdown(w *bytes.Buffer, docs *schemaDocs, table docTable) {
+	link := func(schema, name string) string {
+		return fmt.Sprintf("[%s](%s)", mdCell(erdLabel(schema, name)), docs.page(schema, name, ".md"))
+	}
+
+	w.WriteString(docsMarker + "\n\n")
+	fmt.Fprintf(w, "# %s%s\n\n", erdLabel(table.Schema, table.Name), docsTableKind(table))
+	if table.Comment != nil {
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(*table.Comment))
+	}
+	fmt.Fprintf(w, "**Rows (estimate):** %s · **Size:** %s (data %s, indexes %s)\n\n", docsRows(table),
+		formatBytes(table.TableBytes+table.IndexBytes), formatBytes(table.TableBytes), formatBytes(table.IndexBytes))
+
+	w.WriteString("## Columns\n\n| Column | Type | Nullable | Default | Description |\n|---|---|---|---|---|\n")
+	for _, column := range table.Columns {
+		name := mdCell(column.Name)
+		if column.PrimaryKey {
+			name = "**" + name + "** (PK)"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", name, mdCode(column.Type), docsNullable(column),
+			mdCode(deref(column.Default)), mdCell(deref(column.Comment)))
+	}
+
+	if len(table.Indexes) > 0 {
+		w.WriteString("\n## Indexes\n\n| Index | Definition | Size | Description |\n|---|---|---:|---|\n")
+		for _, index := range table.Indexes {
+			_, definition, _ := strings.Cut(index.Definition, " USING ")
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", mdCell(index.Name), mdCode(definition),
+				formatBytes(index.SizeBytes), mdCell(deref(index.Comment)))
+		}
+	}
+
+	if len(table.References) > 0 {
+		w.WriteString("\n## References\n\n| Columns | Table | Columns | On delete | Constraint |\n|---|---|---|---|---|\n")
+		for _, r := range table.References {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", mdCell(strings.Join(r.Columns, ", ")), link(r.RefSchema, r.RefTable),
+				mdCell(strings.Join(r.RefColumns, ", ")), r.OnDelete, mdCell(r.Name))
+		}
+	}
+	if len(table.ReferencedBy) > 0 {
+		w.WriteString("\n## Referenced by\n\n| Table | Columns | On delete | Constraint |\n|---|---|---|---|\n")
+		for _, r := range table.ReferencedBy {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", link(r.Schema, r.Table), mdCell(strings.Join(r.Columns, ", ")),
+				r.OnDelete, mdCell(r.Name))
+		}
+	}
+	fmt.Fprintf(w, "\n[Back to all tables](index.md)\n")
+}
+
+const docsStyle = `<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; padding: 0 1em; color: #222; }
+h1 { border-bottom: 2px solid #336791; padding-bottom: .3em; }
+table { border-collapse: collapse; margin: 1em 0; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f3f6f9; }
+td.num { text-align: right; }
+code { background: #f5f5f5; padding: 1px 4px; border-radius: 3px; }
+a { color: #336791; }
+.meta { color: #666; }
+.diagram { overflow-x: auto; border: 1px solid #ddd; padding: 1em; }
+</style>`
+
+func writeHTMLHead(w *bytes.Buffer, title string) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n%s\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n%s\n</head>\n<body>\n",
+		docsMarker, html.EscapeString(title), docsStyle)
+}
+
+func htmlCode(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<code>" + html.EscapeString(text) + "</code>"
+}
+
+// htmlText escapes text and keeps its line breaks.
+func htmlText(text string) string {
+	return strings.ReplaceAll(html.EscapeString(strings.TrimSpace(text)), "\n", "<br>")
+}
+
+func writeIndexHTML(w *bytes.Buffer, docs *schemaDocs) {
+	writeHTMLHead(w, "Database "+docs.Database)
+	fmt.Fprintf(w, "<h1>Database %s</h1>\n", html.EscapeString(docs.Database))
+	fmt.Fprintf(w, "<p class=\"meta\">Generated %s from the live schema, %d tables.</p>\n",
+		docs.Generated.Format(time.DateTime), len(docs.Tables))
+	w.WriteString("<table>\n<tr><th>Table</th><th>Rows</th><th>Size</th><th>Description</th></tr>\n")
+	for _, table := range docs.Tables {
+		fmt.Fprintf(w, "<tr><td><a href=\"%s\">%s</a>%s</td><td class=\"num\">%s</td><td class=\"num\">%s</td><td>%s</td></tr>\n",
+			html.EscapeString(docs.page(table.Schema, table.Name, ".html")), html.EscapeString(erdLabel(table.Schema, table.Name)),
+			html.EscapeString(docsTableKind(table)), docsRows(table), formatBytes(table.TableBytes+table.IndexBytes),
+			htmlText(firstLine(table.Comment)))
+	}
+	w.WriteString("</table>\n<h2>Diagram</h2>\n<div class=\"diagram\">\n")
+	writeERDSVG(w, docs.erd())
+	w.WriteString("</div>\n</body>\n</html>\n")
+}
+
+func writeTableHTML(w *bytes.Buffer, docs *schemaDocs, table docTable) {
+	link := func(schema, name string) string {
+		return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(docs.page(schema, name, ".html")),
+			html.EscapeString(erdLabel(schema, name)))
+	}
+	label := erdLabel(table.Schema, table.Name)
+
+	writeHTMLHead(w, label)
+	fmt.Fprintf(w, "<p><a href=\"index.html\">All tables</a></p>\n<h1>%s%s</h1>\n", html.EscapeString(label),
+		html.EscapeString(docsTableKind(table)))
+	if table.Comment != nil {
+		fmt.Fprintf(w, "<p>%s</p>\n", htmlText(*table.Comment))
+	}
+	fmt.Fprintf(w, "<p class=\"meta\">Rows (estimate): %s · Size: %s (data %s, indexes %s)</p>\n", docsRows(table),
+		formatBytes(table.TableBytes+table.IndexBytes), formatBytes(table.TableBytes), formatBytes(table.IndexBytes))
+
+	w.WriteString("<h2>Columns</h2>\n<table>\n<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Default</th><th>Description</th></tr>\n")
+	for _, column := range table.Columns {
+		name := html.EscapeString(column.Name)
+		if column.PrimaryKey {
+			name = "<strong>" + name + "</strong> (PK)"
+		}
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n", name, htmlCode(column.Type),
+			docsNullable(column), htmlCode(deref(column.Default)), htmlText(deref(column.Comment)))
+	}
+	w.WriteString("</table>\n")
+
+	if len(table.Indexes) > 0 {
+		w.WriteString("<h2>Indexes</h2>\n<table>\n<tr><th>Index</th><th>Definition</th><th>Size</th><th>Description</th></tr>\n")
+		for _, index := range table.Indexes {
+			_, definition, _ := strings.Cut(index.Definition, " USING ")
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td class=\"num\">%s</td><td>%s</td></tr>\n", html.EscapeString(index.Name),
+				htmlCode(definition), formatBytes(index.SizeBytes), htmlText(deref(index.Comment)))
+		}
+		w.WriteString("</table>\n")
+	}
+
+	if len(table.References) > 0 {
+		w.WriteString("<h2>References</h2>\n<table>\n<tr><th>Columns</th><th>Table</th><th>Columns</th><th>On delete</th><th>Constraint</th></tr>\n")
+		for _, r := range table.References {
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(strings.Join(r.Columns, ", ")), link(r.RefSchema, r.RefTable),
+				html.EscapeString(strings.Join(r.RefColumns, ", ")), r.OnDelete, html.EscapeString(r.Name))
+		}
+		w.WriteString("</table>\n")
+	}
+	if len(table.ReferencedBy) > 0 {
+		w.WriteString("<h2>Referenced by</h2>\n<table>\n<tr><th>Table</th><th>Columns</th><th>On delete</th><th>Constraint</th></tr>\n")
+		for _, r := range table.ReferencedBy {
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n", link(r.Schema, r.Table),
+				html.EscapeString(strings.Join(r.Columns, ", ")), r.OnDelete, html.EscapeString(r.Name))
+		}
+		w.WriteString("</table>\n")
+	}
+	w.WriteString("</body>\n</html>\n")
+}
@@ -0,0 +1,708 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbErdCmd = &cobra.Command{
+	Use:   "erd [database-name] [output-file]",
+	Short: "Generate an entity-relationship diagram from the schema",
+	Long: `Read the tables, columns, primary keys and foreign keys of a database and
+write them as a diagram to a file, an s3:// URL or stdout (output omitted or
+"-").
+
+Formats are mermaid (an erDiagram block), dot (Graphviz), plantuml and svg,
+a rendered drawing with parent tables to the left of the tables referencing
+them. The format is taken from --format, else from the output's extension
+(.dot, .gv, .puml, .plantuml, .svg), else mermaid; a .md file gets the
+mermaid diagram in a fenced code block.
+
+Tables from every schema except the system ones are included, or only those
+of --schema. Foreign keys to tables outside the selected schemas are left out.
+With --output json or yaml and no output file, the tables and relations are
+printed as data instead.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		schemas, _ := cmd.Flags().GetStringArray("schema")
+		target := "-"
+		if len(args) == 2 {
+			target = args[1]
+		}
+		return writeERD(args[0], target, format, schemas)
+	},
+}
+
+func init() {
+	dbErdCmd.Flags().StringP("format", "f", "", "Diagram format: mermaid, dot, plantuml, svg (default from output extension)")
+	dbErdCmd.Flags().StringArray("schema", nil, "Only include tables of this schema (repeatable)")
+	dbCmd.AddCommand(dbErdCmd)
+}
+
+// Diagram formats of db erd.
+const (
+	erdMermaid  = "mermaid"
+	erdDot      = "dot"
+	erdPlantUML = "plantuml"
+	erdSVG      = "svg"
+)
+
+var erdFormats = []string{erdMermaid, erdDot, erdPlantUML, erdSVG}
+
+type erdColumn struct {
+	Name       string `json:"name" db:"column"`
+	Type       string `json:"type" db:"type"`
+	NotNull    bool   `json:"not_null" db:"not_null"`
+	PrimaryKey bool   `json:"primary_key" db:"primary_key"`
+	ForeignKey bool   `json:"foreign_key" db:"foreign_key"`
+}
+
+type erdTable struct {
+	Schema  string      `json:"schema"`
+	Name    string      `json:"name"`
+	Columns []erdColumn `json:"columns"`
+}
+
+type erdRelation struct {
+	Name       string   `json:"name" db:"name"`
+	Schema     string   `json:"schema" db:"schema"`
+	Table      string   `json:"table" db:"table"`
+	Columns    []string `json:"columns" db:"columns"`
+	RefSchema  string   `json:"ref_schema" db:"ref_schema"`
+	RefTable   string   `json:"ref_table" db:"ref_table"`
+	RefColumns []string `json:"ref_columns" db:"ref_columns"`
+	// Required when no referencing column is nullable, Unique when the
+	// referencing columns are unique, making it one-to-one
+	Required bool   `json:"required" db:"required"`
+	Unique   bool   `json:"unique" db:"unique"`
+	OnDelete string `json:"on_delete" db:"on_delete"`
+	OnUpdate string `json:"on_update" db:"on_update"`
+}
+
+type erdModel struct {
+	Database  string        `json:"database"`
+	Tables    []erdTable    `json:"tables"`
+	Relations []erdRelation `json:"relations"`
+}
+
+// erdFormat picks the diagram format like dataFormat does for data files.
+func erdFormat(flag, location string) (string, error) {
+	if flag != "" {
+		if !slices.Contains(erdFormats, flag) {
+			return "", usageError("invalid format '%s' (valid: %s)", flag, strings.Join(erdFormats, ", "))
+		}
+		return flag, nil
+	}
+	switch strings.ToLower(path.Ext(location)) {
+	case ".dot", ".gv":
+		return erdDot, nil
+	case ".puml", ".plantuml":
+		return erdPlantUML, nil
+	case ".svg":
+		return erdSVG, nil
+	}
+	return erdMermaid, nil
+}
+
+// schemaFilter matches the given schemas, or all but the system ones when
+// $1 is NULL.
+const schemaFilter = `(($1::text[] IS NULL AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg\_%') OR n.nspname = ANY ($1))`
+
+// loadERDModel reads the tables and foreign keys of the selected schemas.
+// Partitions are left out, their parent stands for them.
+func loadERDModel(ctx context.Context, conn *pgx.Conn, dbName string, schemas []string) (*erdModel, error) {
+	version, err := catalogVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	type columnRow struct {
+		Schema string `db:"schema"`
+		Table  string `db:"table"`
+		erdColumn
+	}
+	columns, err := queryRecords[columnRow](ctx, conn, `
+		SELECT
+			n.nspname AS schema,
+			c.relname AS table,
+			a.attname AS column,
+			format_type(a.atttypid, a.atttypmod) AS type,
+			a.attnotnull AS not_null,
+			coalesce(a.attnum = ANY (pk.conkey), false) AS primary_key,
+			EXISTS (
+				SELECT 1 FROM pg_constraint AS fk
+				WHERE fk.conrelid = c.oid AND fk.contype = 'f' AND a.attnum = ANY (fk.conkey)
+			) AS foreign_key
+		FROM pg_class AS c
+		JOIN pg_namespace AS n ON n.oid = c.relnamespace
+		JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		LEFT JOIN pg_constraint AS pk ON pk.conrelid = c.oid AND pk.contype = 'p'
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+schemaFilter+`
+		ORDER BY n.nspname, c.relname, a.attnum`, nilIfEmpty(schemas))
+	if err != nil {
+		return nil, wrapError(err, "failed to read columns")
+	}
+
+	model := &erdModel{Database: dbName, Tables: []erdTable{}, Relations: []erdRelation{}}
+	for _, column := range columns {
+		last := len(model.Tables) - 1
+		if last < 0 || model.Tables[last].Schema != column.Schema || model.Tables[last].Name != column.Table {
+			model.Tables = append(model.Tables, erdTable{Schema: column.Schema, Name: column.Table})
+			last++
+		}
+		model.Tables[last].Columns = append(model.Tables[last].Columns, column.erdColumn)
+	}
+
+	relations, err := loadRelations(ctx, conn, version, schemas)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations {
+		if model.table(relation.RefSchema, relation.RefTable) != nil {
+			model.Relations = append(model.Relations, relation)
+		}
+	}
+	return model, nil
+}
+
+// loadRelations reads the foreign keys of tables in the selected schemas.
+func loadRelations(ctx context.Context, conn *pgx.Conn, version int, schemas []string) ([]erdRelation, error) {
+	// Indexes have non-key INCLUDE columns since PostgreSQL 11
+	keyColumns := "i.indnkeyatts"
+	if version < 110000 {
+		keyColumns = "i.indnatts"
+	}
+	relations, err := queryRecords[erdRelation](ctx, conn, `
+		SELECT
+			c.conname AS name,
+			n.nspname AS schema,
+			t.relname AS table,
+			ARRAY(
+				SELECT a.attname::text FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, i)
+				JOIN pg_attribute AS a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+				ORDER BY k.i
+			) AS columns,
+			rn.nspname AS ref_schema,
+			r.relname AS ref_table,
+			ARRAY(
+				SELECT a.attname::text FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, i)
+				JOIN pg_attribute AS a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+				ORDER BY k.i
+			) AS ref_columns,
+			NOT EXISTS (
+				SELECT 1 FROM pg_attribute AS a
+				WHERE a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey) AND NOT a.attnotnull
+			) AS required,
+			EXISTS (
+				SELECT 1 FROM pg_index AS i
+				WHERE i.indrelid = c.conrelid AND i.indisunique AND i.indpred IS NULL
+					AND `+keyColumns+` = cardinality(c.conkey)
+					AND (i.indkey::int2[])[0:`+keyColumns+` - 1] @> c.conkey
+			) AS unique,
+			`+referentialAction("c.confdeltype")+` AS on_delete,
+			`+referentialAction("c.confupdtype")+` AS on_update
+		FROM pg_constraint AS c
+		JOIN pg_class AS t ON t.oid = c.conrelid
+		JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		JOIN pg_class AS r ON r.oid = c.confrelid
+		JOIN pg_namespace AS rn ON rn.oid = r.relnamespace
+		WHERE c.contype = 'f' AND NOT t.relispartition AND NOT r.relispartition AND `+schemaFilter+`
+		ORDER BY n.nspname, t.relname, c.conname`, nilIfEmpty(schemas))
+	if err != nil {
+		return nil, wrapError(err, "failed to read foreign keys")
+	}
+	return relations, nil
+}
+
+// referentialAction spells out a pg_constraint action code.
+func referentialAction(column string) string {
+	return `CASE ` + column + ` WHEN 'a' THEN 'no action' WHEN 'r' THEN 'restrict' WHEN 'c' THEN 'cascade'
+				WHEN 'n' THEN 'set null' WHEN 'd' THEN 'set default' ELSE '' END`
+}
+
+// catalogVersion returns the server_version_num of the server. The catalog
+// queries of db erd and db docs rely on partitions and identity columns,
+// which PostgreSQL 10 introduced.
+func catalogVersion(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var version int
+	var name string
+	err := conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int, current_setting('server_version')").
+		Scan(&version, &name)
+	if err != nil {
+		return 0, wrapError(err, "failed to read server version")
+	}
+	if version < 100000 {
+		return 0, newError(KindGeneral, "PostgreSQL 10 or later is required, the server runs %s", name)
+	}
+	return version, nil
+}
+
+// nilIfEmpty turns an empty list into NULL for schemaFilter.
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+func (m *erdModel) table(schema, name string) *erdTable {
+	for i := range m.Tables {
+		if m.Tables[i].Schema == schema && m.Tables[i].Name == name {
+			return &m.Tables[i]
+		}
+	}
+	return nil
+}
+
+// erdLabel names a table as the other db commands print it.
+func erdLabel(schema, name string) string {
+	if schema == "public" {
+		return name
+	}
+	return schema + "." + name
+}
+
+// ids assigns each table an identifier from erdID, numbering the ones that
+// would collide, such as a.b and public.a_b.
+func (m *erdModel) ids() map[[2]string]string {
+	ids := make(map[[2]string]string, len(m.Tables))
+	used := make(map[string]bool, len(m.Tables))
+	for _, table := range m.Tables {
+		base := erdID(table.Schema, table.Name)
+		id := base
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[id] = true
+		ids[[2]string{table.Schema, table.Name}] = id
+	}
+	return ids
+}
+
+// erdID turns a table name into an identifier every diagram language accepts.
+func erdID(schema, name string) string {
+	id := []byte(erdLabel(schema, name))
+	for i, c := range id {
+		if !isIdentifierByte(c) || c == '$' {
+			id[i] = '_'
+		}
+	}
+	if len(id) > 0 && id[0] >= '0' && id[0] <= '9' {
+		return "t_" + string(id)
+	}
+	return string(id)
+}
+
+func writeERD(dbName, target, format string, schemas []string) error {
+	format, err := erdFormat(format, target)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	model, err := loadERDModel(ctx, conn, dbName, schemas)
+	if err != nil {
+		return err
+	}
+	if len(model.Tables) == 0 {
+		return notFoundError("no tables found in database '%s'", dbName)
+	}
+	if target == "-" {
+		if ok, err := renderOutput(model); ok {
+			return err
+		}
+	}
+
+	var diagram bytes.Buffer
+	contentType := "text/plain"
+	switch format {
+	case erdMermaid:
+		// Markdown renders a fenced mermaid block, e.g. on GitHub
+		markdown := strings.ToLower(path.Ext(target)) == ".md"
+		if markdown {
+			diagram.WriteString("```mermaid\n")
+		}
+		writeMermaid(&diagram, model)
+		if markdown {
+			diagram.WriteString("```\n")
+		}
+	case erdDot:
+		writeDot(&diagram, model)
+	case erdPlantUML:
+		writePlantUML(&diagram, model)
+	case erdSVG:
+		writeERDSVG(&diagram, model)
+		contentType = "image/svg+xml"
+	}
+
+	out, err := openTarget(ctx, target, contentType)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(diagram.Bytes()); err != nil {
+		out.Abort(err)
+		return wrapError(err, "failed to write '%s'", target)
+	}
+	if err := out.Close(); err != nil {
+		return wrapError(err, "failed to write '%s'", target)
+	}
+	if target == "-" {
+		return nil
+	}
+	return reportAction("erd", target, "written", "✓ Wrote %s diagram of %d tables and %d relations to %s",
+		format, len(model.Tables), len(model.Relations), target)
+}
+
+// mermaidType makes a column type a single word, as erDiagram requires.
+func mermaidType(sqlType string) string {
+	return strings.NewReplacer(" ", "_", ",", "_", `"`, "").Replace(sqlType)
+}
+
+// mermaidName turns a column name into an attribute name Mermaid accepts,
+// which is ASCII letters, digits and underscores only.
+func mermaidName(name string) string {
+	safe := []rune(name)
+	for i, r := range safe {
+		if r >= 0x80 || !isIdentifierByte(byte(r)) || r == '$' {
+			safe[i] = '_'
+		}
+	}
+	if len(safe) == 0 || safe[0] >= '0' && safe[0] <= '9' {
+		return "c_" + string(safe)
+	}
+	return string(safe)
+}
+
+func erdKeys(column erdColumn) []string {
+	var keys []string
+	if column.PrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if column.ForeignKey {
+		keys = append(keys, "FK")
+	}
+	return keys
+}
+
+func writeMermaid(w *bytes.Buffer, model *erdModel) {
+	ids := model.ids()
+	w.WriteString("erDiagram\n")
+	for _, table := range model.Tables {
+		id, label := ids[[2]string{table.Schema, table.Name}], erdLabel(table.Schema, table.Name)
+		if id == label {
+			fmt.Fprintf(w, "    %s {\n", id)
+		} else {
+			fmt.Fprintf(w, "    %s[\"%s\"] {\n", id, label)
+		}
+		for _, column := range table.Columns {
+			name := mermaidName(column.Name)
+			fmt.Fprintf(w, "        %s %s", mermaidType(column.Type), name)
+			if keys := erdKeys(column); len(keys) > 0 {
+				fmt.Fprintf(w, " %s", strings.Join(keys, ", "))
+			}
+			// Keep the real name visible when it had to change
+			if name != column.Name {
+				fmt.Fprintf(w, " \"%s\"", strings.ReplaceAll(column.Name, `"`, "'"))
+			}
+			w.WriteString("\n")
+		}
+		w.WriteString("    }\n")
+	}
+	for _, r := range model.Relations {
+		fmt.Fprintf(w, "    %s %s %s : \"%s\"\n", ids[[2]string{r.RefSchema, r.RefTable}], crowsFoot(r),
+			ids[[2]string{r.Schema, r.Table}], strings.Join(r.Columns, ", "))
+	}
+}
+
+// crowsFoot is the relationship in the notation shared by Mermaid and
+// PlantUML, read from the referenced table to the referencing one.
+func crowsFoot(r erdRelation) string {
+	parent := "|o"
+	if r.Required {
+		parent = "||"
+	}
+	child := "o{"
+	if r.Unique {
+		child = "o|"
+	}
+	return parent + "--" + child
+}
+
+func writePlantUML(w *bytes.Buffer, model *erdModel) {
+	ids := model.ids()
+	w.WriteString("@startuml\nhide circle\nskinparam linetype ortho\n\n")
+	for _, table := range model.Tables {
+		fmt.Fprintf(w, "entity \"%s\" as %s {\n", erdLabel(table.Schema, table.Name), ids[[2]string{table.Schema, table.Name}])
+		var keys, rest []erdColumn
+		for _, column := range table.Columns {
+			if column.PrimaryKey {
+				keys = append(keys, column)
+			} else {
+				rest = append(rest, column)
+			}
+		}
+		line := func(column erdColumn) {
+			mark := ""
+			if column.NotNull {
+				mark = "*"
+			}
+			fmt.Fprintf(w, "  %s%s : %s", mark, column.Name, column.Type)
+			for _, key := range erdKeys(column) {
+				fmt.Fprintf(w, " <<%s>>", key)
+			}
+			w.WriteString("\n")
+		}
+		for _, column := range keys {
+			line(column)
+		}
+		if len(keys) > 0 && len(rest) > 0 {
+			w.WriteString("  --\n")
+		}
+		for _, column := range rest {
+			line(column)
+		}
+		w.WriteString("}\n\n")
+	}
+	for _, r := range model.Relations {
+		fmt.Fprintf(w, "%s %s %s : %s\n", ids[[2]string{r.RefSchema, r.RefTable}], crowsFoot(r),
+			ids[[2]string{r.Schema, r.Table}], strings.Join(r.Columns, ", "))
+	}
+	w.WriteString("@enduml\n")
+}
+
+func writeDot(w *bytes.Buffer, model *erdModel) {
+	ids := model.ids()
+	w.WriteString("digraph erd {\n")
+	w.WriteString("  graph [rankdir=RL, splines=true, nodesep=0.6, ranksep=1.2];\n")
+	w.WriteString("  node [shape=plaintext, fontname=\"Helvetica\", fontsize=11];\n")
+	w.WriteString("  edge [arrowhead=none, arrowtail=crow, dir=both];\n\n")
+	for _, table := range model.Tables {
+		fmt.Fprintf(w, "  %s [label=<\n", ids[[2]string{table.Schema, table.Name}])
+		w.WriteString("    <table border=\"0\" cellborder=\"1\" cellspacing=\"0\" cellpadding=\"4\">\n")
+		fmt.Fprintf(w, "      <tr><td bgcolor=\"#336791\" colspan=\"2\"><font color=\"white\"><b>%s</b></font></td></tr>\n",
+			html.EscapeString(erdLabel(table.Schema, table.Name)))
+		for _, column := range table.Columns {
+			name := html.EscapeString(column.Name)
+			if column.PrimaryKey {
+				name = "<b>" + name + "</b>"
+			}
+			if keys := erdKeys(column); len(keys) > 0 {
+				name += " <font color=\"#888888\">" + strings.Join(keys, ",") + "</font>"
+			}
+			fmt.Fprintf(w, "      <tr><td port=\"%s\" align=\"left\">%s</td><td align=\"left\"><font color=\"#555555\">%s</font></td></tr>\n",
+				html.EscapeString(column.Name), name, html.EscapeString(column.Type))
+		}
+		w.WriteString("    </table>\n  >];\n")
+	}
+	w.WriteString("\n")
+	for _, r := range model.Relations {
+		style := ""
+		if !r.Required {
+			style = ", style=dashed"
+		}
+		if r.Unique {
+			style += ", arrowtail=tee"
+		}
+		fmt.Fprintf(w, "  %s:\"%s\" -> %s:\"%s\" [tooltip=\"%s\"%s];\n",
+			ids[[2]string{r.Schema, r.Table}], r.Columns[0], ids[[2]string{r.RefSchema, r.RefTable}], r.RefColumns[0], r.Name, style)
+	}
+	w.WriteString("}\n")
+}
+
+// SVG layout: tables are boxes placed in columns by their depth in the
+// foreign key graph, referenced tables to the left.
+const (
+	svgMargin     = 20.0
+	svgColumnGap  = 90.0
+	svgTableGap   = 30.0
+	svgHeaderH    = 26.0
+	svgRowH       = 18.0
+	svgCharW      = 7.2
+	svgHeaderChar = 8.0
+)
+
+type svgBox struct {
+	table *erdTable
+	x, y  float64
+	w, h  float64
+	rank  int
+}
+
+func (b *svgBox) rowY(column string) float64 {
+	for i, c := range b.table.Columns {
+		if c.Name == column {
+			return b.y + svgHeaderH + svgRowH*float64(i) + svgRowH/2
+		}
+	}
+	return b.y + svgHeaderH/2
+}
+
+func layoutERD(model *erdModel) (map[[2]string]*svgBox, [][]*svgBox) {
+	boxes := make(map[[2]string]*svgBox, len(model.Tables))
+	for i := range model.Tables {
+		table := &model.Tables[i]
+		width := float64(len(erdLabel(table.Schema, table.Name)))*svgHeaderChar + 20
+		for _, column := range table.Columns {
+			width = max(width, float64(len(column.Name)+len(column.Type)+6)*svgCharW+20)
+		}
+		boxes[[2]string{table.Schema, table.Name}] = &svgBox{
+			table: table,
+			w:     width,
+			h:     svgHeaderH + svgRowH*float64(len(table.Columns)) + 4,
+		}
+	}
+
+	// Longest path from the referenced tables, bounded so cycles end
+	for range model.Tables {
+		changed := false
+		for _, r := range model.Relations {
+			child, parent := boxes[[2]string{r.Schema, r.Table}], boxes[[2]string{r.RefSchema, r.RefTable}]
+			if child != parent && child.rank < parent.rank+1 && parent.rank+1 < len(model.Tables) {
+				child.rank = parent.rank + 1
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var columns [][]*svgBox
+	for i := range model.Tables {
+		box := boxes[[2]string{model.Tables[i].Schema, model.Tables[i].Name}]
+		for len(columns) <= box.rank {
+			columns = append(columns, nil)
+		}
+		columns[box.rank] = append(columns[box.rank], box)
+	}
+
+	// Order each column by the average position of the tables it references
+	position := map[*svgBox]float64{}
+	for rank, column := range columns {
+		if rank > 0 {
+			weight := func(box *svgBox) float64 {
+				sum, n := 0.0, 0.0
+				for _, r := range model.Relations {
+					if boxes[[2]string{r.Schema, r.Table}] == box {
+						if p, ok := position[boxes[[2]string{r.RefSchema, r.RefTable}]]; ok {
+							sum, n = sum+p, n+1
+						}
+					}
+				}
+				if n == 0 {
+					return 1e9
+				}
+				return sum / n
+			}
+			slices.SortStableFunc(column, func(a, b *svgBox) int {
+				wa, wb := weight(a), weight(b)
+				switch {
+				case wa < wb:
+					return -1
+				case wa > wb:
+					return 1
+				}
+				return 0
+			})
+		}
+		for i, box := range column {
+			position[box] = float64(i)
+		}
+	}
+
+	x := svgMargin
+	for _, column := range columns {
+		y, width := svgMargin, 0.0
+		for _, box := range column {
+			box.x, box.y = x, y
+			y += box.h + svgTableGap
+			width = max(width, box.w)
+		}
+		x += width + svgColumnGap
+	}
+	return boxes, columns
+}
+
+func writeERDSVG(w *bytes.Buffer, model *erdModel) {
+	boxes, columns := layoutERD(model)
+	width, height := 0.0, 0.0
+	for _, column := range columns {
+		for _, box := range column {
+			width = max(width, box.x+box.w)
+			height = max(height, box.y+box.h)
+		}
+	}
+	width += svgMargin + svgColumnGap/2
+	height += svgMargin
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="monospace" font-size="12">`+"\n",
+		width, height, width, height)
+	w.WriteString(`<defs><marker id="one" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="10" markerHeight="10" orient="auto">` +
+		`<path d="M9,0 L9,10 M6,0 L6,10" stroke="#555" fill="none"/></marker>` +
+		`<marker id="many" viewBox="0 0 10 10" refX="0" refY="5" markerWidth="10" markerHeight="10" orient="auto">` +
+		`<path d="M0,0 L9,5 L0,10 M0,5 L9,5" stroke="#555" fill="none"/></marker></defs>` + "\n")
+	fmt.Fprintf(w, "<title>%s</title>\n", html.EscapeString(model.Database))
+
+	// Edges first so tables are drawn over them
+	for _, r := range model.Relations {
+		child, parent := boxes[[2]string{r.Schema, r.Table}], boxes[[2]string{r.RefSchema, r.RefTable}]
+		y1, y2 := child.rowY(r.Columns[0]), parent.rowY(r.RefColumns[0])
+		var d string
+		if parent.x < child.x {
+			x1, x2 := child.x, parent.x+parent.w
+			dx := max(30, (x1-x2)/2)
+			d = fmt.Sprintf("M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f", x1, y1, x1-dx, y1, x2+dx, y2, x2, y2)
+		} else {
+			// Same column or a cycle: loop around the right side
+			x1, x2 := child.x+child.w, parent.x+parent.w
+			out := max(x1, x2) + 40
+			d = fmt.Sprintf("M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f", x1, y1, out, y1, out, y2, x2, y2)
+		}
+		dash := ""
+		if !r.Required {
+			dash = ` stroke-dasharray="5,3"`
+		}
+		fmt.Fprintf(w, `<path d="%s" stroke="#555" fill="none"%s marker-start="url(#many)" marker-end="url(#one)"><title>%s</title></path>`+"\n",
+			d, dash, html.EscapeString(r.Name))
+	}
+
+	for _, column := range columns {
+		for _, box := range column {
+			label := erdLabel(box.table.Schema, box.table.Name)
+			fmt.Fprintf(w, `<g><rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#fff" stroke="#336791"/>`+"\n",
+				box.x, box.y, box.w, box.h)
+			fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#336791"/>`+"\n", box.x, box.y, box.w, svgHeaderH)
+			fmt.Fprintf(w, `<text x="%.1f" y="%.1f" fill="#fff" font-weight="bold" text-anchor="middle">%s</text>`+"\n",
+				box.x+box.w/2, box.y+17, html.EscapeString(label))
+			for i, c := range box.table.Columns {
+				y := box.y + svgHeaderH + svgRowH*float64(i) + 13
+				key, weight := strings.Join(erdKeys(c), ","), ""
+				if c.PrimaryKey {
+					weight = ` font-weight="bold"`
+				}
+				fmt.Fprintf(w, `<text x="%.1f" y="%.1f" fill="#888" font-size="9">%s</text>`, box.x+6, y, key)
+				fmt.Fprintf(w, `<text x="%.1f" y="%.1f"%s>%s</text>`, box.x+6+4*svgCharW, y, weight, html.EscapeString(c.Name))
+				fmt.Fprintf(w, `<text x="%.1f" y="%.1f" fill="#555" text-anchor="end">%s</text>`+"\n",
+					box.x+box.w-6, y, html.EscapeString(c.Type))
+			}
+			w.WriteString("</g>\n")
+		}
+	}
+	w.WriteString("</svg>\n")
+}
@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/minio/minio-go/v7"
+	"github.com/parquet-go/parquet-go"
+	"github.com/spf13/cobra"
+)
+
+var dbExportCmd = &cobra.Command{
+	Use:   "export [database-name] [table|query] [target]",
+	Short: "Export a table or query result as CSV, JSON Lines or Parquet",
+	Long: `Stream the rows of a table, or of a query starting with SELECT, WITH, VALUES
+or TABLE, to a file, an s3:// URL or stdout (target omitted or "-").
+
+The format is taken from --format, else from the target's extension (.csv,
+.jsonl, .ndjson, .parquet), else csv. CSV is written by COPY with a header
+row and JSON Lines by row_to_json, so values look as PostgreSQL prints them.
+Parquet maps booleans, integers, floats, dates, timestamps, json and bytea to
+their Parquet types and everything else, numeric included, to strings.`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts exportOptions
+		opts.Format, _ = cmd.Flags().GetString("format")
+		opts.NoHeader, _ = cmd.Flags().GetBool("no-header")
+		target := "-"
+		if len(args) == 3 {
+			target = args[2]
+		}
+		return exportData(args[0], args[1], target, opts)
+	},
+}
+
+func init() {
+	dbExportCmd.Flags().StringP("format", "f", "", "Data format: csv, jsonl, parquet (default from target extension)")
+	dbExportCmd.Flags().Bool("no-header", false, "Leave out the CSV header row")
+	dbCmd.AddCommand(dbExportCmd)
+}
+
+// Data formats of db export and db import.
+const (
+	dataCSV     = "csv"
+	dataJSONL   = "jsonl"
+	dataParquet = "parquet"
+)
+
+var dataFormats = []string{dataCSV, dataJSONL, dataParquet}
+
+// parquetRowBatch is how many rows are handed to the Parquet writer at once.
+const parquetRowBatch = 1000
+
+type exportOptions struct {
+	Format   string
+	NoHeader bool
+}
+
+type exportRecord struct {
+	Database  string `json:"database"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Format    string `json:"format"`
+	Rows      int64  `json:"rows"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// dataFormat resolves the format of a data file from the flag or the
+// location's extension.
+func dataFormat(flag, location string) (string, error) {
+	if flag != "" {
+		if !slices.Contains(dataFormats, flag) {
+			return "", usageError("invalid format '%s' (valid: %s)", flag, strings.Join(dataFormats, ", "))
+		}
+		return flag, nil
+	}
+	switch strings.ToLower(path.Ext(location)) {
+	case ".jsonl", ".ndjson", ".json":
+		return dataJSONL, nil
+	case ".parquet":
+		return dataParquet, nil
+	}
+	return dataCSV, nil
+}
+
+// exportQuery returns the query selecting what to export: source itself if
+// it is a query, else all rows of the table it names.
+func exportQuery(source string) (string, error) {
+	fields := strings.Fields(source)
+	if len(fields) > 1 {
+		switch strings.ToLower(fields[0]) {
+		case "select", "with", "values", "table":
+			return strings.TrimRight(strings.TrimSpace(source), ";"), nil
+		}
+	}
+	schema, table, err := parseQualifiedName(source)
+	if err != nil {
+		return "", usageError("invalid table name '%s': %w", source, err)
+	}
+	return "SELECT * FROM " + qualifiedName(schema, table), nil
+}
+
+// dataTarget is where exported data goes.
+type dataTarget interface {
+	io.Writer
+	// Close finishes the target, e.g. completes the upload.
+	Close() error
+	// Abort discards what was written so far.
+	Abort(err error)
+}
+
+type stdoutTarget struct{ io.Writer }
+
+func (stdoutTarget) Close() error  { return nil }
+func (stdoutTarget) Abort(_ error) {}
+
+type fileTarget struct{ *os.File }
+
+func (f fileTarget) Abort(_ error) {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// s3Target streams into a multipart upload through a pipe.
+type s3Target struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (t *s3Target) Close() error {
+	t.PipeWriter.Close()
+	return <-t.done
+}
+
+func (t *s3Target) Abort(err error) {
+	t.CloseWithError(err)
+	<-t.done
+}
+
+// openTarget opens a local path, an s3:// URL or stdout ("-") for writing.
+func openTarget(ctx context.Context, location, contentType string) (dataTarget, error) {
+	if location == "-" {
+		return stdoutTarget{os.Stdout}, nil
+	}
+	if !strings.HasPrefix(location, "s3://") {
+		file, err := os.Create(location)
+		if err != nil {
+			return nil, wrapError(err, "failed to create '%s'", location)
+		}
+		return fileTarget{file}, nil
+	}
+
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return nil, usageError("'%s' names a prefix, not an object", location)
+	}
+	client, err := getMinIOClient()
+	if err != nil {
+		return nil, connectionError("failed to connect to MinIO: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	target := &s3Target{PipeWriter: writer, done: make(chan error, 1)}
+	go func() {
+		_, err := client.PutObject(ctx, bucket, key, reader, -1, minio.PutObjectOptions{
+			PartSize:    backupPartSize,
+			ContentType: contentType,
+		})
+		// Unblock the writer if the upload gave up early
+		reader.CloseWithError(err)
+		target.done <- err
+	}()
+	return target, nil
+}
+
+func exportData(dbName, source, location string, opts exportOptions) error {
+	format, err := dataFormat(opts.Format, location)
+	if err != nil {
+		return err
+	}
+	query, err := exportQuery(source)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	contentType := map[string]string{
+		dataCSV:     "text/csv",
+		dataJSONL:   "application/x-ndjson",
+		dataParquet: "application/vnd.apache.parquet",
+	}[format]
+	target, err := openTarget(ctx, location, contentType)
+	if err != nil {
+		return err
+	}
+	if location != "-" {
+		printProgress("Exporting %s from database '%s' to '%s'...", format, dbName, location)
+	}
+
+	started := time.Now()
+	counter := &countingWriter{w: target}
+	var rows int64
+	switch format {
+	case dataCSV:
+		rows, err = exportCSV(ctx, conn, query, counter, !opts.NoHeader)
+	case dataJSONL:
+		rows, err = exportJSONL(ctx, conn, query, counter)
+	case dataParquet:
+		rows, err = exportParquet(ctx, conn, query, counter)
+	}
+	if err != nil {
+		target.Abort(err)
+		return wrapError(err, "failed to export from database '%s'", dbName)
+	}
+	if err := target.Close(); err != nil {
+		return wrapError(err, "failed to write '%s'", location)
+	}
+
+	// Data on stdout leaves no room for a report
+	if location == "-" {
+		return nil
+	}
+	record := exportRecord{
+		Database:  dbName,
+		Source:    source,
+		Target:    location,
+		Format:    format,
+		Rows:      rows,
+		SizeBytes: counter.n.Load(),
+	}
+	if ok, err := renderOutput(record); ok {
+		return err
+	}
+	color.Green("✓ Exported %d rows to '%s' (%s in %s)", rows, location, formatBytes(record.SizeBytes), time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+func exportCSV(ctx context.Context, conn *pgx.Conn, query string, w io.Writer, header bool) (int64, error) {
+	copySQL := fmt.Sprintf("COPY (%s) TO STDOUT WITH (FORMAT csv, HEADER %t)", query, header)
+	tag, err := conn.PgConn().CopyTo(ctx, w, copySQL)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func exportJSONL(ctx context.Context, conn *pgx.Conn, query string, w io.Writer) (int64, error) {
+	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT row_to_json(r)::text FROM (%s) AS r", query))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return count, err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// orderedGroup is a parquet.Group whose fields keep the order of the result
+// columns, where parquet.Group alone sorts them by name.
+type orderedGroup struct {
+	parquet.Group
+	order map[string]int
+}
+
+func (g orderedGroup) Fields() []parquet.Field {
+	fields := slices.Clone(g.Group.Fields())
+	slices.SortFunc(fields, func(a, b parquet.Field) int { return g.order[a.Name()] - g.order[b.Name()] })
+	return fields
+}
+
+// parquetColumn converts the text form of one result column to Parquet.
+type parquetColumn struct {
+	name  string
+	oid   uint32
+	index int
+}
+
+// parquetNode returns the Parquet type a PostgreSQL type is exported as.
+func parquetNode(oid uint32) parquet.Node {
+	switch oid {
+	case pgtype.BoolOID:
+		return parquet.Leaf(parquet.BooleanType)
+	case pgtype.Int2OID, pgtype.Int4OID:
+		return parquet.Int(32)
+	case pgtype.Int8OID:
+		return parquet.Int(64)
+	case pgtype.Float4OID:
+		return parquet.Leaf(parquet.FloatType)
+	case pgtype.Float8OID:
+		return parquet.Leaf(parquet.DoubleType)
+	case pgtype.DateOID:
+		return parquet.Date()
+	case pgtype.TimestampOID:
+		return parquet.TimestampAdjusted(parquet.Microsecond, false)
+	case pgtype.TimestamptzOID:
+		return parquet.Timestamp(parquet.Microsecond)
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		return parquet.JSON()
+	case pgtype.ByteaOID:
+		return parquet.Leaf(parquet.ByteArrayType)
+	}
+	return parquet.String()
+}
+
+// value parses the text form PostgreSQL sent for the column.
+func (c parquetColumn) value(text []byte) (parquet.Value, error) {
+	if text == nil {
+		return parquet.NullValue().Level(0, 0, c.index), nil
+	}
+	s := string(text)
+	var v parquet.Value
+	switch c.oid {
+	case pgtype.BoolOID:
+		v = parquet.BooleanValue(s == "t")
+	case pgtype.Int2OID, pgtype.Int4OID:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.Int32Value(int32(n))
+	case pgtype.Int8OID:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.Int64Value(n)
+	case pgtype.Float4OID:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.FloatValue(float32(f))
+	case pgtype.Float8OID:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.DoubleValue(f)
+	case pgtype.DateOID:
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.Int32Value(int32(t.Unix() / 86400))
+	case pgtype.TimestampOID, pgtype.TimestamptzOID:
+		layout := time.DateTime
+		if c.oid == pgtype.TimestamptzOID {
+			layout += "Z07"
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return v, err
+		}
+		v = parquet.Int64Value(t.UnixMicro())
+	case pgtype.ByteaOID:
+		b, err := hex.DecodeString(strings.TrimPrefix(s, `\x`))
+		if err != nil {
+			return v, err
+		}
+		v = parquet.ByteArrayValue(b)
+	default:
+		v = parquet.ByteArrayValue(text)
+	}
+	return v.Level(0, 1, c.index), nil
+}
+
+func exportParquet(ctx context.Context, conn *pgx.Conn, query string, w io.Writer) (int64, error) {
+	// Fix the text forms value() parses
+	if _, err := conn.Exec(ctx, "SET DateStyle = 'ISO, MDY'; SET TimeZone = 'UTC'; SET bytea_output = 'hex'"); err != nil {
+		return 0, err
+	}
+	rows, err := conn.Query(ctx, query, pgx.QueryResultFormats{pgx.TextFormatCode})
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	group := orderedGroup{Group: parquet.Group{}, order: make(map[string]int, len(fields))}
+	for i, field := range fields {
+		if _, ok := group.Group[field.Name]; ok {
+			return 0, usageError("column name '%s' appears twice, give the columns distinct aliases", field.Name)
+		}
+		group.Group[field.Name] = parquet.Optional(parquetNode(field.DataTypeOID))
+		group.order[field.Name] = i
+	}
+	schema := parquet.NewSchema("row", group)
+	columns := make([]parquetColumn, len(fields))
+	for i, field := range fields {
+		leaf, _ := schema.Lookup(field.Name)
+		columns[i] = parquetColumn{name: field.Name, oid: field.DataTypeOID, index: leaf.ColumnIndex}
+	}
+
+	writer := parquet.NewGenericWriter[any](w, schema, parquet.Compression(&parquet.Snappy))
+	batch := make([]parquet.Row, 0, parquetRowBatch)
+	var count int64
+	flush := func() error {
+		if _, err := writer.WriteRows(batch); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for rows.Next() {
+		raw := rows.RawValues()
+		row := make(parquet.Row, len(columns))
+		for i, column := range columns {
+			value, err := column.value(raw[i])
+			if err != nil {
+				return count, fmt.Errorf("column %s: cannot convert %q to Parquet: %w", column.name, raw[i], err)
+			}
+			// Rows list their values in schema column order
+			row[column.index] = value
+		}
+		batch = append(batch, row)
+		count++
+		if len(batch) == parquetRowBatch {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	if err := flush(); err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbGrepCmd = &cobra.Command{
+	Use:   "grep [database-name] [pattern]",
+	Short: "Search for a value in every text, uuid and json column of a database",
+	Long: `Search the text-like (text, varchar, char, citext, ...), uuid, json and
+jsonb columns of every table for a value and report the table, column and
+primary key of each matching row. Values match when they contain the
+pattern; --exact matches whole values and --regex takes a POSIX regular
+expression as PostgreSQL's ~ operator does.
+
+--table and --exclude-table take glob patterns such as 'public.order*' or
+'audit_*'. Patterns without a dot match the table name in any schema.
+
+The search stops reading a table after --limit matching rows, but tables
+with fewer matches are read in full. --jobs tables are scanned at a time,
+each on its own connection.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts grepOptions
+		opts.Schemas, _ = cmd.Flags().GetStringArray("schema")
+		opts.Tables, _ = cmd.Flags().GetStringArray("table")
+		opts.ExcludeTables, _ = cmd.Flags().GetStringArray("exclude-table")
+		opts.Regex, _ = cmd.Flags().GetBool("regex")
+		opts.Exact, _ = cmd.Flags().GetBool("exact")
+		opts.IgnoreCase, _ = cmd.Flags().GetBool("ignore-case")
+		opts.Limit, _ = cmd.Flags().GetInt("limit")
+		opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+		return grepDatabase(args[0], args[1], opts)
+	},
+}
+
+func init() {
+	dbGrepCmd.Flags().StringArray("schema", nil, "Only search this schema (repeatable, default all but system schemas)")
+	dbGrepCmd.Flags().StringArrayP("table", "t", nil, "Only search tables matching this pattern (repeatable)")
+	dbGrepCmd.Flags().StringArrayP("exclude-table", "T", nil, "Skip tables matching this pattern (repeatable)")
+	dbGrepCmd.Flags().BoolP("regex", "E", false, "Treat the pattern as a POSIX regular expression")
+	dbGrepCmd.Flags().BoolP("exact", "x", false, "Match whole values only")
+	dbGrepCmd.Flags().BoolP("ignore-case", "i", false, "Ignore case")
+	dbGrepCmd.Flags().IntP("limit", "l", 10, "Maximum matching rows per table")
+	dbGrepCmd.Flags().IntP("jobs", "j", 4, "Tables scanned at a time")
+	dbCmd.AddCommand(dbGrepCmd)
+}
+
+type grepOptions struct {
+	Schemas       []string
+	Tables        []string
+	ExcludeTables []string
+	Regex         bool
+	Exact         bool
+	IgnoreCase    bool
+	Limit         int
+	Jobs          int
+}
+
+// grepTable is a table with the columns that can hold the pattern.
+type grepTable struct {
+	Schema      string   `db:"schema"`
+	Name        string   `db:"name"`
+	Columns     []string `db:"columns"`
+	PrimaryKey  []string `db:"primary_key"`
+	Partitioned bool     `db:"partitioned"`
+}
+
+// keys returns the columns that identify a matching row: the primary key,
+// or else ctid, which needs the partition it is in to be unique. User
+// columns cannot take the names of system columns.
+func (t grepTable) keys() []string {
+	switch {
+	case len(t.PrimaryKey) > 0:
+		return t.PrimaryKey
+	case t.Partitioned:
+		return []string{"tableoid", "ctid"}
+	}
+	return []string{"ctid"}
+}
+
+type grepHit struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+type grepResult struct {
+	hits      []grepHit
+	truncated bool
+	err       error
+}
+
+// matchesTablePattern reports whether the table matches a glob pattern,
+// which applies to the table name alone when it has no dot.
+func matchesTablePattern(pattern, schema, table string) bool {
+	name := schema + "." + table
+	if !strings.Contains(pattern, ".") {
+		name = table
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// grepCondition is the SQL test of a text value against the pattern in $1.
+func grepCondition(value string, opts grepOptions) string {
+	switch {
+	case opts.Regex && opts.IgnoreCase:
+		return value + " ~* $1"
+	case opts.Regex:
+		return value + " ~ $1"
+	case opts.Exact && opts.IgnoreCase:
+		return "lower(" + value + ") = lower($1)"
+	case opts.Exact:
+		return value + " = $1"
+	case opts.IgnoreCase:
+		return "strpos(lower(" + value + "), lower($1)) > 0"
+	}
+	return "strpos(" + value + ", $1) > 0"
+}
+
+// grepQuery selects up to $2 + 1 matching rows of a table, one result row
+// per matching column with its position in t.Columns. The extra row tells
+// whether there were more than $2.
+func grepQuery(t grepTable, opts grepOptions) string {
+	var inner, outer, matches, values []string
+	for i, key := range t.keys() {
+		expression := pgx.Identifier{key}.Sanitize()
+		if key == "tableoid" {
+			expression = "tableoid::regclass"
+		}
+		inner = append(inner, fmt.Sprintf("%s::text AS k%d", expression, i))
+		outer = append(outer, fmt.Sprintf("t.k%d", i))
+	}
+	for i, column := range t.Columns {
+		value := pgx.Identifier{column}.Sanitize() + "::text"
+		inner = append(inner, fmt.Sprintf("%s AS c%d", value, i))
+		matches = append(matches, grepCondition(value, opts))
+		values = append(values, fmt.Sprintf("(%d, t.c%d)", i, i))
+	}
+	return fmt.Sprintf(`SELECT %s, m.position, m.value
+		FROM (SELECT %s FROM %s WHERE %s LIMIT $2 + 1) AS t,
+			LATERAL (VALUES %s) AS m(position, value)
+		WHERE %s`,
+		strings.Join(outer, ", "), strings.Join(inner, ", "), qualifiedName(t.Schema, t.Name),
+		strings.Join(matches, " OR "), strings.Join(values, ", "), grepCondition("m.value", opts))
+}
+
+// grepPattern finds the pattern in a value to show the text around it. It
+// is nil when the PostgreSQL regular expression has no Go equivalent.
+func grepPattern(pattern string, opts grepOptions) *regexp.Regexp {
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// grepSnippet returns the part of value around the match on one line.
+func grepSnippet(value string, re *regexp.Regexp) string {
+	const context = 40
+	start, end := 0, min(len(value), 2*context)
+	if re != nil {
+		if match := re.FindStringIndex(value); match != nil {
+			start, end = max(match[0]-context, 0), min(match[1]+context, len(value))
+		}
+	}
+	for start > 0 && !utf8.RuneStart(value[start]) {
+		start--
+	}
+	for end < len(value) && !utf8.RuneStart(value[end]) {
+		end++
+	}
+
+	snippet := cleanCell(value[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(value) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+func scanTable(ctx context.Context, conn *pgx.Conn, t grepTable, pattern string, re *regexp.Regexp, opts grepOptions) grepResult {
+	rows, err := conn.Query(ctx, grepQuery(t, opts), pattern, opts.Limit)
+	if err != nil {
+		return grepResult{err: err}
+	}
+	defer rows.Close()
+
+	keys := t.keys()
+	var result grepResult
+	matchedRows := map[string]bool{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return grepResult{err: err}
+		}
+		var key []string
+		for i, name := range keys {
+			text, _ := values[i].(string)
+			key = append(key, name+"="+text)
+		}
+		// Rows of a table come out together, so a new key past the limit
+		// means there was more than --limit
+		rowKey := strings.Join(key, ", ")
+		if !matchedRows[rowKey] && len(matchedRows) == opts.Limit {
+			result.truncated = true
+			break
+		}
+		position, _ := values[len(keys)].(int32)
+		value, _ := values[len(keys)+1].(string)
+		result.hits = append(result.hits, grepHit{
+			Table:  erdLabel(t.Schema, t.Name),
+			Column: t.Columns[position],
+			Key:    rowKey,
+			Value:  grepSnippet(value, re),
+		})
+		matchedRows[rowKey] = true
+	}
+	if err := rows.Err(); err != nil {
+		return grepResult{err: err}
+	}
+	return result
+}
+
+// grepTables lists the tables to search with their text-like, uuid and json
+// columns, including domains over those types. Partitions are searched
+// through their parent.
+func grepTables(ctx context.Context, conn *pgx.Conn, opts grepOptions) ([]grepTable, error) {
+	tables, err := queryRecords[grepTable](ctx, conn, `
+		SELECT
+			n.nspname AS schema,
+			c.relname AS name,
+			array_agg(a.attname::text ORDER BY a.attnum) AS columns,
+			coalesce((
+				SELECT array_agg(pa.attname::text ORDER BY array_position(i.indkey::int2[], pa.attnum))
+				FROM pg_index AS i
+				JOIN pg_attribute AS pa ON pa.attrelid = i.indrelid AND pa.attnum = ANY (i.indkey)
+				WHERE i.indrelid = c.oid AND i.indisprimary
+			), '{}') AS primary_key,
+			c.relkind = 'p' AS partitioned
+		FROM pg_class AS c
+		JOIN pg_namespace AS n ON n.oid = c.relnamespace
+		JOIN pg_attribute AS a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		JOIN pg_type AS ty ON ty.oid = a.atttypid
+		WHERE c.relkind IN ('r', 'p', 'm') AND NOT c.relispartition AND `+schemaFilter+`
+			AND (ty.typcategory = 'S'
+				OR coalesce(nullif(ty.typbasetype, 0), ty.oid) IN ('uuid'::regtype, 'json'::regtype, 'jsonb'::regtype))
+		GROUP BY n.nspname, c.relname, c.oid, c.relkind
+		ORDER BY n.nspname, c.relname`, nilIfEmpty(opts.Schemas))
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []grepTable
+	for _, t := range tables {
+		included := len(opts.Tables) == 0
+		for _, pattern := range opts.Tables {
+			included = included || matchesTablePattern(pattern, t.Schema, t.Name)
+		}
+		for _, pattern := range opts.ExcludeTables {
+			included = included && !matchesTablePattern(pattern, t.Schema, t.Name)
+		}
+		if included {
+			selected = append(selected, t)
+		}
+	}
+	return selected, nil
+}
+
+func grepDatabase(dbName, pattern string, opts grepOptions) error {
+	if opts.Regex && opts.Exact {
+		return usageError("--regex and --exact cannot be combined")
+	}
+	if opts.Limit < 1 {
+		return usageError("--limit must be at least 1")
+	}
+	if opts.Jobs < 1 {
+		return usageError("--jobs must be at least 1")
+	}
+	for _, p := range append(opts.Tables, opts.ExcludeTables...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return usageError("invalid table pattern '%s'", p)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if opts.Regex {
+		if _, err := conn.Exec(ctx, "SELECT '' ~ $1", pattern); pgErrorCode(err) == "2201B" {
+			return usageError("invalid regular expression: %v", err)
+		} else if err != nil {
+			return wrapError(err, "failed to check the regular expression")
+		}
+	}
+	tables, err := grepTables(ctx, conn, opts)
+	if err != nil {
+		return wrapError(err, "failed to list tables")
+	}
+	if len(tables) == 0 {
+		return notFoundError("no tables with text, uuid or json columns to search")
+	}
+
+	columns := 0
+	for _, t := range tables {
+		columns += len(t.Columns)
+	}
+	printProgress("Searching %d columns in %d tables...", columns, len(tables))
+
+	// Each worker scans on its own connection, the first one reuses conn
+	conns := []*pgx.Conn{conn}
+	for len(conns) < min(opts.Jobs, len(tables)) {
+		worker, err := connectPostgres(ctx, dbName)
+		if err != nil {
+			return err
+		}
+		defer worker.Close(context.Background())
+		conns = append(conns, worker)
+	}
+
+	re := grepPattern(pattern, opts)
+	results := make([]grepResult, len(tables))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for _, worker := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = scanTable(ctx, worker, tables[i], pattern, re, opts)
+			}
+		}()
+	}
+	for i := range tables {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return cancelledError("search cancelled")
+	}
+
+	hits := []grepHit{}
+	var failed, truncated []string
+	var firstErr error
+	matched := 0
+	for i, result := range results {
+		label := erdLabel(tables[i].Schema, tables[i].Name)
+		if result.err != nil {
+			printError("failed to search %s: %v", label, result.err)
+			failed = append(failed, label)
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		if len(result.hits) > 0 {
+			matched++
+		}
+		hits = append(hits, result.hits...)
+		if result.truncated {
+			truncated = append(truncated, label)
+		}
+	}
+	if len(failed) == len(tables) {
+		return wrapError(firstErr, "failed to search %s", dbName)
+	}
+
+	if ok, err := renderOutput(hits); ok {
+		if err == nil && len(failed) > 0 {
+			err = newError(KindGeneral, "%d of %d tables could not be searched", len(failed), len(tables))
+		}
+		return err
+	}
+	if len(hits) == 0 {
+		color.Yellow("No matches in %d tables", len(tables)-len(failed))
+	} else {
+		rows := make([][]string, len(hits))
+		for i, hit := range hits {
+			rows[i] = []string{hit.Table, hit.Column, hit.Key, hit.Value}
+		}
+		if err := writeTable(os.Stdout, []string{"table", "column", "key", "value"}, rows); err != nil {
+			return err
+		}
+		color.Green("✓ %d matches in %d of %d tables", len(hits), matched, len(tables)-len(failed))
+		if len(truncated) > 0 {
+			color.Cyan("Stopped at %d matching rows in %s, raise --limit to see more", opts.Limit, strings.Join(truncated, ", "))
+		}
+	}
+	if len(failed) > 0 {
+		return newError(KindGeneral, "%d of %d tables could not be searched", len(failed), len(tables))
+	}
+	return nil
+}
@@ -0,0 +1,742 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/spf13/cobra"
+)
+
+var dbImportCmd = &cobra.Command{
+	Use:   "import [database-name] [table] [source]",
+	Short: "Import CSV, JSON Lines or Parquet data into a table",
+	Long: `Load rows from a file, an s3:// URL or stdin ("-") into an existing table
+with COPY FROM, in batches of --batch-size rows that each commit on their own.
+
+Source columns are matched to table columns by name: the CSV header row, the
+JSON keys of the first line or the Parquet column names. --map renames a
+source column (--map src=column) or skips it (--map src=-). A CSV file
+without a header row fills the table columns in order; --header auto (the
+default) treats the first row as a header when every field names a column.
+Empty CSV fields and JSON null import as NULL.
+
+--on-conflict decides what happens to rows that hit a unique constraint:
+error (default) fails the batch, skip leaves the existing row and update
+overwrites it, matched on the primary key.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts importOptions
+		opts.Format, _ = cmd.Flags().GetString("format")
+		opts.Header, _ = cmd.Flags().GetString("header")
+		opts.Mappings, _ = cmd.Flags().GetStringArray("map")
+		opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.OnConflict, _ = cmd.Flags().GetString("on-conflict")
+		return importData(args[0], args[1], args[2], opts)
+	},
+}
+
+func init() {
+	dbImportCmd.Flags().StringP("format", "f", "", "Data format: csv, jsonl, parquet (default from source extension)")
+	dbImportCmd.Flags().String("header", headerAuto, "CSV header row: auto, yes, no")
+	dbImportCmd.Flags().StringArray("map", nil, "Map a source column to a table column (src=column, or src=- to skip)")
+	dbImportCmd.Flags().Int("batch-size", 10000, "Rows per COPY batch and transaction")
+	dbImportCmd.Flags().String("on-conflict", conflictFail, "On unique conflicts: error, skip, update")
+	dbCmd.AddCommand(dbImportCmd)
+}
+
+// CSV header modes.
+const (
+	headerAuto = "auto"
+	headerYes  = "yes"
+	headerNo   = "no"
+)
+
+// Conflict strategies of db import.
+const (
+	conflictFail   = "error"
+	conflictSkip   = "skip"
+	conflictUpdate = "update"
+)
+
+// importStaging is the temporary table conflicting batches go through.
+const importStaging = "tools_import_staging"
+
+type importOptions struct {
+	Format     string
+	Header     string
+	Mappings   []string
+	BatchSize  int
+	OnConflict string
+}
+
+type importRecord struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Source   string `json:"source"`
+	Format   string `json:"format"`
+	Rows     int64  `json:"rows"`
+	Written  int64  `json:"written"`
+}
+
+func (o *importOptions) validate(format string) error {
+	if !slices.Contains([]string{headerAuto, headerYes, headerNo}, o.Header) {
+		return usageError("invalid --header '%s' (valid: auto, yes, no)", o.Header)
+	}
+	if o.Header != headerAuto && format != dataCSV {
+		return usageError("--header only applies to csv")
+	}
+	if !slices.Contains([]string{conflictFail, conflictSkip, conflictUpdate}, o.OnConflict) {
+		return usageError("invalid --on-conflict '%s' (valid: error, skip, update)", o.OnConflict)
+	}
+	if o.BatchSize < 1 {
+		return usageError("--batch-size must be at least 1")
+	}
+	return nil
+}
+
+// dataSource is an opened import source. readerAt is nil for stdin.
+type dataSource struct {
+	io.Reader
+	readerAt io.ReaderAt
+	size     int64
+	close    func() error
+}
+
+// openSource opens a local path, an s3:// URL or stdin ("-") for reading.
+func openSource(ctx context.Context, location string) (*dataSource, error) {
+	if location == "-" {
+		return &dataSource{Reader: os.Stdin, close: func() error { return nil }}, nil
+	}
+	if !strings.HasPrefix(location, "s3://") {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, wrapError(err, "failed to open '%s'", location)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, wrapError(err, "failed to open '%s'", location)
+		}
+		return &dataSource{Reader: file, readerAt: file, size: info.Size(), close: file.Close}, nil
+	}
+
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	client, err := getMinIOClient()
+	if err != nil {
+		return nil, connectionError("failed to connect to MinIO: %w", err)
+	}
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, wrapError(err, "failed to open '%s'", location)
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, wrapError(err, "failed to open '%s'", location)
+	}
+	return &dataSource{Reader: object, readerAt: object, size: info.Size, close: object.Close}, nil
+}
+
+// recordReader reads records from an import source. Values are in column
+// order, nil for NULL.
+type recordReader interface {
+	Columns() []string
+	Next() ([]*string, error)
+}
+
+type csvRecords struct {
+	r       *csv.Reader
+	columns []string
+	pending []string
+}
+
+// newCSVRecords reads the first row to decide whether it is a header.
+func newCSVRecords(r io.Reader, header string, isHeader func([]string) bool) (*csvRecords, error) {
+	records := &csvRecords{r: csv.NewReader(bufio.NewReader(r))}
+	first, err := records.r.Read()
+	if err == io.EOF {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header == headerYes || header == headerAuto && isHeader(first) {
+		records.columns = first
+	} else {
+		records.pending = first
+	}
+	return records, nil
+}
+
+func (c *csvRecords) Columns() []string { return c.columns }
+
+func (c *csvRecords) Next() ([]*string, error) {
+	fields := c.pending
+	c.pending = nil
+	if fields == nil {
+		var err error
+		if fields, err = c.r.Read(); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]*string, len(fields))
+	for i := range fields {
+		if fields[i] != "" {
+			values[i] = &fields[i]
+		}
+	}
+	return values, nil
+}
+
+type jsonRecords struct {
+	decoder *json.Decoder
+	columns []string
+	pending map[string]any
+	line    int
+}
+
+// newJSONRecords takes the columns from the keys of the first object.
+func newJSONRecords(r io.Reader) (*jsonRecords, error) {
+	records := &jsonRecords{decoder: json.NewDecoder(bufio.NewReader(r))}
+	records.decoder.UseNumber()
+	first, err := records.decode()
+	if err == io.EOF {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key := range first {
+		records.columns = append(records.columns, key)
+	}
+	slices.Sort(records.columns)
+	records.pending = first
+	return records, nil
+}
+
+func (j *jsonRecords) decode() (map[string]any, error) {
+	var object map[string]any
+	if err := j.decoder.Decode(&object); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("line %d: %w", j.line+1, err)
+	}
+	j.line++
+	return object, nil
+}
+
+func (j *jsonRecords) Columns() []string { return j.columns }
+
+func (j *jsonRecords) Next() ([]*string, error) {
+	object := j.pending
+	j.pending = nil
+	if object == nil {
+		var err error
+		if object, err = j.decode(); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make([]*string, len(j.columns))
+	for key, value := range object {
+		index := slices.Index(j.columns, key)
+		if index < 0 {
+			return nil, fmt.Errorf("line %d: key '%s' is not in the first line", j.line, key)
+		}
+		var text string
+		switch value := value.(type) {
+		case nil:
+			continue
+		case string:
+			text = value
+		case json.Number:
+			text = value.String()
+		case bool:
+			text = strconv.FormatBool(value)
+		default:
+			// Objects and arrays go into json columns as they are
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			text = string(encoded)
+		}
+		values[index] = &text
+	}
+	return values, nil
+}
+
+type parquetRecords struct {
+	groups  []parquet.RowGroup
+	rows    parquet.Rows
+	buffer  []parquet.Row
+	next    int
+	columns []string
+	types   []parquet.Type
+}
+
+// newParquetRecords opens a Parquet file with a flat schema.
+func newParquetRecords(source *dataSource) (*parquetRecords, error) {
+	file, err := parquet.OpenFile(source.readerAt, source.size)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := file.Schema().Fields()
+	records := &parquetRecords{
+		groups:  file.RowGroups(),
+		columns: make([]string, len(fields)),
+		types:   make([]parquet.Type, len(fields)),
+	}
+	for _, field := range fields {
+		if !field.Leaf() || field.Repeated() {
+			return nil, usageError("parquet column '%s' is nested or repeated, only flat files can be imported", field.Name())
+		}
+		leaf, _ := file.Schema().Lookup(field.Name())
+		records.columns[leaf.ColumnIndex] = field.Name()
+		records.types[leaf.ColumnIndex] = field.Type()
+	}
+	return records, nil
+}
+
+func (p *parquetRecords) Columns() []string { return p.columns }
+
+func (p *parquetRecords) Next() ([]*string, error) {
+	for p.next == len(p.buffer) {
+		if p.rows == nil {
+			if len(p.groups) == 0 {
+				return nil, io.EOF
+			}
+			p.rows = p.groups[0].Rows()
+			p.groups = p.groups[1:]
+		}
+		if p.buffer == nil {
+			p.buffer = make([]parquet.Row, parquetRowBatch)
+		}
+		n, err := p.rows.ReadRows(p.buffer[:cap(p.buffer)])
+		p.buffer, p.next = p.buffer[:n], 0
+		if err == io.EOF {
+			p.rows.Close()
+			p.rows = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	row := p.buffer[p.next]
+	p.next++
+	values := make([]*string, len(p.columns))
+	for _, value := range row {
+		index := value.Column()
+		if value.IsNull() {
+			continue
+		}
+		text, err := parquetText(value, p.types[index])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", p.columns[index], err)
+		}
+		values[index] = &text
+	}
+	return values, nil
+}
+
+// parquetText returns the text form PostgreSQL reads for a Parquet value.
+func parquetText(value parquet.Value, typ parquet.Type) (string, error) {
+	var logical format.LogicalTypeValue
+	if lt := typ.LogicalType(); lt != nil {
+		logical = lt.Value
+	}
+
+	switch value.Kind() {
+	case parquet.Boolean:
+		return strconv.FormatBool(value.Boolean()), nil
+	case parquet.Int32, parquet.Int64:
+		n := value.Int64()
+		if value.Kind() == parquet.Int32 {
+			n = int64(value.Int32())
+		}
+		switch logical := logical.(type) {
+		case *format.DateType:
+			return time.Unix(n*86400, 0).UTC().Format(time.DateOnly), nil
+		case *format.TimestampType:
+			return parquetTimestamp(n, logical), nil
+		case *format.DecimalType:
+			return formatDecimal(big.NewInt(n), logical.Scale), nil
+		}
+		return strconv.FormatInt(n, 10), nil
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'g', -1, 32), nil
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'g', -1, 64), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		data := value.ByteArray()
+		switch logical := logical.(type) {
+		case *format.StringType, *format.JsonType, *format.EnumType:
+			return string(data), nil
+		case *format.UUIDType:
+			h := hex.EncodeToString(data)
+			return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+		case *format.DecimalType:
+			unscaled := new(big.Int).SetBytes(data)
+			if len(data) > 0 && data[0]&0x80 != 0 {
+				// Two's complement
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+			}
+			return formatDecimal(unscaled, logical.Scale), nil
+		}
+		return `\x` + hex.EncodeToString(data), nil
+	}
+	return "", fmt.Errorf("unsupported parquet type %s", typ)
+}
+
+// parquetTimestamp formats a timestamp, with an offset only if it is UTC
+// rather than local time.
+func parquetTimestamp(n int64, logical *format.TimestampType) string {
+	var t time.Time
+	switch logical.Unit.Value.(type) {
+	case *format.MilliSeconds:
+		t = time.UnixMilli(n)
+	case *format.NanoSeconds:
+		t = time.Unix(0, n)
+	default:
+		t = time.UnixMicro(n)
+	}
+	if logical.IsAdjustedToUTC {
+		return t.UTC().Format("2006-01-02 15:04:05.999999999Z07:00")
+	}
+	return t.UTC().Format("2006-01-02 15:04:05.999999999")
+}
+
+// formatDecimal places the decimal point scale digits from the right.
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// parseMappings turns src=column flags into a map; "-" skips the column.
+func parseMappings(mappings []string) (map[string]string, error) {
+	result := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		source, target, ok := strings.Cut(mapping, "=")
+		if !ok || source == "" || target == "" {
+			return nil, usageError("invalid --map '%s' (expected source=column or source=-)", mapping)
+		}
+		result[source] = target
+	}
+	return result, nil
+}
+
+// resolveColumn returns the table column a source column goes into, "-" to
+// skip it, or "" if there is none.
+func resolveColumn(name string, mappings map[string]string, tableColumns []string) string {
+	if target, ok := mappings[name]; ok {
+		if target == "-" || slices.Contains(tableColumns, target) {
+			return target
+		}
+		return ""
+	}
+	if slices.Contains(tableColumns, name) {
+		return name
+	}
+	// Unquoted names fold to lower case in PostgreSQL
+	if lower := strings.ToLower(name); slices.Contains(tableColumns, lower) {
+		return lower
+	}
+	return ""
+}
+
+// writeCSVRecord appends a record as CSV for COPY: values are always quoted
+// so that only NULL is left as an empty unquoted field.
+func writeCSVRecord(buf *bytes.Buffer, values []*string, plan []int) {
+	first := true
+	for i, value := range values {
+		if i >= len(plan) || plan[i] < 0 {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if value != nil {
+			buf.WriteByte('"')
+			buf.WriteString(strings.ReplaceAll(*value, `"`, `""`))
+			buf.WriteByte('"')
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+func importData(dbName, table, location string, opts importOptions) error {
+	format, err := dataFormat(opts.Format, location)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(format); err != nil {
+		return err
+	}
+	if format == dataParquet && location == "-" {
+		return usageError("parquet needs a file or s3:// object, not stdin")
+	}
+	mappings, err := parseMappings(opts.Mappings)
+	if err != nil {
+		return err
+	}
+	schema, name, err := parseQualifiedName(table)
+	if err != nil {
+		return usageError("invalid table name '%s': %w", table, err)
+	}
+	qualified := qualifiedName(schema, name)
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	columnRecords, err := tableColumns(ctx, conn, schema, name)
+	if err != nil {
+		return wrapError(err, "failed to read columns of %s", qualified)
+	}
+	if len(columnRecords) == 0 {
+		return notFoundError("table %s not found in database '%s'", qualified, dbName)
+	}
+	tableColumnNames := make([]string, len(columnRecords))
+	for i, column := range columnRecords {
+		tableColumnNames[i] = column.Column
+	}
+
+	source, err := openSource(ctx, location)
+	if err != nil {
+		return err
+	}
+	defer source.close()
+
+	var records recordReader
+	var width int
+	switch format {
+	case dataCSV:
+		isHeader := func(fields []string) bool {
+			for _, field := range fields {
+				if resolveColumn(strings.TrimSpace(field), mappings, tableColumnNames) == "" {
+					return false
+				}
+			}
+			return true
+		}
+		csvReader, err := newCSVRecords(source, opts.Header, isHeader)
+		if err != nil {
+			return wrapError(err, "failed to read '%s'", location)
+		}
+		width = len(csvReader.pending)
+		records = csvReader
+	case dataJSONL:
+		records, err = newJSONRecords(source)
+	case dataParquet:
+		records, err = newParquetRecords(source)
+	}
+	if err != nil {
+		return wrapError(err, "failed to read '%s'", location)
+	}
+
+	// Work out which table column each source column goes into
+	sourceColumns := records.Columns()
+	if sourceColumns == nil {
+		if width > len(tableColumnNames) {
+			return usageError("'%s' has %d fields but %s only has %d columns", location, width, qualified, len(tableColumnNames))
+		}
+		sourceColumns = tableColumnNames[:width]
+	}
+	plan := make([]int, len(sourceColumns))
+	var targets []string
+	for i, column := range sourceColumns {
+		target := resolveColumn(strings.TrimSpace(column), mappings, tableColumnNames)
+		switch {
+		case target == "":
+			return usageError("column '%s' of '%s' is not in %s, map it with --map '%s=column' or skip it with --map '%s=-'",
+				column, location, qualified, column, column)
+		case target == "-":
+			plan[i] = -1
+		case slices.Contains(targets, target):
+			return usageError("more than one source column goes into column '%s'", target)
+		default:
+			plan[i] = len(targets)
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		color.Yellow("Nothing to import from '%s'", location)
+		return nil
+	}
+
+	importer, err := newImporter(ctx, conn, qualified, targets, opts.OnConflict)
+	if err != nil {
+		return err
+	}
+
+	printProgress("Importing %s from '%s' into %s in batches of %d...", format, location, qualified, opts.BatchSize)
+	started := time.Now()
+	record := importRecord{Database: dbName, Table: qualified, Source: location, Format: format}
+	var buf bytes.Buffer
+	batchRows := 0
+	flush := func() error {
+		if batchRows == 0 {
+			return nil
+		}
+		written, err := importer.copyBatch(ctx, &buf)
+		if err != nil {
+			return wrapError(err, "failed to import rows %d-%d into %s (%d rows before them were committed)",
+				record.Rows-int64(batchRows)+1, record.Rows, qualified, record.Rows-int64(batchRows))
+		}
+		record.Written += written
+		buf.Reset()
+		batchRows = 0
+		return nil
+	}
+
+	batches := 0
+	for {
+		values, err := records.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return wrapError(err, "failed to read '%s' after %d rows", location, record.Rows)
+		}
+		writeCSVRecord(&buf, values, plan)
+		record.Rows++
+		batchRows++
+		if batchRows == opts.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			if batches++; batches%10 == 0 {
+				printProgress("  %d rows imported", record.Rows)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if ok, err := renderOutput(record); ok {
+		return err
+	}
+	skipped := ""
+	if record.Written < record.Rows {
+		skipped = fmt.Sprintf(", %d skipped as conflicts", record.Rows-record.Written)
+	}
+	color.Green("✓ Imported %d rows into %s from '%s'%s in %s", record.Written, qualified, location, skipped,
+		time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// importer writes CSV batches into a table, through a staging table when
+// conflicts are skipped or update existing rows.
+type importer struct {
+	conn    *pgx.Conn
+	copySQL string
+	insert  string
+}
+
+func newImporter(ctx context.Context, conn *pgx.Conn, qualified string, columns []string, onConflict string) (*importer, error) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pgx.Identifier{column}.Sanitize()
+	}
+	columnList := strings.Join(quoted, ", ")
+	if onConflict == conflictFail {
+		return &importer{conn: conn, copySQL: fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", qualified, columnList)}, nil
+	}
+
+	action := "DO NOTHING"
+	if onConflict == conflictUpdate {
+		keys, err := primaryKeyColumns(ctx, conn, qualified)
+		if err != nil {
+			return nil, wrapError(err, "failed to read primary key of %s", qualified)
+		}
+		if len(keys) == 0 {
+			return nil, usageError("--on-conflict update needs a primary key and %s has none", qualified)
+		}
+		var assignments []string
+		for i, column := range columns {
+			if !slices.Contains(keys, column) {
+				assignments = append(assignments, fmt.Sprintf("%s = EXCLUDED.%s", quoted[i], quoted[i]))
+			}
+		}
+		for i := range keys {
+			if !slices.Contains(columns, keys[i]) {
+				return nil, usageError("--on-conflict update needs primary key column '%s' in the data", keys[i])
+			}
+			keys[i] = pgx.Identifier{keys[i]}.Sanitize()
+		}
+		if len(assignments) > 0 {
+			action = fmt.Sprintf("(%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(assignments, ", "))
+		}
+	}
+
+	// The staging table has the column types but none of the constraints,
+	// and empties itself at every commit.
+	staging := fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DELETE ROWS AS SELECT %s FROM %s WITH NO DATA",
+		importStaging, columnList, qualified)
+	if _, err := conn.Exec(ctx, staging); err != nil {
+		return nil, wrapError(err, "failed to create staging table for %s", qualified)
+	}
+	return &importer{
+		conn:    conn,
+		copySQL: fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", importStaging, columnList),
+		insert: fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT %s",
+			qualified, columnList, columnList, importStaging, action),
+	}, nil
+}
+
+// copyBatch loads one batch and returns the number of rows written.
+func (im *importer) copyBatch(ctx context.Context, batch io.Reader) (int64, error) {
+	if im.insert == "" {
+		tag, err := im.conn.PgConn().CopyFrom(ctx, batch, im.copySQL)
+		return tag.RowsAffected(), err
+	}
+
+	var written int64
+	err := pgx.BeginFunc(ctx, im.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Conn().PgConn().CopyFrom(ctx, batch, im.copySQL); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, im.insert)
+		written = tag.RowsAffected()
+		return err
+	})
+	return written, err
+}
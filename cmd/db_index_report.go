@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var dbIndexReportCmd = &cobra.Command{
+	Use:   "index-report [database-name]",
+	Short: "Report unused, duplicate and bloated indexes and vacuum status",
+	Long: `Check index and table health from the statistics collector:
+
+  unused      non-unique indexes never scanned since the statistics were reset
+  duplicate   indexes with the same table, columns, operator classes and predicate
+  low scans   indexes of 1 MB or more with fewer scans than megabytes of size
+  seq scans   tables of 10000 rows or more read mostly by sequential scans
+  bloat       estimated wasted space in tables and btree indexes (from pg_stats,
+              run ANALYZE first for useful numbers)
+  vacuum      last manual and automatic vacuum and analyze per table
+
+Scan counts are cumulative since the last statistics reset, which the report
+shows. --vacuum, --analyze and --reindex run maintenance on the given tables
+instead of printing the report; --reindex rebuilds concurrently on
+PostgreSQL 12 and later.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vacuum, _ := cmd.Flags().GetStringArray("vacuum")
+		analyze, _ := cmd.Flags().GetStringArray("analyze")
+		reindex, _ := cmd.Flags().GetStringArray("reindex")
+		if len(vacuum)+len(analyze)+len(reindex) > 0 {
+			return maintainTables(args[0], vacuum, analyze, reindex)
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		return showIndexReport(args[0], limit)
+	},
+}
+
+func init() {
+	dbIndexReportCmd.Flags().IntP("limit", "l", 20, "Maximum rows per section")
+	dbIndexReportCmd.Flags().StringArray("vacuum", nil, "Run VACUUM on a table (repeatable)")
+	dbIndexReportCmd.Flags().StringArray("analyze", nil, "Run ANALYZE on a table (repeatable)")
+	dbIndexReportCmd.Flags().StringArray("reindex", nil, "Rebuild the indexes of a table (repeatable)")
+	dbCmd.AddCommand(dbIndexReportCmd)
+}
+
+type indexUsageRecord struct {
+	Schema     string `json:"schema" db:"schema"`
+	Table      string `json:"table" db:"table"`
+	Index      string `json:"index" db:"index"`
+	SizeBytes  int64  `json:"size_bytes" db:"size_bytes"`
+	Scans      int64  `json:"scans" db:"scans"`
+	Definition string `json:"definition" db:"definition"`
+}
+
+type duplicateIndexRecord struct {
+	Schema    string   `json:"schema" db:"schema"`
+	Table     string   `json:"table" db:"table"`
+	Indexes   []string `json:"indexes" db:"indexes"`
+	SizeBytes int64    `json:"size_bytes" db:"size_bytes"`
+	Columns   string   `json:"columns" db:"columns"`
+}
+
+type seqScanRecord struct {
+	Schema     string `json:"schema" db:"schema"`
+	Table      string `json:"table" db:"table"`
+	Rows       int64  `json:"rows" db:"rows"`
+	SeqScans   int64  `json:"seq_scans" db:"seq_scans"`
+	SeqRows    int64  `json:"seq_rows_read" db:"seq_rows_read"`
+	IndexScans int64  `json:"index_scans" db:"index_scans"`
+}
+
+type bloatRecord struct {
+	Schema       string  `json:"schema" db:"schema"`
+	Table        string  `json:"table" db:"table"`
+	Index        string  `json:"index,omitempty" db:"index"`
+	SizeBytes    int64   `json:"size_bytes" db:"size_bytes"`
+	BloatBytes   int64   `json:"bloat_bytes" db:"bloat_bytes"`
+	BloatPercent float64 `json:"bloat_percent" db:"bloat_percent"`
+}
+
+type vacuumRecord struct {
+	Schema          string     `json:"schema" db:"schema"`
+	Table           string     `json:"table" db:"table"`
+	LiveRows        int64      `json:"live_rows" db:"live_rows"`
+	DeadRows        int64      `json:"dead_rows" db:"dead_rows"`
+	LastVacuum      *time.Time `json:"last_vacuum" db:"last_vacuum"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum" db:"last_autovacuum"`
+	LastAnalyze     *time.Time `json:"last_analyze" db:"last_analyze"`
+	LastAutoanalyze *time.Time `json:"last_autoanalyze" db:"last_autoanalyze"`
+}
+
+type indexReport struct {
+	Database   string                 `json:"database"`
+	StatsReset *time.Time             `json:"stats_reset"`
+	Unused     []indexUsageRecord     `json:"unused_indexes"`
+	Duplicates []duplicateIndexRecord `json:"duplicate_indexes"`
+	LowScans   []indexUsageRecord     `json:"low_scan_indexes"`
+	SeqScans   []seqScanRecord        `json:"seq_scan_tables"`
+	Bloat      []bloatRecord          `json:"bloat"`
+	Vacuum     []vacuumRecord         `json:"vacuum"`
+}
+
+const indexUsageColumns = `
+		s.schemaname AS schema,
+		s.relname AS table,
+		s.indexrelname AS index,
+		pg_relation_size(s.indexrelid) AS size_bytes,
+		s.idx_scan AS scans,
+		pg_get_indexdef(s.indexrelid) AS definition
+	FROM pg_stat_user_indexes AS s
+	JOIN pg_index AS x ON x.indexrelid = s.indexrelid`
+
+// Indexes backing unique, primary key or exclusion constraints are needed
+// even when never scanned.
+const indexNotConstraint = `
+		NOT x.indisunique
+		AND NOT EXISTS (SELECT 1 FROM pg_constraint AS c WHERE c.conindid = s.indexrelid)`
+
+var indexReportQueries = struct {
+	unused, duplicates, lowScans, seqScans, bloat, vacuum string
+}{
+	unused: `SELECT` + indexUsageColumns + `
+		WHERE s.idx_scan = 0 AND` + indexNotConstraint + `
+		ORDER BY size_bytes DESC, schema, index
+		LIMIT $1`,
+
+	duplicates: `
+		SELECT
+			n.nspname AS schema,
+			t.relname AS table,
+			array_agg(i.relname ORDER BY i.relname) AS indexes,
+			sum(pg_relation_size(i.oid))::bigint AS size_bytes,
+			min(regexp_replace(pg_get_indexdef(i.oid), '^.* USING ', '')) AS columns
+		FROM pg_index AS x
+		JOIN pg_class AS i ON i.oid = x.indexrelid
+		JOIN pg_class AS t ON t.oid = x.indrelid
+		JOIN pg_namespace AS n ON n.oid = t.relnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+		GROUP BY n.nspname, t.relname, x.indrelid, i.relam, x.indkey::text, x.indclass::text,
+			coalesce(pg_get_expr(x.indexprs, x.indrelid), ''), coalesce(pg_get_expr(x.indpred, x.indrelid), '')
+		HAVING count(*) > 1
+		ORDER BY size_bytes DESC
+		LIMIT $1`,
+
+	lowScans: `SELECT` + indexUsageColumns + `
+		WHERE s.idx_scan > 0
+			AND pg_relation_size(s.indexrelid) >= 1048576
+			AND s.idx_scan < pg_relation_size(s.indexrelid) / 1048576
+			AND` + indexNotConstraint + `
+		ORDER BY size_bytes DESC, schema, index
+		LIMIT $1`,
+
+	seqScans: `
+		SELECT
+			schemaname AS schema,
+			relname AS table,
+			n_live_tup AS rows,
+			seq_scan AS seq_scans,
+			seq_tup_read AS seq_rows_read,
+			coalesce(idx_scan, 0) AS index_scans
+		FROM pg_stat_user_tables
+		WHERE n_live_tup >= 10000 AND seq_scan > coalesce(idx_scan, 0)
+		ORDER BY seq_tup_read DESC
+		LIMIT $1`,
+
+	// A statistics-based estimate: the pages the live rows would need at the
+	// average row width from pg_stats and the fillfactor, against the pages
+	// the relation has. Headers are approximated (24 bytes per heap row, 8 per
+	// index entry, 4 for each line pointer).
+	bloat: `
+		WITH expected AS (
+			SELECT
+				n.nspname AS schema,
+				c.relname AS table,
+				NULL::text AS index,
+				c.relpages,
+				c.reltuples::numeric * (28 + stats.width)
+					/ ((current_setting('block_size')::numeric - 24)
+						* coalesce((SELECT max(substring(o FROM 'fillfactor=(\d+)'))::int FROM unnest(c.reloptions) AS o), 100) / 100)
+					AS pages
+			FROM pg_class AS c
+			JOIN pg_namespace AS n ON n.oid = c.relnamespace
+			CROSS JOIN LATERAL (
+				SELECT sum((1 - s.null_frac) * s.avg_width)::numeric AS width
+				FROM pg_stats AS s
+				WHERE s.schemaname = n.nspname AND s.tablename = c.relname
+			) AS stats
+			WHERE c.relkind IN ('r', 'm') AND c.reltuples > 0 AND stats.width IS NOT NULL
+				AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+			UNION ALL
+			SELECT
+				n.nspname,
+				t.relname,
+				i.relname,
+				i.relpages,
+				1 + i.reltuples::numeric * (12 + stats.width)
+					/ ((current_setting('block_size')::numeric - 40)
+						* coalesce((SELECT max(substring(o FROM 'fillfactor=(\d+)'))::int FROM unnest(i.reloptions) AS o), 90) / 100)
+			FROM pg_index AS x
+			JOIN pg_class AS i ON i.oid = x.indexrelid
+			JOIN pg_class AS t ON t.oid = x.indrelid
+			JOIN pg_namespace AS n ON n.oid = t.relnamespace
+			JOIN pg_am AS a ON a.oid = i.relam AND a.amname = 'btree'
+			CROSS JOIN LATERAL (
+				SELECT sum((1 - s.null_frac) * s.avg_width)::numeric AS width, count(*) AS columns
+				FROM pg_attribute AS att
+				JOIN pg_stats AS s ON s.schemaname = n.nspname AND s.tablename = t.relname AND s.attname = att.attname
+				WHERE att.attrelid = t.oid AND att.attnum = ANY (x.indkey)
+			) AS stats
+			WHERE x.indexprs IS NULL AND i.reltuples > 0 AND stats.columns = x.indnatts
+				AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+		)
+		SELECT
+			schema,
+			"table",
+			coalesce(index, '') AS index,
+			relpages::bigint * current_setting('block_size')::bigint AS size_bytes,
+			greatest(relpages - ceil(pages), 0)::bigint * current_setting('block_size')::bigint AS bloat_bytes,
+			round(100 * greatest(relpages - ceil(pages), 0) / relpages, 1)::float8 AS bloat_percent
+		FROM expected
+		WHERE relpages > 0 AND relpages - ceil(pages) >= 0.1 * relpages
+		ORDER BY bloat_bytes DESC
+		LIMIT $1`,
+
+	vacuum: `
+		SELECT
+			schemaname AS schema,
+			relname AS table,
+			n_live_tup AS live_rows,
+			n_dead_tup AS dead_rows,
+			last_vacuum,
+			last_autovacuum,
+			last_analyze,
+			last_autoanalyze
+		FROM pg_stat_user_tables
+		ORDER BY greatest(last_vacuum, last_autovacuum) NULLS FIRST, n_dead_tup DESC
+		LIMIT $1`,
+}
+
+func showIndexReport(dbName string, limit int) error {
+	if limit < 1 {
+		return usageError("--limit must be at least 1")
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	report := indexReport{Database: dbName}
+	err = conn.QueryRow(ctx, "SELECT stats_reset FROM pg_stat_database WHERE datname = current_database()").Scan(&report.StatsReset)
+	if err != nil {
+		return wrapError(err, "failed to read statistics")
+	}
+	if report.Unused, err = queryRecords[indexUsageRecord](ctx, conn, indexReportQueries.unused, limit); err != nil {
+		return wrapError(err, "failed to find unused indexes")
+	}
+	if report.Duplicates, err = queryRecords[duplicateIndexRecord](ctx, conn, indexReportQueries.duplicates, limit); err != nil {
+		return wrapError(err, "failed to find duplicate indexes")
+	}
+	if report.LowScans, err = queryRecords[indexUsageRecord](ctx, conn, indexReportQueries.lowScans, limit); err != nil {
+		return wrapError(err, "failed to find rarely used indexes")
+	}
+	if report.SeqScans, err = queryRecords[seqScanRecord](ctx, conn, indexReportQueries.seqScans, limit); err != nil {
+		return wrapError(err, "failed to read table scans")
+	}
+	if report.Bloat, err = queryRecords[bloatRecord](ctx, conn, indexReportQueries.bloat, limit); err != nil {
+		return wrapError(err, "failed to estimate bloat")
+	}
+	if report.Vacuum, err = queryRecords[vacuumRecord](ctx, conn, indexReportQueries.vacuum, limit); err != nil {
+		return wrapError(err, "failed to read vacuum status")
+	}
+
+	if ok, err := renderOutput(report); ok {
+		return err
+	}
+	printIndexReport(report)
+	return nil
+}
+
+func printIndexReport(report indexReport) {
+	since := "the server started keeping them"
+	if report.StatsReset != nil {
+		since = report.StatsReset.Local().Format(time.DateTime)
+	}
+	color.Green("Index report for database '%s' (statistics since %s)", report.Database, since)
+
+	section := func(title string, empty string, headers []string, rows [][]string) {
+		fmt.Println()
+		color.Cyan(title)
+		if len(rows) == 0 {
+			fmt.Println("  " + empty)
+			return
+		}
+		writeTable(os.Stdout, headers, rows)
+	}
+	name := func(schema, table string) string {
+		if schema == "public" {
+			return table
+		}
+		return schema + "." + table
+	}
+
+	var rows [][]string
+	for _, index := range report.Unused {
+		rows = append(rows, []string{index.Index, name(index.Schema, index.Table), formatBytes(index.SizeBytes)})
+	}
+	section("Unused indexes", "none", []string{"index", "table", "size"}, rows)
+
+	rows = nil
+	for _, duplicate := range report.Duplicates {
+		rows = append(rows, []string{strings.Join(duplicate.Indexes, ", "), name(duplicate.Schema, duplicate.Table),
+			duplicate.Columns, formatBytes(duplicate.SizeBytes)})
+	}
+	section("Duplicate indexes", "none", []string{"indexes", "table", "definition", "total size"}, rows)
+
+	rows = nil
+	for _, index := range report.LowScans {
+		rows = append(rows, []string{index.Index, name(index.Schema, index.Table), formatBytes(index.SizeBytes),
+			strconv.FormatInt(index.Scans, 10)})
+	}
+	section("Rarely used indexes (fewer scans than MB)", "none", []string{"index", "table", "size", "scans"}, rows)
+
+	rows = nil
+	for _, table := range report.SeqScans {
+		rows = append(rows, []string{name(table.Schema, table.Table), strconv.FormatInt(table.Rows, 10),
+			strconv.FormatInt(table.SeqScans, 10), strconv.FormatInt(table.SeqRows, 10), strconv.FormatInt(table.IndexScans, 10)})
+	}
+	section("Tables read by sequential scans (missing index candidates)", "none",
+		[]string{"table", "rows", "seq scans", "rows read", "index scans"}, rows)
+
+	rows = nil
+	for _, bloat := range report.Bloat {
+		rows = append(rows, []string{name(bloat.Schema, bloat.Table), bloat.Index, formatBytes(bloat.SizeBytes),
+			formatBytes(bloat.BloatBytes), fmt.Sprintf("%.1f%%", bloat.BloatPercent)})
+	}
+	section("Estimated bloat (10% or more)", "none, or no statistics yet (run ANALYZE)",
+		[]string{"table", "index", "size", "bloat", "%"}, rows)
+
+	rows = nil
+	for _, table := range report.Vacuum {
+		rows = append(rows, []string{name(table.Schema, table.Table), strconv.FormatInt(table.LiveRows, 10),
+			strconv.FormatInt(table.DeadRows, 10), formatTimestamp(table.LastVacuum), formatTimestamp(table.LastAutovacuum),
+			formatTimestamp(table.LastAnalyze), formatTimestamp(table.LastAutoanalyze)})
+	}
+	section("Vacuum and analyze (least recently vacuumed first)", "no tables",
+		[]string{"table", "live", "dead", "vacuum", "autovacuum", "analyze", "autoanalyze"}, rows)
+
+	fmt.Println()
+	if len(report.Unused)+len(report.Duplicates) > 0 {
+		color.Yellow("Check replicas before dropping unused indexes: scan counts are kept per server.")
+	}
+	fmt.Println("Run maintenance with --vacuum, --analyze or --reindex TABLE.")
+}
+
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// maintainTables runs VACUUM, ANALYZE and REINDEX on the named tables, one
+// table at a time. Tables run in the order they first appear in reindex,
+// then vacuum, then analyze; pflag does not keep the order across flags.
+func maintainTables(dbName string, vacuum, analyze, reindex []string) error {
+	type tableTask struct {
+		name                     string
+		vacuum, analyze, reindex bool
+	}
+	var tasks []*tableTask
+	add := func(names []string, set func(*tableTask)) error {
+		for _, table := range names {
+			schema, name, err := parseQualifiedName(table)
+			if err != nil {
+				return err
+			}
+			qualified := qualifiedName(schema, name)
+			i := slices.IndexFunc(tasks, func(t *tableTask) bool { return t.name == qualified })
+			if i < 0 {
+				tasks = append(tasks, &tableTask{name: qualified})
+				i = len(tasks) - 1
+			}
+			set(tasks[i])
+		}
+		return nil
+	}
+	if err := add(reindex, func(t *tableTask) { t.reindex = true }); err != nil {
+		return err
+	}
+	if err := add(vacuum, func(t *tableTask) { t.vacuum = true }); err != nil {
+		return err
+	}
+	if err := add(analyze, func(t *tableTask) { t.analyze = true }); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var version int
+	if err := conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return wrapError(err, "failed to read server version")
+	}
+
+	var results []actionResult
+	run := func(action, target, sql string) error {
+		printProgress("%s %s...", action, target)
+		started := time.Now()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			return wrapError(err, "%s %s failed", action, target)
+		}
+		results = append(results, actionResult{Action: strings.ToLower(action), Target: target,
+			Status: fmt.Sprintf("done in %s", time.Since(started).Round(time.Millisecond))})
+		return nil
+	}
+	for _, task := range tasks {
+		// Rebuild first so that VACUUM and ANALYZE see the new indexes
+		if task.reindex {
+			sql := "REINDEX TABLE " + task.name
+			if version >= 120000 {
+				sql = "REINDEX TABLE CONCURRENTLY " + task.name
+			}
+			if err := run("REINDEX", task.name, sql); err != nil {
+				return err
+			}
+		}
+		switch {
+		case task.vacuum && task.analyze:
+			err = run("VACUUM ANALYZE", task.name, "VACUUM (ANALYZE) "+task.name)
+		case task.vacuum:
+			err = run("VACUUM", task.name, "VACUUM "+task.name)
+		case task.analyze:
+			err = run("ANALYZE", task.name, "ANALYZE "+task.name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if ok, err := renderOutput(results); ok {
+		return err
+	}
+	for _, result := range results {
+		color.Green("✓ %s %s %s", strings.ToUpper(result.Action), result.Target, result.Status)
+	}
+	return nil
+}
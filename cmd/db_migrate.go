@@ -0,0 +1,706 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/spf13/cobra"
+)
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply versioned schema migrations",
+	Long: `Apply numbered SQL migrations from a directory and track them in a table.
+
+Migrations are pairs of files named <version>_<name>.up.sql and
+<version>_<name>.down.sql, e.g. 0003_add_orders.up.sql. Each one runs in its
+own transaction unless its first line is -- migrate:no-transaction, which
+statements such as CREATE INDEX CONCURRENTLY need. An advisory lock keeps
+two runs against the same database from interleaving.
+
+Applied files are checksummed; up, down and goto refuse to run while an
+applied file was changed or removed, unless --ignore-drift is given.
+
+The directory defaults to migrations.dir in the config file or ./migrations,
+the table to migrations.table or schema_migrations.`,
+}
+
+var dbMigrateUpCmd = &cobra.Command{
+	Use:   "up [database-name]",
+	Short: "Apply pending migrations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, _ := cmd.Flags().GetInt("steps")
+		return migrateUp(args[0], migrateSettings(cmd), steps)
+	},
+}
+
+var dbMigrateDownCmd = &cobra.Command{
+	Use:   "down [database-name]",
+	Short: "Revert the most recently applied migrations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, _ := cmd.Flags().GetInt("steps")
+		return migrateDown(args[0], migrateSettings(cmd), steps)
+	},
+}
+
+var dbMigrateGotoCmd = &cobra.Command{
+	Use:   "goto [database-name] [version]",
+	Short: "Migrate up or down to a version (0 reverts everything)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return usageError("invalid version '%s'", args[1])
+		}
+		return migrateGoto(args[0], migrateSettings(cmd), version)
+	},
+}
+
+var dbMigrateStatusCmd = &cobra.Command{
+	Use:   "status [database-name]",
+	Short: "Show applied, pending and drifted migrations",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrateStatus(args[0], migrateSettings(cmd))
+	},
+}
+
+var dbMigrateCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create the next pair of up and down migration files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return createMigration(migrateSettings(cmd).Dir, args[0])
+	},
+}
+
+func init() {
+	dbMigrateCmd.PersistentFlags().String("dir", "", "Migrations directory (default migrations.dir or ./migrations)")
+	dbMigrateCmd.PersistentFlags().String("table", "", "Table tracking applied migrations (default migrations.table or schema_migrations)")
+	dbMigrateCmd.PersistentFlags().Bool("ignore-drift", false, "Run even if applied migration files were changed or removed")
+
+	dbMigrateUpCmd.Flags().Int("steps", 0, "Apply at most N migrations (default all)")
+	dbMigrateDownCmd.Flags().Int("steps", 1, "Revert N migrations")
+
+	dbMigrateCmd.AddCommand(dbMigrateUpCmd)
+	dbMigrateCmd.AddCommand(dbMigrateDownCmd)
+	dbMigrateCmd.AddCommand(dbMigrateGotoCmd)
+	dbMigrateCmd.AddCommand(dbMigrateStatusCmd)
+	dbMigrateCmd.AddCommand(dbMigrateCreateCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+}
+
+const (
+	defaultMigrationsDir  = "migrations"
+	defaultMigrationTable = "schema_migrations"
+	noTransactionPragma   = "-- migrate:no-transaction"
+)
+
+// Migration statuses shown by db migrate status.
+const (
+	migrationApplied  = "applied"
+	migrationPending  = "pending"
+	migrationModified = "modified"
+	migrationMissing  = "missing"
+)
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+)\.(up|down)\.sql$`)
+	migrationNamePattern = regexp.MustCompile(`^[a-z0-9_\-]+$`)
+)
+
+type migrateConfig struct {
+	Dir         string
+	Table       string
+	IgnoreDrift bool
+}
+
+// migration is one version found in the migrations directory.
+type migration struct {
+	Version  int64
+	Name     string
+	UpPath   string
+	DownPath string
+	Checksum string
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+type migrationStatusRecord struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type migrationRunRecord struct {
+	Version    int64  `json:"version"`
+	Name       string `json:"name"`
+	Direction  string `json:"direction"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type migrationFilesRecord struct {
+	Version int64    `json:"version"`
+	Name    string   `json:"name"`
+	Files   []string `json:"files"`
+}
+
+func migrateSettings(cmd *cobra.Command) migrateConfig {
+	var config migrateConfig
+	config.Dir, _ = cmd.Flags().GetString("dir")
+	config.Table, _ = cmd.Flags().GetString("table")
+	config.IgnoreDrift, _ = cmd.Flags().GetBool("ignore-drift")
+	if config.Dir == "" {
+		config.Dir = settingString("migrations.dir")
+	}
+	if config.Dir == "" {
+		config.Dir = defaultMigrationsDir
+	}
+	if config.Table == "" {
+		config.Table = settingString("migrations.table")
+	}
+	if config.Table == "" {
+		config.Table = defaultMigrationTable
+	}
+	return config
+}
+
+// loadMigrations reads the migrations directory, sorted by version.
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, notFoundError("migrations directory '%s' not found", dir)
+	} else if err != nil {
+		return nil, wrapError(err, "failed to read migrations directory '%s'", dir)
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, usageError("migration file '%s' does not match <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, usageError("migration file '%s' has an invalid version", entry.Name())
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, usageError("version %d is used by both '%s' and '%s'", version, m.Name, match[2])
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if match[3] == "up" {
+			m.UpPath = path
+		} else {
+			m.DownPath = path
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpPath == "" {
+			return nil, usageError("migration %d_%s has no .up.sql file", m.Version, m.Name)
+		}
+		checksum, err := fileChecksum(m.UpPath)
+		if err != nil {
+			return nil, wrapError(err, "failed to read migration '%s'", m.UpPath)
+		}
+		m.Checksum = checksum
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrator applies migrations over one connection that holds the advisory
+// lock for the migration table.
+type migrator struct {
+	conn       *pgx.Conn
+	table      string
+	lockKey    string
+	migrations []migration
+	applied    map[int64]appliedMigration
+}
+
+func openMigrator(ctx context.Context, dbName string, config migrateConfig) (*migrator, error) {
+	migrations, err := loadMigrations(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	schema, table, err := parseQualifiedName(config.Table)
+	if err != nil {
+		return nil, usageError("invalid migration table '%s': %w", config.Table, err)
+	}
+
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	m := &migrator{
+		conn:       conn,
+		table:      pgx.Identifier{schema, table}.Sanitize(),
+		lockKey:    fmt.Sprintf("tools migrate %s.%s", schema, table),
+		migrations: migrations,
+	}
+	if err := m.lock(ctx); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	if err := m.loadApplied(ctx); err != nil {
+		m.close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// lock takes the advisory lock, waiting for a concurrent run to finish.
+func (m *migrator) lock(ctx context.Context) error {
+	var locked bool
+	if err := m.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", m.lockKey).Scan(&locked); err != nil {
+		return wrapError(err, "failed to take migration lock")
+	}
+	if locked {
+		return nil
+	}
+	printProgress("Waiting for another migration run to finish...")
+	if _, err := m.conn.Exec(ctx, "SELECT pg_advisory_lock(hashtext($1))", m.lockKey); err != nil {
+		return wrapError(err, "failed to take migration lock")
+	}
+	return nil
+}
+
+func (m *migrator) close(ctx context.Context) {
+	m.conn.Exec(ctx, "SELECT pg_advisory_unlock(hashtext($1))", m.lockKey)
+	m.conn.Close(ctx)
+}
+
+func (m *migrator) loadApplied(ctx context.Context) error {
+	create := `CREATE TABLE IF NOT EXISTS ` + m.table + ` (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now(),
+		execution_ms bigint NOT NULL DEFAULT 0
+	)`
+	if _, err := m.conn.Exec(ctx, create); err != nil {
+		return wrapError(err, "failed to create migration table %s", m.table)
+	}
+
+	applied, err := queryRecords[appliedMigration](ctx, m.conn,
+		`SELECT version, name, checksum, applied_at FROM `+m.table+` ORDER BY version`)
+	if err != nil {
+		return wrapError(err, "failed to read applied migrations")
+	}
+	m.applied = make(map[int64]appliedMigration, len(applied))
+	for _, a := range applied {
+		m.applied[a.Version] = a
+	}
+	return nil
+}
+
+// status lists every known version: those in the directory and those only
+// recorded as applied.
+func (m *migrator) status() []migrationStatusRecord {
+	files := make(map[int64]migration, len(m.migrations))
+	versions := make([]int64, 0, len(m.migrations)+len(m.applied))
+	for _, mig := range m.migrations {
+		files[mig.Version] = mig
+		versions = append(versions, mig.Version)
+	}
+	for version := range m.applied {
+		if _, ok := files[version]; !ok {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	records := make([]migrationStatusRecord, 0, len(versions))
+	for _, version := range versions {
+		mig, inDir := files[version]
+		applied, isApplied := m.applied[version]
+		record := migrationStatusRecord{Version: version, Name: mig.Name, Status: migrationPending}
+		if isApplied {
+			appliedAt := applied.AppliedAt
+			record.AppliedAt = &appliedAt
+			record.Name = applied.Name
+			switch {
+			case !inDir:
+				record.Status = migrationMissing
+			case mig.Checksum != applied.Checksum:
+				record.Status = migrationModified
+			default:
+				record.Status = migrationApplied
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// checkDrift fails when applied migration files were changed or removed.
+func (m *migrator) checkDrift(ignore bool) error {
+	var drifted []string
+	for _, record := range m.status() {
+		if record.Status == migrationModified || record.Status == migrationMissing {
+			drifted = append(drifted, fmt.Sprintf("%d_%s (%s)", record.Version, record.Name, record.Status))
+		}
+	}
+	if len(drifted) == 0 {
+		return nil
+	}
+	if ignore {
+		printError("Warning: applied migrations drifted: %s", strings.Join(drifted, ", "))
+		return nil
+	}
+	return conflictError("applied migrations drifted from their files: %s (use --ignore-drift to run anyway)", strings.Join(drifted, ", "))
+}
+
+func (m *migrator) current() int64 {
+	var current int64
+	for version := range m.applied {
+		current = max(current, version)
+	}
+	return current
+}
+
+// pending returns the unapplied migrations up to target, oldest first.
+func (m *migrator) pending(target int64) []migration {
+	var pending []migration
+	for _, mig := range m.migrations {
+		if _, ok := m.applied[mig.Version]; !ok && mig.Version <= target {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// revertible returns the applied migrations above target, newest first, at
+// most steps of them unless steps is 0. Only those need a down file.
+func (m *migrator) revertible(target int64, steps int) ([]migration, error) {
+	files := make(map[int64]migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		files[mig.Version] = mig
+	}
+
+	var versions []int64
+	for version := range m.applied {
+		if version > target {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if steps > 0 && len(versions) > steps {
+		versions = versions[:steps]
+	}
+
+	revert := make([]migration, 0, len(versions))
+	for _, version := range versions {
+		mig, ok := files[version]
+		if !ok {
+			return nil, notFoundError("cannot revert %d_%s: its files are missing", version, m.applied[version].Name)
+		}
+		if mig.DownPath == "" {
+			return nil, usageError("cannot revert %d_%s: it has no .down.sql file", version, mig.Name)
+		}
+		revert = append(revert, mig)
+	}
+	return revert, nil
+}
+
+// run applies or reverts one migration and records it in the migration
+// table, both inside one transaction unless the file opts out. Without a
+// transaction the statements are sent one at a time, since a multi-statement
+// query would still run in an implicit transaction.
+func (m *migrator) run(ctx context.Context, mig migration, up bool) (migrationRunRecord, error) {
+	path, direction := mig.UpPath, "up"
+	if !up {
+		path, direction = mig.DownPath, "down"
+	}
+	record := migrationRunRecord{Version: mig.Version, Name: mig.Name, Direction: direction}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, wrapError(err, "failed to read migration '%s'", path)
+	}
+	sql := string(data)
+	transactional := !strings.HasPrefix(strings.TrimSpace(sql), noTransactionPragma)
+
+	started := time.Now()
+	err = m.exec(ctx, transactional, func(q execer) error {
+		statements := []string{sql}
+		if !transactional {
+			statements = splitStatements(sql)
+		}
+		for _, statement := range statements {
+			if _, err := q.Exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		record.DurationMS = time.Since(started).Milliseconds()
+		if up {
+			_, err := q.Exec(ctx, `INSERT INTO `+m.table+` (version, name, checksum, execution_ms) VALUES ($1, $2, $3, $4)`,
+				mig.Version, mig.Name, mig.Checksum, record.DurationMS)
+			return err
+		}
+		_, err := q.Exec(ctx, `DELETE FROM `+m.table+` WHERE version = $1`, mig.Version)
+		return err
+	})
+	if err != nil {
+		return record, wrapError(err, "migration %d_%s %s failed", mig.Version, mig.Name, direction)
+	}
+
+	if up {
+		m.applied[mig.Version] = appliedMigration{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now()}
+	} else {
+		delete(m.applied, mig.Version)
+	}
+	return record, nil
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (m *migrator) exec(ctx context.Context, transactional bool, fn func(q execer) error) error {
+	if !transactional {
+		return fn(m.conn)
+	}
+	return pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		return fn(tx)
+	})
+}
+
+// runAll reverts then applies migrations in the given order and reports
+// each one, stopping at the first failure.
+func (m *migrator) runAll(ctx context.Context, revert, apply []migration) error {
+	records := []migrationRunRecord{}
+	step := func(mig migration, up bool) error {
+		record, err := m.run(ctx, mig, up)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+		if outputFormat() == outputText {
+			arrow := "↑"
+			if !up {
+				arrow = "↓"
+			}
+			color.Green("✓ %s %d_%s (%dms)", arrow, mig.Version, mig.Name, record.DurationMS)
+		}
+		return nil
+	}
+	for _, mig := range revert {
+		if err := step(mig, false); err != nil {
+			return err
+		}
+	}
+	for _, mig := range apply {
+		if err := step(mig, true); err != nil {
+			return err
+		}
+	}
+
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+	if len(records) == 0 {
+		color.Yellow("Nothing to migrate, database is at version %d", m.current())
+		return nil
+	}
+	color.Green("Database is now at version %d", m.current())
+	return nil
+}
+
+func migrateUp(dbName string, config migrateConfig, steps int) error {
+	if steps < 0 {
+		return usageError("--steps must not be negative")
+	}
+	ctx := context.Background()
+	m, err := openMigrator(ctx, dbName, config)
+	if err != nil {
+		return err
+	}
+	defer m.close(ctx)
+
+	if err := m.checkDrift(config.IgnoreDrift); err != nil {
+		return err
+	}
+	pending := m.pending(math.MaxInt64)
+	if steps > 0 && len(pending) > steps {
+		pending = pending[:steps]
+	}
+	return m.runAll(ctx, nil, pending)
+}
+
+func migrateDown(dbName string, config migrateConfig, steps int) error {
+	if steps < 1 {
+		return usageError("--steps must be at least 1")
+	}
+	ctx := context.Background()
+	m, err := openMigrator(ctx, dbName, config)
+	if err != nil {
+		return err
+	}
+	defer m.close(ctx)
+
+	if err := m.checkDrift(config.IgnoreDrift); err != nil {
+		return err
+	}
+	revert, err := m.revertible(0, steps)
+	if err != nil {
+		return err
+	}
+	return m.runAll(ctx, revert, nil)
+}
+
+func migrateGoto(dbName string, config migrateConfig, target int64) error {
+	ctx := context.Background()
+	m, err := openMigrator(ctx, dbName, config)
+	if err != nil {
+		return err
+	}
+	defer m.close(ctx)
+
+	if target != 0 && !m.known(target) {
+		return notFoundError("migration version %d not found", target)
+	}
+	if err := m.checkDrift(config.IgnoreDrift); err != nil {
+		return err
+	}
+
+	revert, err := m.revertible(target, 0)
+	if err != nil {
+		return err
+	}
+	return m.runAll(ctx, revert, m.pending(target))
+}
+
+func (m *migrator) known(version int64) bool {
+	if _, ok := m.applied[version]; ok {
+		return true
+	}
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func migrateStatus(dbName string, config migrateConfig) error {
+	ctx := context.Background()
+	m, err := openMigrator(ctx, dbName, config)
+	if err != nil {
+		return err
+	}
+	defer m.close(ctx)
+
+	records := m.status()
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+	if len(records) == 0 {
+		color.Yellow("No migrations in '%s'", config.Dir)
+		return nil
+	}
+
+	counts := make(map[string]int)
+	var rows [][]string
+	for _, record := range records {
+		counts[record.Status]++
+		applied := ""
+		if record.AppliedAt != nil {
+			applied = record.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		rows = append(rows, []string{strconv.FormatInt(record.Version, 10), record.Name, record.Status, applied})
+	}
+	color.Green("Migrations of database '%s' (%s):", dbName, config.Dir)
+	if err := writeTable(os.Stdout, []string{"version", "name", "status", "applied at"}, rows); err != nil {
+		return err
+	}
+	fmt.Println()
+	color.Yellow("Current version: %d, %d pending", m.current(), counts[migrationPending])
+	if drifted := counts[migrationModified] + counts[migrationMissing]; drifted > 0 {
+		color.Red("%d applied migrations drifted from their files", drifted)
+	}
+	return nil
+}
+
+// createMigration writes empty up and down files for the next version,
+// zero-padded like the existing ones.
+func createMigration(dir, name string) error {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !migrationNamePattern.MatchString(name) {
+		return usageError("invalid migration name '%s' (use letters, digits, _ and -)", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return wrapError(err, "failed to create migrations directory '%s'", dir)
+	}
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return err
+	}
+
+	next, width := int64(1), 4
+	if len(migrations) > 0 {
+		last := migrations[len(migrations)-1]
+		next = last.Version + 1
+		width = len(strings.TrimSuffix(filepath.Base(last.UpPath), "_"+last.Name+".up.sql"))
+	}
+	base := fmt.Sprintf("%0*d_%s", width, next, name)
+
+	var created []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, base+"."+direction+".sql")
+		content := fmt.Sprintf("-- %s: %s\n\n", strings.ToUpper(direction[:1])+direction[1:], name)
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return wrapError(err, "failed to create '%s'", path)
+		}
+		_, err = file.WriteString(content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return wrapError(err, "failed to write '%s'", path)
+		}
+		created = append(created, path)
+	}
+
+	if ok, err := renderOutput(migrationFilesRecord{Version: next, Name: name, Files: created}); ok {
+		return err
+	}
+	for _, path := range created {
+		color.Green("✓ Created %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,832 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var dbRoleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Manage PostgreSQL roles and users",
+	Long: `Create, list, inspect and drop roles. A user is a role that can log in, which
+is the default for db role create.
+
+Passwords are never passed on the command line: --password prompts for one
+(or reads a line from stdin when it is not a terminal) and --generate-password
+creates a random one and prints it once. They are hashed with SCRAM-SHA-256
+before being sent, so the plain text does not end up in server logs.`,
+}
+
+var dbRoleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List roles with their attributes and memberships",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listRoles()
+	},
+}
+
+var dbRoleCreateCmd = &cobra.Command{
+	Use:   "create [role-name]",
+	Short: "Create a role, by default one that can log in",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := roleOptions{}
+		opts.NoLogin, _ = cmd.Flags().GetBool("no-login")
+		opts.CreateDB, _ = cmd.Flags().GetBool("createdb")
+		opts.CreateRole, _ = cmd.Flags().GetBool("createrole")
+		opts.Superuser, _ = cmd.Flags().GetBool("superuser")
+		opts.MemberOf, _ = cmd.Flags().GetStringArray("member-of")
+		opts.ConnectionLimit, _ = cmd.Flags().GetInt("connection-limit")
+		opts.ValidUntil, _ = cmd.Flags().GetString("valid-until")
+		opts.Password, _ = cmd.Flags().GetBool("password")
+		opts.Generate, _ = cmd.Flags().GetBool("generate-password")
+		return createRole(args[0], opts)
+	},
+}
+
+var dbRoleDropCmd = &cobra.Command{
+	Use:   "drop [role-name]",
+	Short: "Drop a role",
+	Long: `Drop a role after confirmation. A role that still owns objects or holds
+privileges cannot be dropped; --reassign-to hands its objects in every
+database to another role and removes its privileges first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reassignTo, _ := cmd.Flags().GetString("reassign-to")
+		return dropRole(args[0], reassignTo)
+	},
+}
+
+var dbRolePasswdCmd = &cobra.Command{
+	Use:   "passwd [role-name]",
+	Short: "Set the password of a role",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		generate, _ := cmd.Flags().GetBool("generate-password")
+		return setRolePassword(args[0], generate)
+	},
+}
+
+var dbRoleShowCmd = &cobra.Command{
+	Use:   "show [role-name] [database-name]",
+	Short: "Summarize the effective privileges of a role",
+	Long: `Show the attributes and memberships of a role and what it can do in each
+database. With a database name, also list its privileges on the schemas and
+tables of that database and the default privileges that apply to it.
+
+Privileges are effective ones: they include those inherited through role
+membership and those granted to PUBLIC.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbName := ""
+		if len(args) == 2 {
+			dbName = args[1]
+		}
+		return showRole(args[0], dbName)
+	},
+}
+
+var dbGrantCmd = &cobra.Command{
+	Use:   "grant [database-name] [role-name] [privileges]",
+	Short: "Grant privileges on a database, schema or tables",
+	Long: `Grant a comma-separated list of privileges to a role (or PUBLIC). The flags
+select what they apply to:
+
+  (none)                         the database: connect, create, temporary, all
+  --schema S                     the schema: usage, create, all
+  --table T                      tables, repeatable: select, insert, update,
+                                 delete, truncate, references, trigger, all
+  --schema S --all-tables        all existing tables in the schema
+  --schema S --default           tables created in the schema from now on,
+                                 by the current user or --for-role
+
+For tables, read is short for select and write for select, insert, update and
+delete. Granting insert or all with --all-tables or --default also grants use
+of the schema's sequences, so serial columns work.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changePrivileges(cmd, true, args[0], args[1], args[2])
+	},
+}
+
+var dbRevokeCmd = &cobra.Command{
+	Use:   "revoke [database-name] [role-name] [privileges]",
+	Short: "Revoke privileges on a database, schema or tables",
+	Long: `Revoke privileges granted with db grant. It takes the same privileges and
+flags; see tools db grant --help.`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changePrivileges(cmd, false, args[0], args[1], args[2])
+	},
+}
+
+func init() {
+	dbRoleCreateCmd.Flags().Bool("no-login", false, "Create a group role that cannot log in")
+	dbRoleCreateCmd.Flags().Bool("createdb", false, "Allow the role to create databases")
+	dbRoleCreateCmd.Flags().Bool("createrole", false, "Allow the role to create roles")
+	dbRoleCreateCmd.Flags().Bool("superuser", false, "Make the role a superuser")
+	dbRoleCreateCmd.Flags().StringArray("member-of", nil, "Add the role to a group role (repeatable)")
+	dbRoleCreateCmd.Flags().Int("connection-limit", -1, "Maximum concurrent connections (-1 for no limit)")
+	dbRoleCreateCmd.Flags().String("valid-until", "", "Time after which the password stops working")
+	dbRoleCreateCmd.Flags().Bool("password", false, "Prompt for a password")
+	dbRoleCreateCmd.Flags().Bool("generate-password", false, "Set a random password and print it")
+	dbRoleDropCmd.Flags().String("reassign-to", "", "Hand objects owned by the role to this role first")
+	dbRolePasswdCmd.Flags().Bool("generate-password", false, "Set a random password and print it")
+	dbRoleCmd.AddCommand(dbRoleListCmd, dbRoleCreateCmd, dbRoleDropCmd, dbRolePasswdCmd, dbRoleShowCmd)
+
+	for _, c := range []*cobra.Command{dbGrantCmd, dbRevokeCmd} {
+		c.Flags().String("schema", "", "Apply to a schema, or to tables in it")
+		c.Flags().StringArray("table", nil, "Apply to a table, [schema.]table (repeatable)")
+		c.Flags().Bool("all-tables", false, "Apply to all existing tables in --schema")
+		c.Flags().Bool("default", false, "Apply to tables created in --schema from now on")
+		c.Flags().String("for-role", "", "With --default, tables created by this role")
+	}
+	dbGrantCmd.Flags().Bool("with-grant-option", false, "Allow the role to grant the privileges on")
+	dbCmd.AddCommand(dbRoleCmd, dbGrantCmd, dbRevokeCmd)
+}
+
+type roleOptions struct {
+	NoLogin         bool
+	CreateDB        bool
+	CreateRole      bool
+	Superuser       bool
+	MemberOf        []string
+	ConnectionLimit int
+	ValidUntil      string
+	Password        bool
+	Generate        bool
+}
+
+type roleRecord struct {
+	Name            string     `json:"name" db:"name"`
+	Login           bool       `json:"login" db:"login"`
+	Superuser       bool       `json:"superuser" db:"superuser"`
+	CreateDB        bool       `json:"createdb" db:"createdb"`
+	CreateRole      bool       `json:"createrole" db:"createrole"`
+	ConnectionLimit int        `json:"connection_limit" db:"connection_limit"`
+	ValidUntil      *time.Time `json:"valid_until" db:"valid_until"`
+	MemberOf        []string   `json:"member_of" db:"member_of"`
+}
+
+// passwordResult reports a created role or changed password, including the
+// password when it was generated.
+type passwordResult struct {
+	Action   string `json:"action"`
+	Target   string `json:"target"`
+	Status   string `json:"status"`
+	Password string `json:"password,omitempty"`
+}
+
+const roleColumns = `
+		r.rolname AS name,
+		r.rolcanlogin AS login,
+		r.rolsuper AS superuser,
+		r.rolcreatedb AS createdb,
+		r.rolcreaterole AS createrole,
+		r.rolconnlimit AS connection_limit,
+		r.rolvaliduntil AS valid_until,
+		ARRAY(
+			SELECT g.rolname FROM pg_auth_members AS m
+			JOIN pg_roles AS g ON g.oid = m.roleid
+			WHERE m.member = r.oid
+			ORDER BY g.rolname
+		) AS member_of
+	FROM pg_roles AS r`
+
+// roleIdentifier quotes a role name, keeping PUBLIC as the keyword.
+func roleIdentifier(name string) string {
+	if strings.EqualFold(name, "public") {
+		return "PUBLIC"
+	}
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// scramVerifier hashes a password the way PostgreSQL stores it with
+// password_encryption = scram-sha-256, as psql's \password does.
+func scramVerifier(password string) string {
+	const iterations = 4096
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	salted, _ := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+
+	keyed := func(key []byte, message string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(message))
+		return mac.Sum(nil)
+	}
+	clientKey := keyed(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := keyed(salted, "Server Key")
+
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations, encode(salt), encode(storedKey[:]), encode(serverKey))
+}
+
+// passwordClause returns the PASSWORD clause for a role. The server
+// normalizes non-ASCII passwords with SASLprep before hashing, which is not
+// reproduced here, so those and servers before PostgreSQL 10 get the plain
+// text.
+func passwordClause(ctx context.Context, conn *pgx.Conn, password string) (string, error) {
+	var version int
+	if err := conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return "", wrapError(err, "failed to read server version")
+	}
+	ascii := !strings.ContainsFunc(password, func(r rune) bool { return r < 0x20 || r > 0x7e })
+	if version >= 100000 && ascii {
+		return " PASSWORD " + quoteLiteral(scramVerifier(password)), nil
+	}
+	return " PASSWORD " + quoteLiteral(password), nil
+}
+
+// readPassword prompts twice for a password on a terminal, or reads one line
+// from stdin otherwise.
+func readPassword(role string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", wrapError(err, "failed to read password")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return "", usageError("no password on stdin")
+		}
+		return line, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for role '%s': ", role)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", wrapError(err, "failed to read password")
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", wrapError(err, "failed to read password")
+	}
+	if len(first) == 0 {
+		return "", usageError("password must not be empty")
+	}
+	if string(first) != string(second) {
+		return "", usageError("passwords do not match")
+	}
+	return string(first), nil
+}
+
+func reportPassword(result passwordResult, format string, args ...any) error {
+	if ok, err := renderOutput(result); ok {
+		return err
+	}
+	color.Green(format, args...)
+	if result.Password != "" {
+		fmt.Printf("Password: %s\n", result.Password)
+		color.Yellow("Store it now, it is not shown again.")
+	}
+	return nil
+}
+
+func listRoles() error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	roles, err := queryRecords[roleRecord](ctx, conn, `SELECT`+roleColumns+`
+		WHERE r.rolname NOT LIKE 'pg\_%'
+		ORDER BY r.rolname`)
+	if err != nil {
+		return wrapError(err, "failed to list roles")
+	}
+	if ok, err := renderOutput(roles); ok {
+		return err
+	}
+
+	color.Green("Roles:")
+	var rows [][]string
+	for _, role := range roles {
+		rows = append(rows, []string{role.Name, strings.Join(roleAttributes(role), ", "), strings.Join(role.MemberOf, ", ")})
+	}
+	return writeTable(os.Stdout, []string{"role", "attributes", "member of"}, rows)
+}
+
+func roleAttributes(role roleRecord) []string {
+	var attributes []string
+	if role.Login {
+		attributes = append(attributes, "login")
+	}
+	if role.Superuser {
+		attributes = append(attributes, "superuser")
+	}
+	if role.CreateDB {
+		attributes = append(attributes, "createdb")
+	}
+	if role.CreateRole {
+		attributes = append(attributes, "createrole")
+	}
+	if role.ConnectionLimit >= 0 {
+		attributes = append(attributes, fmt.Sprintf("%d connections", role.ConnectionLimit))
+	}
+	if role.ValidUntil != nil {
+		attributes = append(attributes, "valid until "+role.ValidUntil.Local().Format(time.DateTime))
+	}
+	return attributes
+}
+
+func createRole(name string, opts roleOptions) error {
+	if opts.Password && opts.Generate {
+		return usageError("--password and --generate-password cannot be combined")
+	}
+	if opts.NoLogin && (opts.Password || opts.Generate) {
+		return usageError("a role created with --no-login cannot use a password")
+	}
+	password := ""
+	if opts.Generate {
+		password = rand.Text()
+	} else if opts.Password {
+		var err error
+		if password, err = readPassword(name); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	sql := "CREATE ROLE " + pgx.Identifier{name}.Sanitize() + " WITH"
+	if opts.NoLogin {
+		sql += " NOLOGIN"
+	} else {
+		sql += " LOGIN"
+	}
+	if opts.Superuser {
+		sql += " SUPERUSER"
+	}
+	if opts.CreateDB {
+		sql += " CREATEDB"
+	}
+	if opts.CreateRole {
+		sql += " CREATEROLE"
+	}
+	if opts.ConnectionLimit >= 0 {
+		sql += fmt.Sprintf(" CONNECTION LIMIT %d", opts.ConnectionLimit)
+	}
+	if opts.ValidUntil != "" {
+		sql += " VALID UNTIL " + quoteLiteral(opts.ValidUntil)
+	}
+	if password != "" {
+		clause, err := passwordClause(ctx, conn, password)
+		if err != nil {
+			return err
+		}
+		sql += clause
+	}
+	if len(opts.MemberOf) > 0 {
+		groups := make([]string, len(opts.MemberOf))
+		for i, group := range opts.MemberOf {
+			groups[i] = pgx.Identifier{group}.Sanitize()
+		}
+		sql += " IN ROLE " + strings.Join(groups, ", ")
+	}
+
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		return wrapError(err, "failed to create role '%s'", name)
+	}
+	result := passwordResult{Action: "create", Target: name, Status: "created"}
+	if opts.Generate {
+		result.Password = password
+	}
+	return reportPassword(result, "✓ Role '%s' created", name)
+}
+
+func setRolePassword(name string, generate bool) error {
+	var password string
+	if generate {
+		password = rand.Text()
+	} else {
+		var err error
+		if password, err = readPassword(name); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	clause, err := passwordClause(ctx, conn, password)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, "ALTER ROLE "+pgx.Identifier{name}.Sanitize()+" WITH"+clause); err != nil {
+		return wrapError(err, "failed to set password of role '%s'", name)
+	}
+	result := passwordResult{Action: "passwd", Target: name, Status: "changed"}
+	if generate {
+		result.Password = password
+	}
+	return reportPassword(result, "✓ Password of role '%s' changed", name)
+}
+
+func dropRole(name, reassignTo string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, maintenanceDB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", name).Scan(&exists); err != nil {
+		return wrapError(err, "failed to look up role '%s'", name)
+	}
+	if !exists {
+		return notFoundError("role '%s' does not exist", name)
+	}
+
+	color.Yellow("WARNING: This will permanently drop role '%s'", name)
+	if reassignTo != "" {
+		color.Yellow("Objects it owns in every database will be handed to '%s' and its privileges removed.", reassignTo)
+	}
+	fmt.Print("Type the role name to confirm: ")
+	var confirm string
+	fmt.Scanln(&confirm)
+	if confirm != name {
+		return cancelledError("drop cancelled")
+	}
+
+	if reassignTo != "" {
+		// REASSIGN OWNED and DROP OWNED only act on the current database
+		var databases []string
+		rows, err := conn.Query(ctx, "SELECT datname FROM pg_database WHERE datallowconn ORDER BY datname")
+		if err == nil {
+			databases, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		}
+		if err != nil {
+			return wrapError(err, "failed to list databases")
+		}
+		for _, database := range databases {
+			printProgress("Reassigning objects in database '%s'...", database)
+			dbConn, err := connectPostgres(ctx, database)
+			if err != nil {
+				return err
+			}
+			_, err = dbConn.Exec(ctx, fmt.Sprintf("REASSIGN OWNED BY %s TO %s; DROP OWNED BY %s",
+				pgx.Identifier{name}.Sanitize(), pgx.Identifier{reassignTo}.Sanitize(), pgx.Identifier{name}.Sanitize()))
+			dbConn.Close(ctx)
+			if err != nil {
+				return wrapError(err, "failed to reassign objects of role '%s' in database '%s'", name, database)
+			}
+		}
+	}
+
+	if _, err := conn.Exec(ctx, "DROP ROLE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		// 2BP01: the role still owns objects or holds privileges
+		if pgErrorCode(err) == "2BP01" {
+			return conflictError("role '%s' still owns objects or holds privileges, use --reassign-to: %v", name, err)
+		}
+		return wrapError(err, "failed to drop role '%s'", name)
+	}
+	return reportAction("drop", name, "dropped", "✓ Role '%s' dropped", name)
+}
+
+// Privileges accepted at each level of db grant and db revoke.
+var privilegeLevels = map[string][]string{
+	"database": {"CONNECT", "CREATE", "TEMPORARY"},
+	"schema":   {"USAGE", "CREATE"},
+	"table":    {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+}
+
+// parsePrivileges validates a comma-separated privilege list for a level.
+func parsePrivileges(level, spec string) ([]string, error) {
+	var privileges []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		expanded := []string{name}
+		switch {
+		case name == "ALL":
+			return []string{"ALL PRIVILEGES"}, nil
+		case name == "TEMP" && level == "database":
+			expanded = []string{"TEMPORARY"}
+		case name == "READ" && level == "table":
+			expanded = []string{"SELECT"}
+		case name == "WRITE" && level == "table":
+			expanded = []string{"SELECT", "INSERT", "UPDATE", "DELETE"}
+		case !slices.Contains(privilegeLevels[level], name):
+			valid := strings.ToLower(strings.Join(privilegeLevels[level], ", "))
+			return nil, usageError("invalid %s privilege '%s' (valid: %s, all)", level, strings.ToLower(name), valid)
+		}
+		for _, privilege := range expanded {
+			if !slices.Contains(privileges, privilege) {
+				privileges = append(privileges, privilege)
+			}
+		}
+	}
+	return privileges, nil
+}
+
+// privilegeStatements builds the GRANT or REVOKE statements for the flags of
+// db grant and db revoke, and describes what they apply to.
+func privilegeStatements(cmd *cobra.Command, grant bool, dbName, role, spec string) ([]string, string, error) {
+	schema, _ := cmd.Flags().GetString("schema")
+	tables, _ := cmd.Flags().GetStringArray("table")
+	allTables, _ := cmd.Flags().GetBool("all-tables")
+	defaults, _ := cmd.Flags().GetBool("default")
+	forRole, _ := cmd.Flags().GetString("for-role")
+	grantOption := false
+	if grant {
+		grantOption, _ = cmd.Flags().GetBool("with-grant-option")
+	}
+
+	switch {
+	case len(tables) > 0 && (schema != "" || allTables || defaults):
+		return nil, "", usageError("--table cannot be combined with --schema, --all-tables or --default")
+	case (allTables || defaults) && schema == "":
+		return nil, "", usageError("--all-tables and --default need --schema")
+	case allTables && defaults:
+		return nil, "", usageError("--all-tables and --default cannot be combined")
+	case forRole != "" && !defaults:
+		return nil, "", usageError("--for-role only applies with --default")
+	}
+
+	level := "table"
+	if len(tables) == 0 && !allTables && !defaults {
+		level = "database"
+		if schema != "" {
+			level = "schema"
+		}
+	}
+	privileges, err := parsePrivileges(level, spec)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var object, description string
+	switch {
+	case level == "database":
+		object, description = "DATABASE "+pgx.Identifier{dbName}.Sanitize(), "database '"+dbName+"'"
+	case level == "schema":
+		object, description = "SCHEMA "+pgx.Identifier{schema}.Sanitize(), "schema '"+schema+"'"
+	case allTables:
+		object, description = "ALL TABLES IN SCHEMA "+pgx.Identifier{schema}.Sanitize(), "all tables in schema '"+schema+"'"
+	case defaults:
+		object, description = "TABLES", "new tables in schema '"+schema+"'"
+	default:
+		names := make([]string, len(tables))
+		for i, table := range tables {
+			tableSchema, tableName, err := parseQualifiedName(table)
+			if err != nil {
+				return nil, "", err
+			}
+			names[i] = qualifiedName(tableSchema, tableName)
+		}
+		object, description = "TABLE "+strings.Join(names, ", "), "table "+strings.Join(tables, ", ")
+	}
+
+	statement := func(privileges []string, object string) string {
+		if grant {
+			sql := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(privileges, ", "), object, roleIdentifier(role))
+			if grantOption {
+				sql += " WITH GRANT OPTION"
+			}
+			return sql
+		}
+		return fmt.Sprintf("REVOKE %s ON %s FROM %s", strings.Join(privileges, ", "), object, roleIdentifier(role))
+	}
+	statements := []string{statement(privileges, object)}
+
+	// Inserting into a serial column needs the sequence as well
+	if (allTables || defaults) && (slices.Contains(privileges, "INSERT") || privileges[0] == "ALL PRIVILEGES") {
+		sequences := "ALL SEQUENCES IN SCHEMA " + pgx.Identifier{schema}.Sanitize()
+		if defaults {
+			sequences = "SEQUENCES"
+		}
+		statements = append(statements, statement([]string{"USAGE", "SELECT"}, sequences))
+	}
+	if defaults {
+		prefix := "ALTER DEFAULT PRIVILEGES"
+		if forRole != "" {
+			prefix += " FOR ROLE " + pgx.Identifier{forRole}.Sanitize()
+			description += " created by '" + forRole + "'"
+		}
+		prefix += " IN SCHEMA " + pgx.Identifier{schema}.Sanitize() + " "
+		for i := range statements {
+			statements[i] = prefix + statements[i]
+		}
+	}
+	return statements, description, nil
+}
+
+func changePrivileges(cmd *cobra.Command, grant bool, dbName, role, spec string) error {
+	statements, description, err := privilegeStatements(cmd, grant, dbName, role, spec)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	action, status, verb, preposition := "grant", "granted", "Granted", "to"
+	if !grant {
+		action, status, verb, preposition = "revoke", "revoked", "Revoked", "from"
+	}
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return wrapError(err, "failed to %s privileges on %s", action, description)
+	}
+	return reportAction(action, role, status, "✓ %s %s on %s %s '%s'", verb, strings.ToLower(spec), description, preposition, role)
+}
+
+type privilegeRecord struct {
+	Object     string   `json:"object" db:"object"`
+	Privileges []string `json:"privileges" db:"privileges"`
+}
+
+type defaultPrivilegeRecord struct {
+	Owner      string   `json:"owner" db:"owner"`
+	Schema     string   `json:"schema" db:"schema"`
+	ObjectType string   `json:"object_type" db:"object_type"`
+	Privileges []string `json:"privileges" db:"privileges"`
+}
+
+type roleDetails struct {
+	Role              roleRecord               `json:"role"`
+	Databases         []privilegeRecord        `json:"databases"`
+	Database          string                   `json:"database,omitempty"`
+	Schemas           []privilegeRecord        `json:"schemas,omitempty"`
+	Tables            []privilegeRecord        `json:"tables,omitempty"`
+	DefaultPrivileges []defaultPrivilegeRecord `json:"default_privileges,omitempty"`
+}
+
+// privilegeCheck lists the privileges of a role on the objects of a catalog
+// query, using the given has_*_privilege function.
+func privilegeCheck(function, object, from string, privileges []string) string {
+	checks := make([]string, len(privileges))
+	for i, privilege := range privileges {
+		checks[i] = fmt.Sprintf("CASE WHEN %s($1, o.oid, '%s') THEN '%s' END", function, privilege, strings.ToLower(privilege))
+	}
+	return fmt.Sprintf(`
+		SELECT object, privileges FROM (
+			SELECT %s AS object, array_remove(ARRAY[%s], NULL) AS privileges
+			%s
+		) AS p
+		WHERE cardinality(privileges) > 0
+		ORDER BY object`, object, strings.Join(checks, ", "), from)
+}
+
+func showRole(name, dbName string) error {
+	connectTo := dbName
+	if connectTo == "" {
+		connectTo = maintenanceDB
+	}
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, connectTo)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	roles, err := queryRecords[roleRecord](ctx, conn, `SELECT`+roleColumns+` WHERE r.rolname = $1`, name)
+	if err != nil {
+		return wrapError(err, "failed to look up role '%s'", name)
+	}
+	if len(roles) == 0 {
+		return notFoundError("role '%s' does not exist", name)
+	}
+	details := roleDetails{Role: roles[0], Database: dbName}
+
+	details.Databases, err = queryRecords[privilegeRecord](ctx, conn, privilegeCheck("has_database_privilege",
+		"o.datname", "FROM pg_database AS o WHERE NOT o.datistemplate", privilegeLevels["database"]), name)
+	if err != nil {
+		return wrapError(err, "failed to read database privileges")
+	}
+
+	if dbName != "" {
+		details.Schemas, err = queryRecords[privilegeRecord](ctx, conn, privilegeCheck("has_schema_privilege",
+			"o.nspname::text", `FROM pg_namespace AS o
+			WHERE o.nspname NOT IN ('pg_catalog', 'information_schema') AND o.nspname NOT LIKE 'pg\_%'`,
+			privilegeLevels["schema"]), name)
+		if err != nil {
+			return wrapError(err, "failed to read schema privileges")
+		}
+		details.Tables, err = queryRecords[privilegeRecord](ctx, conn, privilegeCheck("has_table_privilege",
+			"format('%I.%I', n.nspname, o.relname)", `FROM pg_class AS o
+			JOIN pg_namespace AS n ON n.oid = o.relnamespace
+			WHERE o.relkind IN ('r', 'p', 'v', 'm', 'f')
+				AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%'`,
+			privilegeLevels["table"]), name)
+		if err != nil {
+			return wrapError(err, "failed to read table privileges")
+		}
+		details.DefaultPrivileges, err = queryRecords[defaultPrivilegeRecord](ctx, conn, `
+			SELECT
+				pg_get_userbyid(d.defaclrole) AS owner,
+				coalesce(n.nspname, '') AS schema,
+				CASE d.defaclobjtype WHEN 'r' THEN 'tables' WHEN 'S' THEN 'sequences' WHEN 'f' THEN 'functions'
+					WHEN 'T' THEN 'types' WHEN 'n' THEN 'schemas' ELSE d.defaclobjtype::text END AS object_type,
+				array_agg(lower(a.privilege_type) ORDER BY a.privilege_type) AS privileges
+			FROM pg_default_acl AS d
+			LEFT JOIN pg_namespace AS n ON n.oid = d.defaclnamespace
+			CROSS JOIN LATERAL aclexplode(d.defaclacl) AS a
+			WHERE a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $1)
+			GROUP BY 1, 2, 3
+			ORDER BY 1, 2, 3`, name)
+		if err != nil {
+			return wrapError(err, "failed to read default privileges")
+		}
+	}
+
+	if ok, err := renderOutput(details); ok {
+		return err
+	}
+
+	role := details.Role
+	color.Green("Role '%s':", role.Name)
+	fmt.Printf("  Attributes: %s\n", strings.Join(roleAttributes(role), ", "))
+	if len(role.MemberOf) > 0 {
+		fmt.Printf("  Member of:  %s\n", strings.Join(role.MemberOf, ", "))
+	}
+	if role.Superuser {
+		color.Yellow("  Superusers bypass all privilege checks.")
+	}
+
+	printPrivileges := func(title, header string, records []privilegeRecord) {
+		fmt.Println()
+		color.Cyan(title)
+		if len(records) == 0 {
+			fmt.Println("  none")
+			return
+		}
+		var rows [][]string
+		for _, record := range records {
+			rows = append(rows, []string{record.Object, strings.Join(record.Privileges, ", ")})
+		}
+		writeTable(os.Stdout, []string{header, "privileges"}, rows)
+	}
+	printPrivileges("Databases", "database", details.Databases)
+	if dbName == "" {
+		fmt.Println()
+		fmt.Printf("Pass a database name to see schema and table privileges: tools db role show %s <database>\n", name)
+		return nil
+	}
+	printPrivileges(fmt.Sprintf("Schemas in '%s'", dbName), "schema", details.Schemas)
+	printPrivileges(fmt.Sprintf("Tables in '%s'", dbName), "table", details.Tables)
+
+	fmt.Println()
+	color.Cyan("Default privileges in '%s'", dbName)
+	if len(details.DefaultPrivileges) == 0 {
+		fmt.Println("  none")
+		return nil
+	}
+	var rows [][]string
+	for _, record := range details.DefaultPrivileges {
+		schema := record.Schema
+		if schema == "" {
+			schema = "(all)"
+		}
+		rows = append(rows, []string{record.Owner, schema, record.ObjectType, strings.Join(record.Privileges, ", ")})
+	}
+	return writeTable(os.Stdout, []string{"created by", "schema", "objects", "privileges"}, rows)
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+)
+
+// Error handling modes of db exec --file.
+const (
+	onErrorStop     = "stop"
+	onErrorContinue = "continue"
+)
+
+type scriptOptions struct {
+	Vars              []string
+	SingleTransaction bool
+	OnError           string
+}
+
+// scriptStatementRecord is the outcome of one statement of a script.
+type scriptStatementRecord struct {
+	Statement  int    `json:"statement"`
+	Line       int    `json:"line"`
+	SQL        string `json:"sql"`
+	Status     string `json:"status"`
+	Rows       int64  `json:"rows"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// parseVariables turns key=value flags into a map.
+func parseVariables(vars []string) (map[string]string, error) {
+	result := make(map[string]string, len(vars))
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" || !isVariableName(name) {
+			return nil, usageError("invalid --var '%s' (expected name=value)", v)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+func isVariableName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierByte(name[i]) || name[i] == '$' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// substituteVariables replaces psql-style variables outside quotes and
+// comments: :name with the raw value, :'name' with it as a string literal and
+// :"name" as an identifier. Casts (::) and undefined :name are left alone, as
+// psql does; an undefined quoted reference is an error.
+func substituteVariables(script string, vars map[string]string) (string, []string, error) {
+	var b strings.Builder
+	var used []string
+	for i := 0; i < len(script); i++ {
+		if end, _, ok := skipQuoted(script, i); ok {
+			b.WriteString(script[i : end+1])
+			i = end
+			continue
+		}
+		if script[i] != ':' || i+1 == len(script) {
+			b.WriteByte(script[i])
+			continue
+		}
+
+		next := script[i+1]
+		switch {
+		case next == ':':
+			b.WriteString("::")
+			i++
+			continue
+		case next == '\'' || next == '"':
+			end := strings.IndexByte(script[i+2:], next)
+			if end < 0 || !isVariableName(script[i+2:i+2+end]) {
+				break
+			}
+			name := script[i+2 : i+2+end]
+			value, ok := vars[name]
+			if !ok {
+				return "", nil, usageError("variable '%s' is used but not set, pass --var %s=...", name, name)
+			}
+			if next == '\'' {
+				b.WriteString("'" + strings.ReplaceAll(value, "'", "''") + "'")
+			} else {
+				b.WriteString(pgx.Identifier{value}.Sanitize())
+			}
+			used = append(used, name)
+			i += 2 + end
+			continue
+		case isIdentifierByte(next) && next != '$' && (next < '0' || next > '9'):
+			end := i + 1
+			for end < len(script) && isIdentifierByte(script[end]) && script[end] != '$' {
+				end++
+			}
+			if value, ok := vars[script[i+1:end]]; ok {
+				b.WriteString(value)
+				used = append(used, script[i+1:end])
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(':')
+	}
+	return b.String(), used, nil
+}
+
+// summarizeSQL collapses a statement onto one shortened line for progress
+// output.
+func summarizeSQL(sql string) string {
+	return oneLine(sql, 60)
+}
+
+// runScript runs the statements of a SQL file ("-" for stdin) one by one.
+func runScript(dbName, file string, opts scriptOptions) error {
+	if opts.OnError != onErrorStop && opts.OnError != onErrorContinue {
+		return usageError("invalid --on-error '%s' (valid: stop, continue)", opts.OnError)
+	}
+	vars, err := parseVariables(opts.Vars)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if file == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return wrapError(err, "failed to read script '%s'", file)
+	}
+	script, used, err := substituteVariables(string(content), vars)
+	if err != nil {
+		return err
+	}
+	for name := range vars {
+		if !slices.Contains(used, name) {
+			printError("Warning: variable '%s' is not used in the script", name)
+		}
+	}
+	statements := splitStatements(script)
+	if len(statements) == 0 {
+		return usageError("script '%s' has no statements", file)
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if opts.SingleTransaction {
+		if _, err := conn.Exec(ctx, "BEGIN"); err != nil {
+			return wrapError(err, "failed to begin transaction")
+		}
+	}
+	// In a transaction a failed statement aborts it, so continuing past one
+	// needs a savepoint around every statement.
+	savepoints := opts.SingleTransaction && opts.OnError == onErrorContinue
+
+	text := outputFormat() == outputText
+	records := make([]scriptStatementRecord, 0, len(statements))
+	var failed []scriptStatementRecord
+	offset := 0
+	for i, statement := range statements {
+		position := offset + strings.Index(script[offset:], statement)
+		offset = position + len(statement)
+		record := scriptStatementRecord{
+			Statement: i + 1,
+			Line:      strings.Count(script[:position], "\n") + 1,
+			SQL:       summarizeSQL(statement),
+		}
+		printProgress("[%d/%d] line %d: %s", record.Statement, len(statements), record.Line, record.SQL)
+
+		if savepoints {
+			conn.Exec(ctx, "SAVEPOINT tools_script")
+		}
+		started := time.Now()
+		results, err := execStatements(ctx, conn, statement)
+		record.DurationMs = time.Since(started).Milliseconds()
+		if err != nil {
+			record.Status, record.Error = "error", err.Error()
+			printError("  %v", err)
+			records = append(records, record)
+			failed = append(failed, record)
+			if savepoints {
+				conn.Exec(ctx, "ROLLBACK TO SAVEPOINT tools_script")
+			}
+			if opts.OnError == onErrorStop {
+				if opts.SingleTransaction {
+					conn.Exec(ctx, "ROLLBACK")
+				}
+				if text {
+					printScriptSummary(records)
+				}
+				rolledBack := ""
+				if opts.SingleTransaction {
+					rolledBack = ", transaction rolled back"
+				}
+				return wrapError(err, "statement %d (line %d) failed%s", record.Statement, record.Line, rolledBack)
+			}
+			continue
+		}
+
+		for _, result := range results {
+			record.Status = result.Tag
+			record.Rows += result.RowsAffected
+		}
+		records = append(records, record)
+		if text {
+			for _, result := range results {
+				if result.Rows != nil && len(result.Rows.Values) > 0 {
+					if err := printStatements([]statementResult{result}, false); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	if opts.SingleTransaction {
+		if _, err := conn.Exec(ctx, "COMMIT"); err != nil {
+			return wrapError(err, "failed to commit transaction")
+		}
+	}
+
+	if ok, err := renderOutput(records); ok {
+		if err == nil && len(failed) > 0 {
+			err = newError(KindGeneral, "%d of %d statements failed", len(failed), len(records))
+		}
+		return err
+	}
+	printScriptSummary(records)
+	if len(failed) > 0 {
+		return newError(KindGeneral, "%d of %d statements failed", len(failed), len(records))
+	}
+	color.Green("✓ Ran %d statements from '%s'", len(records), file)
+	return nil
+}
+
+func printScriptSummary(records []scriptStatementRecord) {
+	fmt.Println()
+	var rows [][]string
+	for _, record := range records {
+		rows = append(rows, []string{
+			fmt.Sprint(record.Statement), fmt.Sprint(record.Line), record.SQL, record.Status,
+			fmt.Sprint(record.Rows), fmt.Sprintf("%d ms", record.DurationMs),
+		})
+	}
+	writeTable(os.Stdout, []string{"#", "line", "statement", "status", "rows", "time"}, rows)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	vars := map[string]string{"days": "90", "tbl": `My "Table"`, "name": "O'Brien"}
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		used    []string
+		wantErr bool
+	}{
+		{name: "raw value", script: "WHERE age > :days", want: "WHERE age > 90", used: []string{"days"}},
+		{name: "string literal", script: "SELECT :'name'", want: "SELECT 'O''Brien'", used: []string{"name"}},
+		{name: "identifier", script: `SELECT * FROM :"tbl"`, want: `SELECT * FROM "My ""Table"""`, used: []string{"tbl"}},
+		{name: "cast is kept", script: "SELECT '1'::int, now()::date", want: "SELECT '1'::int, now()::date"},
+		{name: "undefined raw is kept", script: "SELECT :missing", want: "SELECT :missing"},
+		{name: "undefined quoted is an error", script: "SELECT :'missing'", wantErr: true},
+		{name: "inside string", script: "SELECT ':days', :days", want: "SELECT ':days', 90", used: []string{"days"}},
+		{name: "inside comment", script: "-- :days\n/* :days */ SELECT :days", want: "-- :days\n/* :days */ SELECT 90", used: []string{"days"}},
+		{name: "inside dollar quote", script: "SELECT $$:days$$", want: "SELECT $$:days$$"},
+		{name: "array slice", script: "SELECT a[1:2]", want: "SELECT a[1:2]"},
+		{name: "name stops at non-identifier", script: "SELECT :days+1", want: "SELECT 90+1", used: []string{"days"}},
+		{name: "trailing colon", script: "SELECT 1:", want: "SELECT 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, used, err := substituteVariables(tt.script, vars)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("substituteVariables(%q) = %q, want an error", tt.script, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("substituteVariables(%q): %v", tt.script, err)
+			}
+			if got != tt.want {
+				t.Errorf("substituteVariables(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+			if !slices.Equal(used, tt.used) {
+				t.Errorf("used = %q, want %q", used, tt.used)
+			}
+		})
+	}
+}
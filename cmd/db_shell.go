@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+var dbShellCmd = &cobra.Command{
+	Use:   "shell [database-name]",
+	Short: "Open an interactive SQL shell",
+	Long: `Run SQL interactively against a database. Statements can span several
+lines and run once a line ends them with a semicolon. Tab completes SQL
+keywords, table and column names. History is kept across sessions in
+shell.history_file from the config file, by default
+<user config dir>/tools/shell_history.
+
+Ctrl-C clears the current input or cancels a running query, Ctrl-D quits.
+
+` + shellMetaHelp,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runShell(args[0])
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbShellCmd)
+}
+
+const shellMetaHelp = `Meta-commands:
+
+  \dt              list tables
+  \d [table]       describe a table, or list tables
+  \l               list databases
+  \c database      connect to another database
+  \timing [on|off] show how long each query took (default on)
+  \x [on|off]      expanded display, one field per line
+  \?               show this help
+  \q               quit`
+
+var shellKeywords = []string{
+	"ALTER", "AND", "AS", "ASC", "BEGIN", "BETWEEN", "BY", "CASE", "COMMIT", "COUNT", "CREATE",
+	"DELETE", "DESC", "DISTINCT", "DROP", "ELSE", "END", "EXISTS", "EXPLAIN", "ANALYZE", "FROM",
+	"GROUP", "HAVING", "ILIKE", "IN", "INDEX", "INNER", "INSERT", "INTO", "IS", "JOIN", "LEFT",
+	"LIKE", "LIMIT", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER", "RETURNING", "RIGHT",
+	"ROLLBACK", "SELECT", "SET", "TABLE", "THEN", "TRUNCATE", "UNION", "UPDATE", "VALUES", "VIEW",
+	"WHEN", "WHERE", "WITH",
+}
+
+var shellMetaCommands = []string{`\c`, `\connect`, `\d`, `\dt`, `\l`, `\q`, `\timing`, `\x`, `\?`}
+
+// sqlShell is the state of an interactive session.
+type sqlShell struct {
+	conn     *pgx.Conn
+	dbName   string
+	timing   bool
+	expanded bool
+	// Completion candidates, reloaded after DDL
+	tables  []string
+	columns map[string][]string
+}
+
+func runShell(dbName string) error {
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	shell := &sqlShell{conn: conn, dbName: dbName, timing: true}
+	defer func() { shell.conn.Close(ctx) }()
+	shell.loadNames(ctx)
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetMultiLineMode(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(shell.complete)
+
+	history, err := shellHistoryPath()
+	if err != nil {
+		printError("Warning: history disabled: %v", err)
+	} else if file, err := os.Open(history); err == nil {
+		line.ReadHistory(file)
+		file.Close()
+	}
+	defer func() {
+		if history == "" {
+			return
+		}
+		if file, err := os.Create(history); err == nil {
+			line.WriteHistory(file)
+			file.Close()
+		}
+	}()
+
+	color.Green("Connected to database '%s'. Type \\? for help, \\q to quit.", dbName)
+	var buffer []string
+	for {
+		prompt := shell.dbName + "=> "
+		if len(buffer) > 0 {
+			prompt = shell.dbName + "-> "
+		}
+		input, err := line.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			buffer = nil
+			continue
+		}
+		if err == io.EOF {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return wrapError(err, "failed to read input")
+		}
+
+		if len(buffer) == 0 && strings.HasPrefix(strings.TrimSpace(input), `\`) {
+			line.AppendHistory(strings.TrimSpace(input))
+			if quit := shell.meta(ctx, strings.TrimSpace(input)); quit {
+				return nil
+			}
+			continue
+		}
+
+		buffer = append(buffer, input)
+		script := strings.Join(buffer, "\n")
+		statements, rest := scanStatements(script)
+		if rest != "" {
+			continue
+		}
+		buffer = nil
+		if len(statements) == 0 {
+			continue
+		}
+		line.AppendHistory(strings.Join(strings.Fields(script), " "))
+		shell.run(ctx, script)
+	}
+}
+
+// shellHistoryPath returns shell.history_file from the config file or
+// <user config dir>/tools/shell_history, creating its directory.
+func shellHistoryPath() (string, error) {
+	path := settingString("shell.history_file")
+	if path == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(configDir, "tools", "shell_history")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// run executes the statements of script, cancelling them on Ctrl-C.
+func (s *sqlShell) run(ctx context.Context, script string) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupt:
+			s.conn.PgConn().CancelRequest(context.Background())
+		case <-done:
+		}
+	}()
+
+	started := time.Now()
+	statements, err := execStatements(ctx, s.conn, script)
+	elapsed := time.Since(started)
+	signal.Stop(interrupt)
+	close(done)
+
+	if err != nil {
+		printError("%v", err)
+		if s.conn.IsClosed() {
+			s.reconnect(ctx, s.dbName)
+		}
+		return
+	}
+	if err := printStatements(statements, s.expanded); err != nil {
+		printError("%v", err)
+	}
+	if s.timing {
+		fmt.Printf("Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
+
+	for _, statement := range statements {
+		verb, _, _ := strings.Cut(statement.Tag, " ")
+		if verb == "CREATE" || verb == "ALTER" || verb == "DROP" {
+			s.loadNames(ctx)
+			break
+		}
+	}
+}
+
+// meta runs a backslash command and reports whether the shell should quit.
+func (s *sqlShell) meta(ctx context.Context, input string) (quit bool) {
+	fields := strings.Fields(input)
+	command, args := fields[0], fields[1:]
+	toggle := func(current bool) bool {
+		if len(args) == 0 {
+			return !current
+		}
+		return args[0] == "on"
+	}
+
+	var err error
+	switch command {
+	case `\q`:
+		return true
+	case `\?`:
+		fmt.Println(shellMetaHelp)
+	case `\dt`:
+		err = printTables(ctx, s.conn, s.dbName)
+	case `\d`:
+		if len(args) == 0 {
+			err = printTables(ctx, s.conn, s.dbName)
+			break
+		}
+		var schema, table string
+		if schema, table, err = parseQualifiedName(args[0]); err == nil {
+			err = printTableDetails(ctx, s.conn, s.dbName, schema, table, 5)
+		}
+	case `\l`:
+		err = listDatabases()
+	case `\c`, `\connect`:
+		if len(args) == 0 {
+			fmt.Printf("Connected to database '%s'\n", s.dbName)
+			break
+		}
+		s.reconnect(ctx, args[0])
+	case `\timing`:
+		s.timing = toggle(s.timing)
+		fmt.Printf("Timing is %s.\n", onOff(s.timing))
+	case `\x`:
+		s.expanded = toggle(s.expanded)
+		fmt.Printf("Expanded display is %s.\n", onOff(s.expanded))
+	default:
+		err = fmt.Errorf("invalid command %s, try \\? for help", command)
+	}
+	if err != nil {
+		printError("%v", err)
+	}
+	return false
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// reconnect switches to dbName, keeping the current connection if that fails
+// and it is still open.
+func (s *sqlShell) reconnect(ctx context.Context, dbName string) {
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		printError("%v", err)
+		return
+	}
+	s.conn.Close(ctx)
+	s.conn, s.dbName = conn, dbName
+	s.loadNames(ctx)
+	color.Green("Connected to database '%s'.", dbName)
+}
+
+// loadNames reads the table and column names offered for completion.
+func (s *sqlShell) loadNames(ctx context.Context) {
+	rows, err := s.conn.Query(ctx, `
+		SELECT table_schema, table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name, ordinal_position`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	s.tables, s.columns = nil, map[string][]string{}
+	for rows.Next() {
+		var schema, table, column string
+		if err := rows.Scan(&schema, &table, &column); err != nil {
+			return
+		}
+		if schema != "public" {
+			table = schema + "." + table
+		}
+		if _, ok := s.columns[table]; !ok {
+			s.tables = append(s.tables, table)
+		}
+		s.columns[table] = append(s.columns[table], column)
+	}
+}
+
+// complete offers keywords, tables and columns for the word before the
+// cursor, and meta-commands at the start of the line. liner passes pos as a
+// rune index.
+func (s *sqlShell) complete(line string, pos int) (head string, completions []string, tail string) {
+	runes := []rune(line)
+	pos = min(pos, len(runes))
+	start := pos
+	for start > 0 {
+		r := runes[start-1]
+		if r < 0x80 && !isIdentifierByte(byte(r)) && r != '.' && r != '\\' {
+			break
+		}
+		start--
+	}
+	head, word, tail := string(runes[:start]), string(runes[start:pos]), string(runes[pos:])
+	if word == "" {
+		return head, nil, tail
+	}
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(word, `\`):
+		candidates = shellMetaCommands
+	case strings.Contains(word, "."):
+		// table.column, or schema.table
+		table := word[:strings.LastIndex(word, ".")]
+		for _, column := range s.columns[table] {
+			candidates = append(candidates, table+"."+column)
+		}
+		candidates = append(candidates, s.tables...)
+	default:
+		upper := word[0] >= 'A' && word[0] <= 'Z'
+		for _, keyword := range shellKeywords {
+			if !upper {
+				keyword = strings.ToLower(keyword)
+			}
+			candidates = append(candidates, keyword)
+		}
+		candidates = append(candidates, s.tables...)
+		for _, table := range s.tables {
+			candidates = append(candidates, s.columns[table]...)
+		}
+	}
+
+	lower := strings.ToLower(word)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), lower) && !slices.Contains(completions, candidate) {
+			completions = append(completions, candidate)
+		}
+	}
+	slices.Sort(completions)
+	return head, completions, tail
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+var dbTopQueriesCmd = &cobra.Command{
+	Use:   "top-queries [database-name]",
+	Short: "Rank the most expensive queries from pg_stat_statements",
+	Long: `Rank the normalized queries recorded by pg_stat_statements for a database by
+total time (default), mean time, calls, rows or shared-buffer hits.
+
+--reset clears the statistics of the database after printing the report, to
+start a fresh measurement; other databases keep theirs. It needs PostgreSQL 12
+or later. pg_stat_statements must be loaded through
+shared_preload_libraries and created in the database; the command explains
+how if it is not.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sortBy, _ := cmd.Flags().GetString("sort")
+		limit, _ := cmd.Flags().GetInt("limit")
+		reset, _ := cmd.Flags().GetBool("reset")
+		return showTopQueries(args[0], sortBy, limit, reset)
+	},
+}
+
+func init() {
+	dbTopQueriesCmd.Flags().StringP("sort", "s", "total", "Rank by: total, mean, calls, rows, hits")
+	dbTopQueriesCmd.Flags().IntP("limit", "l", 20, "Number of queries to show")
+	dbTopQueriesCmd.Flags().Bool("reset", false, "Reset the database's pg_stat_statements after the report")
+	dbCmd.AddCommand(dbTopQueriesCmd)
+}
+
+// topQuerySorts maps --sort to the column it orders by.
+var topQuerySorts = map[string]string{
+	"total": "total_ms",
+	"mean":  "mean_ms",
+	"calls": "calls",
+	"rows":  "rows",
+	"hits":  "shared_blks_hit",
+}
+
+type topQueryRecord struct {
+	QueryID        *int64   `json:"query_id" db:"query_id"`
+	User           string   `json:"user" db:"user"`
+	Calls          int64    `json:"calls" db:"calls"`
+	TotalMs        float64  `json:"total_ms" db:"total_ms"`
+	MeanMs         float64  `json:"mean_ms" db:"mean_ms"`
+	Rows           int64    `json:"rows" db:"rows"`
+	SharedBlksHit  int64    `json:"shared_blks_hit" db:"shared_blks_hit"`
+	SharedBlksRead int64    `json:"shared_blks_read" db:"shared_blks_read"`
+	HitPercent     *float64 `json:"hit_percent" db:"hit_percent"`
+	TimePercent    float64  `json:"time_percent" db:"time_percent"`
+	Query          string   `json:"query" db:"query"`
+}
+
+// formatMillis prints a duration given in milliseconds compactly.
+func formatMillis(ms float64) string {
+	switch {
+	case ms < 1:
+		return fmt.Sprintf("%.3fms", ms)
+	case ms < 1000:
+		return fmt.Sprintf("%.1fms", ms)
+	}
+	seconds := ms / 1000
+	return formatAge(&seconds)
+}
+
+// statStatementsMissing explains how to enable pg_stat_statements.
+func statStatementsMissing(ctx context.Context, conn *pgx.Conn, dbName string, installed bool) error {
+	var preload string
+	conn.QueryRow(ctx, "SELECT current_setting('shared_preload_libraries')").Scan(&preload)
+
+	var steps []string
+	if !slices.Contains(strings.Split(strings.ReplaceAll(preload, " ", ""), ","), "pg_stat_statements") {
+		current := preload
+		if current == "" {
+			current = "empty"
+		}
+		steps = append(steps, fmt.Sprintf("add pg_stat_statements to shared_preload_libraries in postgresql.conf (currently %s) and restart the server", current))
+	}
+	if !installed {
+		steps = append(steps, fmt.Sprintf("run: tools db exec %s \"CREATE EXTENSION pg_stat_statements\"", dbName))
+	}
+	message := fmt.Sprintf("pg_stat_statements is not available in database '%s'. To enable it:", dbName)
+	for i, step := range steps {
+		message += fmt.Sprintf("\n  %d. %s", i+1, step)
+	}
+	return notFoundError("%s", message)
+}
+
+// resetStatStatements clears the pg_stat_statements entries of the current
+// database only.
+func resetStatStatements(ctx context.Context, conn *pgx.Conn, dbName string) error {
+	_, err := conn.Exec(ctx, `SELECT pg_stat_statements_reset(0, (SELECT oid FROM pg_database WHERE datname = current_database()), 0)`)
+	if err != nil {
+		// 42883: an extension older than 1.7 only resets everything
+		if pgErrorCode(err) == "42883" {
+			return newError(KindGeneral, "pg_stat_statements is too old to reset one database, run: tools db exec %s \"ALTER EXTENSION pg_stat_statements UPDATE\"", dbName)
+		}
+		return wrapError(err, "failed to reset pg_stat_statements")
+	}
+	printProgress("Statistics of database '%s' reset at %s", dbName, time.Now().Format(time.TimeOnly))
+	return nil
+}
+
+func showTopQueries(dbName, sortBy string, limit int, reset bool) (err error) {
+	order, ok := topQuerySorts[sortBy]
+	if !ok {
+		return usageError("invalid --sort '%s' (valid: total, mean, calls, rows, hits)", sortBy)
+	}
+	if limit < 1 {
+		return usageError("--limit must be at least 1")
+	}
+
+	ctx := context.Background()
+	conn, err := connectPostgres(ctx, dbName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var installed bool
+	var version int
+	err = conn.QueryRow(ctx, `SELECT
+			EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements'),
+			current_setting('server_version_num')::int`).Scan(&installed, &version)
+	if err != nil {
+		return wrapError(err, "failed to check for pg_stat_statements")
+	}
+	if !installed {
+		return statStatementsMissing(ctx, conn, dbName, false)
+	}
+	if reset && version < 120000 {
+		return usageError("--reset needs PostgreSQL 12 or later to reset a single database")
+	}
+
+	// The time columns were renamed in PostgreSQL 13
+	total, mean := "total_exec_time", "mean_exec_time"
+	if version < 130000 {
+		total, mean = "total_time", "mean_time"
+	}
+	query := fmt.Sprintf(`
+		SELECT
+			s.queryid AS query_id,
+			coalesce(r.rolname, '') AS user,
+			s.calls,
+			s.%[1]s AS total_ms,
+			s.%[2]s AS mean_ms,
+			s.rows,
+			s.shared_blks_hit,
+			s.shared_blks_read,
+			100.0 * s.shared_blks_hit / nullif(s.shared_blks_hit + s.shared_blks_read, 0) AS hit_percent,
+			coalesce(100.0 * s.%[1]s / nullif(sum(s.%[1]s) OVER (), 0), 0) AS time_percent,
+			s.query
+		FROM pg_stat_statements AS s
+		LEFT JOIN pg_roles AS r ON r.oid = s.userid
+		WHERE s.dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		ORDER BY %[3]s DESC
+		LIMIT $1`, total, mean, order)
+	queries, err := queryRecords[topQueryRecord](ctx, conn, query, limit)
+	if err != nil {
+		// 55000: the extension exists but its library is not preloaded
+		if pgErrorCode(err) == "55000" {
+			return statStatementsMissing(ctx, conn, dbName, true)
+		}
+		return wrapError(err, "failed to read pg_stat_statements")
+	}
+
+	if reset {
+		// Reset once the report is out, and only if it was
+		defer func() {
+			if err == nil {
+				err = resetStatStatements(ctx, conn, dbName)
+			}
+		}()
+	}
+	if ok, err := renderOutput(queries); ok {
+		return err
+	}
+
+	color.Green("Top %d queries in database '%s' by %s:", len(queries), dbName, sortBy)
+	var rows [][]string
+	for i, q := range queries {
+		hit := ""
+		if q.HitPercent != nil {
+			hit = fmt.Sprintf("%.1f%%", *q.HitPercent)
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1), strconv.FormatInt(q.Calls, 10), formatMillis(q.TotalMs), formatMillis(q.MeanMs),
+			strconv.FormatInt(q.Rows, 10), hit, fmt.Sprintf("%.1f%%", q.TimePercent), oneLine(q.Query, 70),
+		})
+	}
+	if err := writeTable(os.Stdout, []string{"#", "calls", "total", "mean", "rows", "hit", "% time", "query"}, rows); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/minio/minio-go/v7"
+)
+
+// ErrorKind classifies command failures so main can map them to exit codes.
+type ErrorKind int
+
+const (
+	KindGeneral ErrorKind = iota
+	KindUsage
+	KindConnection
+	KindAuth
+	KindNotFound
+	KindConflict
+	KindCancelled
+)
+
+// Exit codes returned by the tools binary, see CMD.md.
+const (
+	ExitOK         = 0
+	ExitGeneral    = 1
+	ExitUsage      = 2
+	ExitConnection = 3
+	ExitAuth       = 4
+	ExitNotFound   = 5
+	ExitConflict   = 6
+	ExitCancelled  = 7
+)
+
+// CommandError is an error tagged with the category it belongs to.
+type CommandError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func newError(kind ErrorKind, format string, args ...any) error {
+	return &CommandError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+func usageError(format string, args ...any) error {
+	return newError(KindUsage, format, args...)
+}
+
+func connectionError(format string, args ...any) error {
+	return newError(KindConnection, format, args...)
+}
+
+func notFoundError(format string, args ...any) error {
+	return newError(KindNotFound, format, args...)
+}
+
+func conflictError(format string, args ...any) error {
+	return newError(KindConflict, format, args...)
+}
+
+func cancelledError(format string, args ...any) error {
+	return newError(KindCancelled, format, args...)
+}
+
+// ExitCode maps an error returned from a command to the process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return ExitGeneral
+	}
+
+	switch cmdErr.Kind {
+	case KindUsage:
+		return ExitUsage
+	case KindConnection:
+		return ExitConnection
+	case KindAuth:
+		return ExitAuth
+	case KindNotFound:
+		return ExitNotFound
+	case KindConflict:
+		return ExitConflict
+	case KindCancelled:
+		return ExitCancelled
+	}
+	return ExitGeneral
+}
+
+// wrapError wraps err with a message, keeping the category of err or
+// inferring it from the underlying cause.
+func wrapError(err error, format string, args ...any) error {
+	var kind ErrorKind
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		kind = cmdErr.Kind
+	} else {
+		kind = classifyError(err)
+	}
+
+	args = append(args, err)
+	return &CommandError{Kind: kind, Err: fmt.Errorf(format+": %w", args...)}
+}
+
+func classifyError(err error) ErrorKind {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return KindNotFound
+	case errors.Is(err, fs.ErrExist):
+		return KindConflict
+	}
+
+	// PostgreSQL server errors and failed connection attempts
+	if code := pgErrorCode(err); code != "" {
+		return classifySQLState(code)
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return KindConnection
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || errors.As(err, &urlErr) {
+		return KindConnection
+	}
+
+	// MinIO / S3 error responses
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchBucket", "NoSuchKey", "NoSuchObject":
+		return KindNotFound
+	case "BucketAlreadyExists", "BucketAlreadyOwnedByYou", "BucketNotEmpty":
+		return KindConflict
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return KindAuth
+	}
+
+	// psql / pg_dump messages on stderr
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "password authentication failed"),
+		strings.Contains(message, "no password supplied"),
+		strings.Contains(message, "permission denied"):
+		return KindAuth
+	case strings.Contains(message, "could not connect"),
+		strings.Contains(message, "connection refused"),
+		strings.Contains(message, "could not translate host name"):
+		return KindConnection
+	case strings.Contains(message, "does not exist"):
+		return KindNotFound
+	case strings.Contains(message, "already exists"):
+		return KindConflict
+	}
+
+	return KindGeneral
+}
+
+// httpStatusError turns an unexpected RabbitMQ management API status into a
+// categorized error.
+func httpStatusError(status int, format string, args ...any) error {
+	kind := KindGeneral
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = KindAuth
+	case http.StatusNotFound:
+		kind = KindNotFound
+	case http.StatusConflict:
+		kind = KindConflict
+	}
+
+	args = append(args, status)
+	return &CommandError{Kind: kind, Err: fmt.Errorf(format+": HTTP %d", args...)}
+}
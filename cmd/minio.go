@@ -7,12 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var minioCmd = &cobra.Command{
@@ -24,8 +24,8 @@ var minioCmd = &cobra.Command{
 var minioListBucketsCmd = &cobra.Command{
 	Use:   "buckets",
 	Short: "List all buckets",
-	Run: func(cmd *cobra.Command, args []string) {
-		listBuckets()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listBuckets()
 	},
 }
 
@@ -33,9 +33,9 @@ var minioCreateBucketCmd = &cobra.Command{
 	Use:   "create-bucket [bucket-name]",
 	Short: "Create a new bucket",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		region, _ := cmd.Flags().GetString("region")
-		createBucket(args[0], region)
+		return createBucket(args[0], region)
 	},
 }
 
@@ -43,9 +43,9 @@ var minioDeleteBucketCmd = &cobra.Command{
 	Use:   "delete-bucket [bucket-name]",
 	Short: "Delete a bucket",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		force, _ := cmd.Flags().GetBool("force")
-		deleteBucket(args[0], force)
+		return deleteBucket(args[0], force)
 	},
 }
 
@@ -53,10 +53,10 @@ var minioListObjectsCmd = &cobra.Command{
 	Use:   "list [bucket-name]",
 	Short: "List objects in a bucket",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		prefix, _ := cmd.Flags().GetString("prefix")
 		recursive, _ := cmd.Flags().GetBool("recursive")
-		listObjects(args[0], prefix, recursive)
+		return listObjects(args[0], prefix, recursive)
 	},
 }
 
@@ -64,14 +64,14 @@ var minioUploadCmd = &cobra.Command{
 	Use:   "upload [bucket-name] [local-file] [object-name]",
 	Short: "Upload a file to a bucket",
 	Args:  cobra.RangeArgs(2, 3),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		objectName := ""
 		if len(args) == 3 {
 			objectName = args[2]
 		} else {
 			objectName = filepath.Base(args[1])
 		}
-		uploadFile(args[0], args[1], objectName)
+		return uploadFile(args[0], args[1], objectName)
 	},
 }
 
@@ -79,14 +79,14 @@ var minioDownloadCmd = &cobra.Command{
 	Use:   "download [bucket-name] [object-name] [local-file]",
 	Short: "Download an object from a bucket",
 	Args:  cobra.RangeArgs(2, 3),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		localFile := ""
 		if len(args) == 3 {
 			localFile = args[2]
 		} else {
 			localFile = filepath.Base(args[1])
 		}
-		downloadFile(args[0], args[1], localFile)
+		return downloadFile(args[0], args[1], localFile)
 	},
 }
 
@@ -94,8 +94,8 @@ var minioDeleteObjectCmd = &cobra.Command{
 	Use:   "delete [bucket-name] [object-name]",
 	Short: "Delete an object from a bucket",
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		deleteObject(args[0], args[1])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deleteObject(args[0], args[1])
 	},
 }
 
@@ -103,8 +103,8 @@ var minioCopyCmd = &cobra.Command{
 	Use:   "copy [source-bucket] [source-object] [dest-bucket] [dest-object]",
 	Short: "Copy an object between buckets",
 	Args:  cobra.ExactArgs(4),
-	Run: func(cmd *cobra.Command, args []string) {
-		copyObject(args[0], args[1], args[2], args[3])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return copyObject(args[0], args[1], args[2], args[3])
 	},
 }
 
@@ -112,12 +112,11 @@ var minioStatCmd = &cobra.Command{
 	Use:   "stat [bucket-name] [object-name]",
 	Short: "Get object information",
 	Args:  cobra.RangeArgs(1, 2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 1 {
-			statBucket(args[0])
-		} else {
-			statObject(args[0], args[1])
+			return statBucket(args[0])
 		}
+		return statObject(args[0], args[1])
 	},
 }
 
@@ -125,9 +124,9 @@ var minioMirrorCmd = &cobra.Command{
 	Use:   "mirror [local-dir] [bucket-name]",
 	Short: "Mirror local directory to bucket",
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		prefix, _ := cmd.Flags().GetString("prefix")
-		mirrorDirectory(args[0], args[1], prefix)
+		return mirrorDirectory(args[0], args[1], prefix)
 	},
 }
 
@@ -155,6 +154,39 @@ func init() {
 	minioCmd.AddCommand(minioMirrorCmd)
 }
 
+type bucketRecord struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+type objectRecord struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type bucketStatRecord struct {
+	Bucket    string `json:"bucket"`
+	Objects   int    `json:"objects"`
+	TotalSize int64  `json:"total_size"`
+}
+
+type objectStatRecord struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ETag         string    `json:"etag"`
+	ContentType  string    `json:"content_type"`
+}
+
+type mirrorRecord struct {
+	Source string `json:"source"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+}
+
 func getMinIOClient() (*minio.Client, error) {
 	endpoint, _ := minioCmd.Flags().GetString("endpoint")
 	accessKey, _ := minioCmd.Flags().GetString("access-key")
@@ -163,16 +195,16 @@ func getMinIOClient() (*minio.Client, error) {
 
 	// Use environment variables if flags not provided
 	if endpoint == "" {
-		endpoint = viper.GetString("minio.endpoint")
+		endpoint = configString("minio.endpoint")
 	}
 	if accessKey == "" {
-		accessKey = viper.GetString("minio.access_key")
+		accessKey = configString("minio.access_key")
 	}
 	if secretKey == "" {
-		secretKey = viper.GetString("minio.secret_key")
+		secretKey = configString("minio.secret_key")
 	}
 	if !useSSL {
-		useSSL = viper.GetBool("minio.use_ssl")
+		useSSL = configBool("minio.use_ssl")
 	}
 
 	// Initialize minio client
@@ -187,31 +219,37 @@ func getMinIOClient() (*minio.Client, error) {
 	return minioClient, nil
 }
 
-func listBuckets() {
+func listBuckets() error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
 	buckets, err := client.ListBuckets(ctx)
 	if err != nil {
-		color.Red("Error listing buckets: %v", err)
-		return
+		return wrapError(err, "failed to list buckets")
 	}
 
-	color.Green("Buckets:")
+	records := make([]bucketRecord, 0, len(buckets))
 	for _, bucket := range buckets {
-		fmt.Printf("  - %s (created: %s)\n", bucket.Name, bucket.CreationDate.Format("2006-01-02 15:04:05"))
+		records = append(records, bucketRecord{Name: bucket.Name, Created: bucket.CreationDate})
 	}
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+
+	color.Green("Buckets:")
+	for _, bucket := range records {
+		fmt.Printf("  - %s (created: %s)\n", bucket.Name, bucket.Created.Format("2006-01-02 15:04:05"))
+	}
+	return nil
 }
 
-func createBucket(bucketName, region string) {
+func createBucket(bucketName, region string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -220,21 +258,18 @@ func createBucket(bucketName, region string) {
 		// Check if bucket already exists
 		exists, errBucketExists := client.BucketExists(ctx, bucketName)
 		if errBucketExists == nil && exists {
-			color.Yellow("Bucket '%s' already exists", bucketName)
-		} else {
-			color.Red("Error creating bucket: %v", err)
+			return conflictError("bucket '%s' already exists", bucketName)
 		}
-		return
+		return wrapError(err, "failed to create bucket")
 	}
 
-	color.Green("Bucket '%s' created successfully", bucketName)
+	return reportAction("create-bucket", bucketName, "created", "Bucket '%s' created successfully", bucketName)
 }
 
-func deleteBucket(bucketName string, force bool) {
+func deleteBucket(bucketName string, force bool) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -247,32 +282,28 @@ func deleteBucket(bucketName string, force bool) {
 
 		for object := range objectsCh {
 			if object.Err != nil {
-				color.Red("Error listing objects: %v", object.Err)
-				return
+				return wrapError(object.Err, "failed to list objects")
 			}
 
 			err := client.RemoveObject(ctx, bucketName, object.Key, minio.RemoveObjectOptions{})
 			if err != nil {
-				color.Red("Error removing object '%s': %v", object.Key, err)
-				return
+				return wrapError(err, "failed to remove object '%s'", object.Key)
 			}
 		}
 	}
 
 	err = client.RemoveBucket(ctx, bucketName)
 	if err != nil {
-		color.Red("Error deleting bucket: %v", err)
-		return
+		return wrapError(err, "failed to delete bucket")
 	}
 
-	color.Green("Bucket '%s' deleted successfully", bucketName)
+	return reportAction("delete-bucket", bucketName, "deleted", "Bucket '%s' deleted successfully", bucketName)
 }
 
-func listObjects(bucketName, prefix string, recursive bool) {
+func listObjects(bucketName, prefix string, recursive bool) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -282,27 +313,32 @@ func listObjects(bucketName, prefix string, recursive bool) {
 		Recursive: recursive,
 	})
 
-	color.Green("Objects in bucket '%s':", bucketName)
-	count := 0
-	totalSize := int64(0)
+	records := []objectRecord{}
 	for object := range objectsCh {
 		if object.Err != nil {
-			color.Red("Error: %v", object.Err)
-			return
+			return wrapError(object.Err, "failed to list objects")
 		}
+		records = append(records, objectRecord{Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+	}
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+
+	color.Green("Objects in bucket '%s':", bucketName)
+	totalSize := int64(0)
+	for _, object := range records {
 		fmt.Printf("  - %s (size: %d bytes, modified: %s)\n",
 			object.Key, object.Size, object.LastModified.Format("2006-01-02 15:04:05"))
-		count++
 		totalSize += object.Size
 	}
-	fmt.Printf("\nTotal: %d objects, %d bytes\n", count, totalSize)
+	fmt.Printf("\nTotal: %d objects, %d bytes\n", len(records), totalSize)
+	return nil
 }
 
-func uploadFile(bucketName, localFile, objectName string) {
+func uploadFile(bucketName, localFile, objectName string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -310,32 +346,28 @@ func uploadFile(bucketName, localFile, objectName string) {
 	// Check if file exists
 	file, err := os.Open(localFile)
 	if err != nil {
-		color.Red("Error opening file: %v", err)
-		return
+		return wrapError(err, "failed to open file")
 	}
 	defer file.Close()
 
 	fileStat, err := file.Stat()
 	if err != nil {
-		color.Red("Error getting file info: %v", err)
-		return
+		return wrapError(err, "failed to get file info")
 	}
 
 	// Upload the file
 	_, err = client.PutObject(ctx, bucketName, objectName, file, fileStat.Size(), minio.PutObjectOptions{})
 	if err != nil {
-		color.Red("Error uploading file: %v", err)
-		return
+		return wrapError(err, "failed to upload file")
 	}
 
-	color.Green("File '%s' uploaded successfully as '%s'", localFile, objectName)
+	return reportAction("upload", bucketName+"/"+objectName, "uploaded", "File '%s' uploaded successfully as '%s'", localFile, objectName)
 }
 
-func downloadFile(bucketName, objectName, localFile string) {
+func downloadFile(bucketName, objectName, localFile string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -343,52 +375,46 @@ func downloadFile(bucketName, objectName, localFile string) {
 	// Get object from bucket
 	object, err := client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
 	if err != nil {
-		color.Red("Error getting object: %v", err)
-		return
+		return wrapError(err, "failed to get object")
 	}
 	defer object.Close()
 
 	// Create local file
 	file, err := os.Create(localFile)
 	if err != nil {
-		color.Red("Error creating local file: %v", err)
-		return
+		return wrapError(err, "failed to create local file")
 	}
 	defer file.Close()
 
 	// Copy object to file
 	_, err = io.Copy(file, object)
 	if err != nil {
-		color.Red("Error downloading file: %v", err)
-		return
+		return wrapError(err, "failed to download file")
 	}
 
-	color.Green("Object '%s' downloaded successfully as '%s'", objectName, localFile)
+	return reportAction("download", bucketName+"/"+objectName, "downloaded", "Object '%s' downloaded successfully as '%s'", objectName, localFile)
 }
 
-func deleteObject(bucketName, objectName string) {
+func deleteObject(bucketName, objectName string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
 
 	err = client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
 	if err != nil {
-		color.Red("Error deleting object: %v", err)
-		return
+		return wrapError(err, "failed to delete object")
 	}
 
-	color.Green("Object '%s' deleted successfully from bucket '%s'", objectName, bucketName)
+	return reportAction("delete", bucketName+"/"+objectName, "deleted", "Object '%s' deleted successfully from bucket '%s'", objectName, bucketName)
 }
 
-func copyObject(sourceBucket, sourceObject, destBucket, destObject string) {
+func copyObject(sourceBucket, sourceObject, destBucket, destObject string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
@@ -406,32 +432,28 @@ func copyObject(sourceBucket, sourceObject, destBucket, destObject string) {
 
 	_, err = client.CopyObject(ctx, dstOpts, srcOpts)
 	if err != nil {
-		color.Red("Error copying object: %v", err)
-		return
+		return wrapError(err, "failed to copy object")
 	}
 
-	color.Green("Object copied successfully from '%s/%s' to '%s/%s'",
+	return reportAction("copy", destBucket+"/"+destObject, "copied", "Object copied successfully from '%s/%s' to '%s/%s'",
 		sourceBucket, sourceObject, destBucket, destObject)
 }
 
-func statBucket(bucketName string) {
+func statBucket(bucketName string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
 
 	exists, err := client.BucketExists(ctx, bucketName)
 	if err != nil {
-		color.Red("Error checking bucket: %v", err)
-		return
+		return wrapError(err, "failed to check bucket")
 	}
 
 	if !exists {
-		color.Yellow("Bucket '%s' does not exist", bucketName)
-		return
+		return notFoundError("bucket '%s' does not exist", bucketName)
 	}
 
 	// Count objects and total size
@@ -443,31 +465,45 @@ func statBucket(bucketName string) {
 	totalSize := int64(0)
 	for object := range objectsCh {
 		if object.Err != nil {
-			color.Red("Error: %v", object.Err)
-			return
+			return wrapError(object.Err, "failed to list objects")
 		}
 		count++
 		totalSize += object.Size
 	}
 
+	if ok, err := renderOutput(bucketStatRecord{Bucket: bucketName, Objects: count, TotalSize: totalSize}); ok {
+		return err
+	}
+
 	color.Green("Bucket '%s' information:", bucketName)
 	fmt.Printf("  - Objects: %d\n", count)
 	fmt.Printf("  - Total size: %d bytes\n", totalSize)
+	return nil
 }
 
-func statObject(bucketName, objectName string) {
+func statObject(bucketName, objectName string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
 
 	info, err := client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
 	if err != nil {
-		color.Red("Error getting object info: %v", err)
-		return
+		return wrapError(err, "failed to get object info")
+	}
+
+	record := objectStatRecord{
+		Bucket:       bucketName,
+		Key:          objectName,
+		Size:         info.Size,
+		LastModified: info.LastModified,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+	}
+	if ok, err := renderOutput(record); ok {
+		return err
 	}
 
 	color.Green("Object '%s' information:", objectName)
@@ -476,19 +512,20 @@ func statObject(bucketName, objectName string) {
 	fmt.Printf("  - Last modified: %s\n", info.LastModified.Format("2006-01-02 15:04:05"))
 	fmt.Printf("  - ETag: %s\n", info.ETag)
 	fmt.Printf("  - Content-Type: %s\n", info.ContentType)
+	return nil
 }
 
-func mirrorDirectory(localDir, bucketName, prefix string) {
+func mirrorDirectory(localDir, bucketName, prefix string) error {
 	client, err := getMinIOClient()
 	if err != nil {
-		color.Red("Error connecting to MinIO: %v", err)
-		return
+		return connectionError("failed to connect to MinIO: %w", err)
 	}
 
 	ctx := context.Background()
 
 	// Walk through local directory
-	uploadCount := 0
+	uploaded := []mirrorRecord{}
+	failed := 0
 	err = filepath.Walk(localDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -520,21 +557,32 @@ func mirrorDirectory(localDir, bucketName, prefix string) {
 
 		_, err = client.PutObject(ctx, bucketName, objectName, file, info.Size(), minio.PutObjectOptions{})
 		if err != nil {
-			color.Red("Error uploading '%s': %v", path, err)
+			printError("Error uploading '%s': %v", path, err)
+			failed++
 			return nil
 		}
 
-		fmt.Printf("Uploaded: %s -> %s/%s\n", path, bucketName, objectName)
-		uploadCount++
+		if outputFormat() == outputText {
+			fmt.Printf("Uploaded: %s -> %s/%s\n", path, bucketName, objectName)
+		}
+		uploaded = append(uploaded, mirrorRecord{Source: path, Bucket: bucketName, Key: objectName, Size: info.Size()})
 		return nil
 	})
 
 	if err != nil {
-		color.Red("Error walking directory: %v", err)
-		return
+		return wrapError(err, "failed to walk directory")
+	}
+
+	if ok, err := renderOutput(uploaded); !ok {
+		color.Green("Mirror completed: %d files uploaded", len(uploaded))
+	} else if err != nil {
+		return err
 	}
 
-	color.Green("Mirror completed: %d files uploaded", uploadCount)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to upload", failed, failed+len(uploaded))
+	}
+	return nil
 }
 
 func GetMinIOCommand() *cobra.Command {
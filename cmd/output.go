@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
+	outputCSV   = "csv"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputYAML, outputTable, outputCSV}
+
+// tabular is implemented by records whose columns are only known at runtime,
+// such as the result of an arbitrary SQL query.
+type tabular interface {
+	Headers() []string
+	Rows() [][]string
+}
+
+// tableViewer is implemented by detail records that carry more than one list.
+// JSON and YAML output get the whole record, table and CSV output get the
+// value returned by TableView.
+type tableViewer interface {
+	TableView() any
+}
+
+// resultSet holds the typed rows of an ad-hoc query. It renders as a list of
+// objects in JSON/YAML, keeping the column order of the query.
+type resultSet struct {
+	Columns []string
+	Values  [][]any
+}
+
+func (r *resultSet) Headers() []string { return r.Columns }
+
+func (r *resultSet) Rows() [][]string {
+	rows := make([][]string, len(r.Values))
+	for i, values := range r.Values {
+		rows[i] = make([]string, len(values))
+		for j, value := range values {
+			rows[i][j] = formatCell(reflect.ValueOf(value))
+		}
+	}
+	return rows
+}
+
+func (r *resultSet) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range r.Values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, column := range r.Columns {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(column)
+			value, err := json.Marshal(row[j])
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// actionResult is emitted by commands that change something instead of
+// listing it, so scripts can still consume a structured response.
+type actionResult struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+}
+
+func outputFormat() string {
+	format := strings.ToLower(viper.GetString("output"))
+	if format == "" {
+		return outputText
+	}
+	return format
+}
+
+func validateOutputFormat() error {
+	format := outputFormat()
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s' (valid: %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// renderOutput writes v in the format selected with --output and reports
+// whether it did. Commands fall back to their colored text output when it
+// returns false.
+func renderOutput(v any) (bool, error) {
+	format := outputFormat()
+	if format == outputText {
+		return false, nil
+	}
+
+	if err := writeOutput(os.Stdout, format, v); err != nil {
+		return true, fmt.Errorf("failed to render output: %w", err)
+	}
+	return true, nil
+}
+
+// reportAction prints a colored success message in text mode and an
+// actionResult record otherwise.
+func reportAction(action, target, status, format string, args ...any) error {
+	if ok, err := renderOutput(actionResult{Action: action, Target: target, Status: status}); ok {
+		return err
+	}
+	color.Green(format, args...)
+	return nil
+}
+
+// printProgress reports progress in yellow on stdout in text mode and as
+// plain text on stderr otherwise, so structured output stays parseable.
+func printProgress(format string, args ...any) {
+	if outputFormat() == outputText {
+		color.Yellow(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// printError reports a non-fatal error in red on stderr.
+func printError(format string, args ...any) {
+	color.New(color.FgRed).Fprintf(os.Stderr, format+"\n", args...)
+}
+
+func writeOutput(w io.Writer, format string, v any) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		return writeYAML(w, v)
+	case outputTable, outputCSV:
+		if viewer, ok := v.(tableViewer); ok {
+			v = viewer.TableView()
+		}
+		headers, rows := tabulate(v)
+		if format == outputCSV {
+			writer := csv.NewWriter(w)
+			writer.Write(headers)
+			writer.WriteAll(rows)
+			return writer.Error()
+		}
+		return writeTable(w, headers, rows)
+	}
+	return fmt.Errorf("unknown output format '%s'", format)
+}
+
+// writeYAML goes through JSON so YAML keys follow the json struct tags and
+// keep their declaration order.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(&node)
+}
+
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+func writeTable(w io.Writer, headers []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	upper := make([]string, len(headers))
+	for i, h := range headers {
+		upper[i] = strings.ToUpper(h)
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tabulate flattens a struct, a slice of structs or a tabular value into
+// headers and string rows. Headers are taken from the json struct tags.
+func tabulate(v any) ([]string, [][]string) {
+	if t, ok := v.(tabular); ok {
+		return t.Headers(), t.Rows()
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		headers, row := structRow(value)
+		return headers, [][]string{row}
+	}
+
+	var headers []string
+	var rows [][]string
+	if value.Len() == 0 {
+		headers, _ = structRow(reflect.New(value.Type().Elem()).Elem())
+		return headers, rows
+	}
+	for i := 0; i < value.Len(); i++ {
+		var row []string
+		headers, row = structRow(reflect.Indirect(value.Index(i)))
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
+
+func structRow(value reflect.Value) ([]string, []string) {
+	if value.Kind() != reflect.Struct {
+		return []string{"value"}, []string{formatCell(value)}
+	}
+
+	var headers, row []string
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		headers = append(headers, name)
+		row = append(row, formatCell(value.Field(i)))
+	}
+	return headers, row
+}
+
+func formatCell(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+	if (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+		return ""
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		data, _ := json.Marshal(value.Interface())
+		return string(data)
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,443 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maintenanceDB is the database server-level commands (list, create, drop,
+// size) connect to, like createdb and dropdb do.
+const maintenanceDB = "postgres"
+
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// postgresDSN builds a keyword/value connection string for dbName from the
+// configured connection settings. Empty settings are left out so libpq
+// defaults (PGPASSWORD, ~/.pgpass, ...) still apply.
+func postgresDSN(dbName string) string {
+	host, port, user, password, _ := getPostgresConfig()
+	return buildDSN(host, port, user, password, dbName)
+}
+
+// postgresSettings returns the connection settings of the named profile, or
+// those of the active profile and connection flags when profile is empty or
+// the active one. The connection flags only apply to the active profile.
+func postgresSettings(profile string) (host, port, user, password string) {
+	if profile == "" || profile == activeProfile() {
+		host, port, user, password, _ = getPostgresConfig()
+		return
+	}
+	return profileConfigString(profile, "postgres.host"),
+		profileConfigString(profile, "postgres.port"),
+		profileConfigString(profile, "postgres.user"),
+		profileConfigString(profile, "postgres.password")
+}
+
+func buildDSN(host, port, user, password, dbName string) string {
+	settings := []struct{ key, value string }{
+		{"host", host},
+		{"port", port},
+		{"user", user},
+		{"password", password},
+		{"dbname", dbName},
+	}
+
+	var parts []string
+	for _, setting := range settings {
+		if setting.value == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s='%s'", setting.key, dsnEscaper.Replace(setting.value)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// connectPostgres opens the single connection a command uses for its whole
+// invocation. Callers must close it.
+func connectPostgres(ctx context.Context, dbName string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, postgresDSN(dbName))
+	if err != nil {
+		return nil, wrapError(err, "failed to connect to PostgreSQL")
+	}
+	return conn, nil
+}
+
+// connectPostgresProfile connects to dbName on the server of the named
+// profile instead of the active one, for commands that compare or copy
+// between servers.
+func connectPostgresProfile(ctx context.Context, profile, dbName string) (*pgx.Conn, error) {
+	if profile == "" || profile == activeProfile() {
+		return connectPostgres(ctx, dbName)
+	}
+	if !profileExists(profile) {
+		return nil, notFoundError("profile '%s' not found in config file", profile)
+	}
+
+	host, port, user, password := postgresSettings(profile)
+	conn, err := pgx.Connect(ctx, buildDSN(host, port, user, password, dbName))
+	if err != nil {
+		return nil, wrapError(err, "failed to connect to PostgreSQL of profile '%s'", profile)
+	}
+	return conn, nil
+}
+
+// queryRecords runs query and scans every row into a T, matching result
+// columns to the db struct tags.
+func queryRecords[T any](ctx context.Context, conn *pgx.Conn, query string, args ...any) ([]T, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[T])
+}
+
+// queryResultSet runs a single query and keeps the typed values of every row.
+func queryResultSet(ctx context.Context, conn *pgx.Conn, query string, args ...any) (*resultSet, error) {
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := newResultSet(rows.FieldDescriptions())
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		fields := rows.FieldDescriptions()
+		for i := range values {
+			values[i] = normalizeValue(fields[i].DataTypeOID, values[i])
+		}
+		result.Values = append(result.Values, values)
+	}
+	return result, rows.Err()
+}
+
+// statementResult is the outcome of one statement of an ad-hoc script.
+type statementResult struct {
+	Tag          string
+	RowsAffected int64
+	Rows         *resultSet
+}
+
+// execStatements runs sql, which may hold several statements, over the simple
+// query protocol and decodes the rows of every statement that returned any.
+// Like psql -c, the statements run in a single implicit transaction.
+func execStatements(ctx context.Context, conn *pgx.Conn, sql string) ([]statementResult, error) {
+	results, err := conn.PgConn().Exec(ctx, sql).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	typeMap := conn.TypeMap()
+	statements := make([]statementResult, 0, len(results))
+	for _, result := range results {
+		statement := statementResult{Tag: result.CommandTag.String(), RowsAffected: result.CommandTag.RowsAffected()}
+		if len(result.FieldDescriptions) > 0 {
+			statement.Rows = newResultSet(result.FieldDescriptions)
+			for _, raw := range result.Rows {
+				values := make([]any, len(raw))
+				for i, src := range raw {
+					value, err := decodeValue(typeMap, result.FieldDescriptions[i], src)
+					if err != nil {
+						return nil, err
+					}
+					values[i] = value
+				}
+				statement.Rows.Values = append(statement.Rows.Values, values)
+			}
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+func newResultSet(fields []pgconn.FieldDescription) *resultSet {
+	result := &resultSet{Columns: make([]string, len(fields)), Values: [][]any{}}
+	for i, field := range fields {
+		result.Columns[i] = field.Name
+	}
+	return result
+}
+
+// decodeValue turns a raw column value into its Go type. Types the driver
+// does not know, such as enums and domains of extensions, stay text.
+func decodeValue(typeMap *pgtype.Map, field pgconn.FieldDescription, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	dataType, ok := typeMap.TypeForOID(field.DataTypeOID)
+	if !ok {
+		return string(src), nil
+	}
+	value, err := dataType.Codec.DecodeValue(typeMap, field.DataTypeOID, field.Format, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode column '%s': %w", field.Name, err)
+	}
+	return normalizeValue(field.DataTypeOID, value), nil
+}
+
+// normalizeValue converts driver values without a useful JSON or text form,
+// such as UUIDs, bytea and numerics, into plain values.
+func normalizeValue(oid uint32, value any) any {
+	switch v := value.(type) {
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case []byte:
+		if oid == pgtype.JSONOID || oid == pgtype.JSONBOID {
+			return json.RawMessage(v)
+		}
+		return `\x` + hex.EncodeToString(v)
+	case time.Time:
+		if oid == pgtype.DateOID {
+			return v.Format("2006-01-02")
+		}
+		return v
+	case pgtype.Numeric:
+		text, err := v.Value()
+		if err != nil || text == nil {
+			return nil
+		}
+		if v.NaN || v.InfinityModifier != pgtype.Finite {
+			return text
+		}
+		return json.Number(text.(string))
+	case []any:
+		for i := range v {
+			v[i] = normalizeValue(0, v[i])
+		}
+		return v
+	case interface{ Value() (any, error) }:
+		// Remaining pgtype values (intervals, ranges, ...) render as their
+		// PostgreSQL text form.
+		if text, err := v.Value(); err == nil {
+			return text
+		}
+	}
+	return value
+}
+
+// parseQualifiedName splits a table name that may be schema-qualified the way
+// PostgreSQL reads it: unquoted parts are folded to lower case, double-quoted
+// parts keep their case and may contain dots and doubled quotes. Names
+// without a schema default to public.
+func parseQualifiedName(name string) (schema, table string, err error) {
+	var parts []string
+	for i := 0; ; i++ {
+		var part string
+		part, i, err = parseIdentifier(name, i)
+		if err != nil {
+			return "", "", err
+		}
+		parts = append(parts, part)
+
+		if i == len(name) {
+			break
+		}
+		if name[i] != '.' {
+			return "", "", fmt.Errorf("unexpected %q after quoted identifier", name[i])
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		return "public", parts[0], nil
+	case 2:
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("expected [schema.]table, got %d name parts", len(parts))
+}
+
+// parseIdentifier reads one identifier of a qualified name starting at i and
+// returns it with the position just after it.
+func parseIdentifier(name string, i int) (string, int, error) {
+	if i < len(name) && name[i] == '"' {
+		var ident strings.Builder
+		for i++; i < len(name); i++ {
+			if name[i] != '"' {
+				ident.WriteByte(name[i])
+				continue
+			}
+			if i+1 < len(name) && name[i+1] == '"' {
+				ident.WriteByte('"')
+				i++
+				continue
+			}
+			if ident.Len() == 0 {
+				return "", 0, fmt.Errorf("zero-length quoted identifier")
+			}
+			return ident.String(), i + 1, nil
+		}
+		return "", 0, fmt.Errorf("unterminated quoted identifier")
+	}
+
+	start := i
+	for i < len(name) && name[i] != '.' {
+		if name[i] == '"' {
+			return "", 0, fmt.Errorf("unexpected quote inside identifier")
+		}
+		i++
+	}
+	ident := strings.TrimSpace(name[start:i])
+	if ident == "" {
+		return "", 0, fmt.Errorf("empty identifier")
+	}
+	return strings.ToLower(ident), i, nil
+}
+
+// splitStatements splits a SQL script into its statements at top-level
+// semicolons, skipping those inside quotes, dollar quotes and comments.
+// Statements holding nothing but comments are dropped.
+func splitStatements(script string) []string {
+	statements, rest := scanStatements(script)
+	if rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// scanStatements is splitStatements for input that may be incomplete: rest
+// is the trailing statement not yet ended by a semicolon, or "" if there is
+// none.
+func scanStatements(script string) (statements []string, rest string) {
+	start, hasCode := 0, false
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start, hasCode = end+1, false
+	}
+
+	for i := 0; i < len(script); i++ {
+		if end, code, ok := skipQuoted(script, i); ok {
+			hasCode = hasCode || code
+			i = end
+			continue
+		}
+		switch c := script[i]; {
+		case c == ';':
+			flush(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	if hasCode && start < len(script) {
+		rest = strings.TrimSpace(script[start:])
+	}
+	return statements, rest
+}
+
+// skipQuoted reports whether a comment, quoted literal or identifier, or
+// dollar-quoted string starts at script[i], returning the index of its last
+// byte and whether it is code rather than a comment. Unterminated ones run
+// to the end of script.
+func skipQuoted(script string, i int) (end int, code, ok bool) {
+	last := len(script) - 1
+	switch c := script[i]; {
+	case c == '-' && strings.HasPrefix(script[i:], "--"):
+		if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+			return i + end, false, true
+		}
+		return last, false, true
+	case c == '/' && strings.HasPrefix(script[i:], "/*"):
+		// Block comments nest in PostgreSQL
+		depth := 0
+		for ; i < len(script); i++ {
+			if strings.HasPrefix(script[i:], "/*") {
+				depth++
+				i++
+			} else if strings.HasPrefix(script[i:], "*/") {
+				depth--
+				i++
+				if depth == 0 {
+					return i, false, true
+				}
+			}
+		}
+		return last, false, true
+	case c == '\'' || c == '"':
+		escapes := c == '\'' && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') &&
+			(i == 1 || !isIdentifierByte(script[i-2]))
+		for i++; i < len(script); i++ {
+			if escapes && script[i] == '\\' {
+				i++
+			} else if script[i] == c {
+				// A doubled quote stays inside the literal
+				if i+1 < len(script) && script[i+1] == c {
+					i++
+				} else {
+					return i, true, true
+				}
+			}
+		}
+		return last, true, true
+	case c == '$' && (i == 0 || !isIdentifierByte(script[i-1])):
+		tag, ok := dollarQuoteTag(script[i:])
+		if !ok {
+			return 0, false, false
+		}
+		if end := strings.Index(script[i+len(tag):], tag); end >= 0 {
+			return i + len(tag) + end + len(tag) - 1, true, true
+		}
+		return last, true, true
+	}
+	return 0, false, false
+}
+
+// dollarQuoteTag returns the opening $tag$ at the start of s, if any.
+func dollarQuoteTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '$':
+			return s[:j+1], true
+		case s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= 0x80,
+			s[j] >= '0' && s[j] <= '9' && j > 1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// classifySQLState maps a PostgreSQL SQLSTATE code to an error category.
+func classifySQLState(code string) ErrorKind {
+	switch code {
+	case "28000", "28P01", "42501": // invalid authorization, bad password, insufficient privilege
+		return KindAuth
+	case "3D000", "3F000", "42P01", "42704": // unknown database, schema, table, object
+		return KindNotFound
+	case "42P04", "42P06", "42P07", "42710", "23505", "55006": // duplicates, unique violation, object in use
+		return KindConflict
+	case "57014": // query cancelled
+		return KindCancelled
+	case "57P03": // server starting up or shutting down
+		return KindConnection
+	}
+	if strings.HasPrefix(code, "08") {
+		return KindConnection
+	}
+	return KindGeneral
+}
+
+// pgErrorCode returns the SQLSTATE of err, if it came from the server.
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
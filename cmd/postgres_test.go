@@ -0,0 +1,81 @@
+package cmd
+
+import "testing"
+
+func TestParseQualifiedName(t *testing.T) {
+	tests := []struct {
+		name          string
+		schema, table string
+		wantErr       bool
+	}{
+		{name: "users", schema: "public", table: "users"},
+		{name: "Users", schema: "public", table: "users"},
+		{name: "sales.Orders", schema: "sales", table: "orders"},
+		{name: " sales . orders ", schema: "sales", table: "orders"},
+		{name: `"Reporting"."Q1.2024"`, schema: "Reporting", table: "Q1.2024"},
+		{name: `app."Order ""Items"""`, schema: "app", table: `Order "Items"`},
+		{name: `"CamelCase"`, schema: "public", table: "CamelCase"},
+		{name: "", wantErr: true},
+		{name: "a.b.c", wantErr: true},
+		{name: "sales.", wantErr: true},
+		{name: `""`, wantErr: true},
+		{name: `"open`, wantErr: true},
+		{name: `"a"b`, wantErr: true},
+		{name: `ab"c`, wantErr: true},
+	}
+	for _, tt := range tests {
+		schema, table, err := parseQualifiedName(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseQualifiedName(%q) = %q, %q, want an error", tt.name, schema, table)
+			}
+			continue
+		}
+		if err != nil || schema != tt.schema || table != tt.table {
+			t.Errorf("parseQualifiedName(%q) = %q, %q, %v, want %q, %q", tt.name, schema, table, err, tt.schema, tt.table)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"only whitespace and comments", "  -- nothing\n/* here */\n;\n", nil},
+		{"two statements", "SELECT 1;\nSELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"missing final semicolon", "SELECT 1;\n  SELECT 2\n", []string{"SELECT 1", "SELECT 2"}},
+		{"semicolon in string", "SELECT 'a;b';", []string{"SELECT 'a;b'"}},
+		{"doubled quote in string", "SELECT 'it''s;'; SELECT 2;", []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{"semicolon in identifier", `SELECT 1 AS "x;y";`, []string{`SELECT 1 AS "x;y"`}},
+		{"line comment", "SELECT 1; -- a; b\nSELECT 2;", []string{"SELECT 1", "-- a; b\nSELECT 2"}},
+		{"nested block comment", "SELECT /* a /* b; */ c; */ 1;", []string{"SELECT /* a /* b; */ c; */ 1"}},
+		{
+			"dollar quoted body",
+			"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\nSELECT f();",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT f()"},
+		},
+		{
+			"tagged dollar quote",
+			"DO $body$ BEGIN RAISE NOTICE '$$;'; END $body$;",
+			[]string{"DO $body$ BEGIN RAISE NOTICE '$$;'; END $body$"},
+		},
+		{"positional parameter is not a dollar quote", "SELECT $1; SELECT 2;", []string{"SELECT $1", "SELECT 2"}},
+		{"unterminated string runs to the end", "SELECT 'a; SELECT 2;", []string{"SELECT 'a; SELECT 2;"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("statement %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
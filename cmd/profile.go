@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// profileStore holds the contents of the tools config file. It is kept
+// separate from the global viper instance so that writing the file back
+// (e.g. from `config use`) never persists values that came from .env or
+// the environment.
+var profileStore = viper.New()
+
+func loadProfiles() {
+	if configFile := os.Getenv("TOOLS_CONFIG"); configFile != "" {
+		profileStore.SetConfigFile(configFile)
+	} else {
+		profileStore.SetConfigName("tools")
+		profileStore.AddConfigPath(".")
+		if execPath, err := os.Executable(); err == nil {
+			profileStore.AddConfigPath(filepath.Dir(execPath))
+		}
+		if configDir, err := os.UserConfigDir(); err == nil {
+			profileStore.AddConfigPath(filepath.Join(configDir, "tools"))
+		}
+	}
+
+	// A missing config file is fine, profiles are optional
+	profileStore.ReadInConfig()
+}
+
+func profileNames() []string {
+	var names []string
+	for name := range profileStore.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func profileExists(name string) bool {
+	return profileStore.IsSet("profiles." + name)
+}
+
+// activeProfile returns the profile selected with --profile / TOOLS_PROFILE,
+// falling back to the one saved with `tools config use`.
+func activeProfile() string {
+	if name := viper.GetString("profile"); name != "" {
+		return name
+	}
+	return profileStore.GetString("current_profile")
+}
+
+func profileKey(key string) (string, bool) {
+	name := activeProfile()
+	if name == "" {
+		return "", false
+	}
+	k := fmt.Sprintf("profiles.%s.%s", name, key)
+	return k, profileStore.IsSet(k)
+}
+
+// configString resolves a setting such as "postgres.host" through the active
+// profile first, then the environment and defaults.
+func configString(key string) string {
+	if k, ok := profileKey(key); ok {
+		return profileStore.GetString(k)
+	}
+	return viper.GetString(key)
+}
+
+// profileConfigString resolves a setting through the named profile, which
+// need not be the active one, then the environment and defaults.
+func profileConfigString(profile, key string) string {
+	k := fmt.Sprintf("profiles.%s.%s", profile, key)
+	if profileStore.IsSet(k) {
+		return profileStore.GetString(k)
+	}
+	return viper.GetString(key)
+}
+
+func configBool(key string) bool {
+	if k, ok := profileKey(key); ok {
+		return profileStore.GetBool(k)
+	}
+	return viper.GetBool(key)
+}
+
+// settingString resolves a setting that only lives in the config file, such
+// as the backup location: the active profile first, then the top level.
+func settingString(key string) string {
+	if k, ok := profileKey(key); ok {
+		return profileStore.GetString(k)
+	}
+	return profileStore.GetString(key)
+}
+
+func settingInt(key string) int {
+	if k, ok := profileKey(key); ok {
+		return profileStore.GetInt(k)
+	}
+	return profileStore.GetInt(key)
+}
+
+func settingStringSlice(key string) []string {
+	if k, ok := profileKey(key); ok {
+		return profileStore.GetStringSlice(k)
+	}
+	return profileStore.GetStringSlice(key)
+}
+
+// settingUnmarshal decodes a structured setting, such as the backup
+// schedules, from the active profile or the top level of the config file.
+func settingUnmarshal(key string, out any) error {
+	if k, ok := profileKey(key); ok {
+		return profileStore.UnmarshalKey(k, out)
+	}
+	return profileStore.UnmarshalKey(key, out)
+}
+
+func useProfile(name string) error {
+	if !profileExists(name) {
+		return fmt.Errorf("profile '%s' not found in config file", name)
+	}
+
+	profileStore.Set("current_profile", name)
+	return profileStore.WriteConfig()
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var rabbitCmd = &cobra.Command{
@@ -21,8 +20,8 @@ var rabbitCmd = &cobra.Command{
 var rabbitListQueuesCmd = &cobra.Command{
 	Use:   "queues",
 	Short: "List all queues",
-	Run: func(cmd *cobra.Command, args []string) {
-		listQueues()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listQueues()
 	},
 }
 
@@ -30,10 +29,10 @@ var rabbitCreateQueueCmd = &cobra.Command{
 	Use:   "create-queue [queue-name]",
 	Short: "Create a new queue",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		durable, _ := cmd.Flags().GetBool("durable")
 		autoDelete, _ := cmd.Flags().GetBool("auto-delete")
-		createQueue(args[0], durable, autoDelete)
+		return createQueue(args[0], durable, autoDelete)
 	},
 }
 
@@ -41,8 +40,8 @@ var rabbitDeleteQueueCmd = &cobra.Command{
 	Use:   "delete-queue [queue-name]",
 	Short: "Delete a queue",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		deleteQueue(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deleteQueue(args[0])
 	},
 }
 
@@ -50,16 +49,16 @@ var rabbitPurgeQueueCmd = &cobra.Command{
 	Use:   "purge [queue-name]",
 	Short: "Purge all messages from a queue",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		purgeQueue(args[0])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return purgeQueue(args[0])
 	},
 }
 
 var rabbitListExchangesCmd = &cobra.Command{
 	Use:   "exchanges",
 	Short: "List all exchanges",
-	Run: func(cmd *cobra.Command, args []string) {
-		listExchanges()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listExchanges()
 	},
 }
 
@@ -67,11 +66,11 @@ var rabbitCreateExchangeCmd = &cobra.Command{
 	Use:   "create-exchange [exchange-name]",
 	Short: "Create a new exchange",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		exchangeType, _ := cmd.Flags().GetString("type")
 		durable, _ := cmd.Flags().GetBool("durable")
 		autoDelete, _ := cmd.Flags().GetBool("auto-delete")
-		createExchange(args[0], exchangeType, durable, autoDelete)
+		return createExchange(args[0], exchangeType, durable, autoDelete)
 	},
 }
 
@@ -79,16 +78,16 @@ var rabbitPublishCmd = &cobra.Command{
 	Use:   "publish [exchange] [routing-key] [message]",
 	Short: "Publish a message to an exchange",
 	Args:  cobra.ExactArgs(3),
-	Run: func(cmd *cobra.Command, args []string) {
-		publishMessage(args[0], args[1], args[2])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return publishMessage(args[0], args[1], args[2])
 	},
 }
 
 var rabbitStatsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "Show RabbitMQ statistics",
-	Run: func(cmd *cobra.Command, args []string) {
-		showStats()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showStats()
 	},
 }
 
@@ -116,15 +115,41 @@ func init() {
 	rabbitCmd.AddCommand(rabbitStatsCmd)
 }
 
+type queueRecord struct {
+	Name      string `json:"name"`
+	VHost     string `json:"vhost"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+	Durable   bool   `json:"durable"`
+}
+
+type exchangeRecord struct {
+	Name    string `json:"name"`
+	VHost   string `json:"vhost"`
+	Type    string `json:"type"`
+	Durable bool   `json:"durable"`
+}
+
+type rabbitStatsRecord struct {
+	Messages               int `json:"messages"`
+	MessagesReady          int `json:"messages_ready"`
+	MessagesUnacknowledged int `json:"messages_unacknowledged"`
+	Connections            int `json:"connections"`
+	Channels               int `json:"channels"`
+	Exchanges              int `json:"exchanges"`
+	Queues                 int `json:"queues"`
+	Consumers              int `json:"consumers"`
+}
+
 func getRabbitMQURL(path string) string {
 	host, _ := rabbitCmd.Flags().GetString("host")
 	port, _ := rabbitCmd.Flags().GetString("port")
 
 	if host == "" {
-		host = viper.GetString("rabbitmq.host")
+		host = configString("rabbitmq.host")
 	}
 	if port == "" {
-		port = viper.GetString("rabbitmq.port")
+		port = configString("rabbitmq.port")
 	}
 
 	return fmt.Sprintf("http://%s:%s/api%s", host, port, path)
@@ -135,15 +160,23 @@ func getRabbitMQAuth() (string, string) {
 	password, _ := rabbitCmd.Flags().GetString("password")
 
 	if user == "" {
-		user = viper.GetString("rabbitmq.user")
+		user = configString("rabbitmq.user")
 	}
 	if password == "" {
-		password = viper.GetString("rabbitmq.password")
+		password = configString("rabbitmq.password")
 	}
 
 	return user, password
 }
 
+func getRabbitMQVHost() string {
+	vhost, _ := rabbitCmd.Flags().GetString("vhost")
+	if vhost == "" {
+		vhost = configString("rabbitmq.vhost")
+	}
+	return vhost
+}
+
 func makeRabbitMQRequest(method, path string, body interface{}) (*http.Response, error) {
 	client := &http.Client{}
 
@@ -171,43 +204,36 @@ func makeRabbitMQRequest(method, path string, body interface{}) (*http.Response,
 	return client.Do(req)
 }
 
-func listQueues() {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
-	if vhost == "" {
-		vhost = viper.GetString("rabbitmq.vhost")
-	}
+func listQueues() error {
+	vhost := getRabbitMQVHost()
 	resp, err := makeRabbitMQRequest("GET", fmt.Sprintf("/queues/%s", vhost), nil)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		color.Red("Error: HTTP %d", resp.StatusCode)
-		return
+		return httpStatusError(resp.StatusCode, "unexpected response from RabbitMQ")
 	}
 
-	var queues []map[string]interface{}
+	var queues []queueRecord
 	if err := json.NewDecoder(resp.Body).Decode(&queues); err != nil {
-		color.Red("Error parsing response: %v", err)
-		return
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if ok, err := renderOutput(queues); ok {
+		return err
 	}
 
 	color.Green("Queues in vhost '%s':", vhost)
 	for _, queue := range queues {
-		name := queue["name"].(string)
-		messages := int(queue["messages"].(float64))
-		consumers := int(queue["consumers"].(float64))
-		fmt.Printf("  - %s (messages: %d, consumers: %d)\n", name, messages, consumers)
+		fmt.Printf("  - %s (messages: %d, consumers: %d)\n", queue.Name, queue.Messages, queue.Consumers)
 	}
+	return nil
 }
 
-func createQueue(queueName string, durable, autoDelete bool) {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
-	if vhost == "" {
-		vhost = viper.GetString("rabbitmq.vhost")
-	}
+func createQueue(queueName string, durable, autoDelete bool) error {
+	vhost := getRabbitMQVHost()
 	body := map[string]interface{}{
 		"durable":     durable,
 		"auto_delete": autoDelete,
@@ -215,89 +241,78 @@ func createQueue(queueName string, durable, autoDelete bool) {
 
 	resp, err := makeRabbitMQRequest("PUT", fmt.Sprintf("/queues/%s/%s", vhost, queueName), body)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusNoContent {
-		color.Green("Queue '%s' created successfully", queueName)
-	} else {
-		color.Red("Error creating queue: HTTP %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return httpStatusError(resp.StatusCode, "failed to create queue '%s'", queueName)
 	}
+	return reportAction("create-queue", queueName, "created", "Queue '%s' created successfully", queueName)
 }
 
-func deleteQueue(queueName string) {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
-	if vhost == "" {
-		vhost = viper.GetString("rabbitmq.vhost")
-	}
+func deleteQueue(queueName string) error {
+	vhost := getRabbitMQVHost()
 	resp, err := makeRabbitMQRequest("DELETE", fmt.Sprintf("/queues/%s/%s", vhost, queueName), nil)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNoContent {
-		color.Green("Queue '%s' deleted successfully", queueName)
-	} else {
-		color.Red("Error deleting queue: HTTP %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusNoContent {
+		return httpStatusError(resp.StatusCode, "failed to delete queue '%s'", queueName)
 	}
+	return reportAction("delete-queue", queueName, "deleted", "Queue '%s' deleted successfully", queueName)
 }
 
-func purgeQueue(queueName string) {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
-	if vhost == "" {
-		vhost = viper.GetString("rabbitmq.vhost")
-	}
+func purgeQueue(queueName string) error {
+	vhost := getRabbitMQVHost()
 	resp, err := makeRabbitMQRequest("DELETE", fmt.Sprintf("/queues/%s/%s/contents", vhost, queueName), nil)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNoContent {
-		color.Green("Queue '%s' purged successfully", queueName)
-	} else {
-		color.Red("Error purging queue: HTTP %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusNoContent {
+		return httpStatusError(resp.StatusCode, "failed to purge queue '%s'", queueName)
 	}
+	return reportAction("purge", queueName, "purged", "Queue '%s' purged successfully", queueName)
 }
 
-func listExchanges() {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
+func listExchanges() error {
+	vhost := getRabbitMQVHost()
 	resp, err := makeRabbitMQRequest("GET", fmt.Sprintf("/exchanges/%s", vhost), nil)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		color.Red("Error: HTTP %d", resp.StatusCode)
-		return
+		return httpStatusError(resp.StatusCode, "unexpected response from RabbitMQ")
 	}
 
-	var exchanges []map[string]interface{}
+	var exchanges []exchangeRecord
 	if err := json.NewDecoder(resp.Body).Decode(&exchanges); err != nil {
-		color.Red("Error parsing response: %v", err)
-		return
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if ok, err := renderOutput(exchanges); ok {
+		return err
 	}
 
 	color.Green("Exchanges in vhost '%s':", vhost)
 	for _, exchange := range exchanges {
-		name := exchange["name"].(string)
+		name := exchange.Name
 		if name == "" {
 			name = "(default)"
 		}
-		exchangeType := exchange["type"].(string)
-		fmt.Printf("  - %s (type: %s)\n", name, exchangeType)
+		fmt.Printf("  - %s (type: %s)\n", name, exchange.Type)
 	}
+	return nil
 }
 
-func createExchange(exchangeName, exchangeType string, durable, autoDelete bool) {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
+func createExchange(exchangeName, exchangeType string, durable, autoDelete bool) error {
+	vhost := getRabbitMQVHost()
 	body := map[string]interface{}{
 		"type":        exchangeType,
 		"durable":     durable,
@@ -306,20 +321,18 @@ func createExchange(exchangeName, exchangeType string, durable, autoDelete bool)
 
 	resp, err := makeRabbitMQRequest("PUT", fmt.Sprintf("/exchanges/%s/%s", vhost, exchangeName), body)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusNoContent {
-		color.Green("Exchange '%s' created successfully", exchangeName)
-	} else {
-		color.Red("Error creating exchange: HTTP %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return httpStatusError(resp.StatusCode, "failed to create exchange '%s'", exchangeName)
 	}
+	return reportAction("create-exchange", exchangeName, "created", "Exchange '%s' created successfully", exchangeName)
 }
 
-func publishMessage(exchange, routingKey, message string) {
-	vhost, _ := rabbitCmd.Flags().GetString("vhost")
+func publishMessage(exchange, routingKey, message string) error {
+	vhost := getRabbitMQVHost()
 	body := map[string]interface{}{
 		"properties":       map[string]interface{}{},
 		"routing_key":      routingKey,
@@ -329,68 +342,69 @@ func publishMessage(exchange, routingKey, message string) {
 
 	resp, err := makeRabbitMQRequest("POST", fmt.Sprintf("/exchanges/%s/%s/publish", vhost, exchange), body)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		color.Green("Message published successfully")
-	} else {
-		color.Red("Error publishing message: HTTP %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError(resp.StatusCode, "failed to publish message")
 	}
+	return reportAction("publish", exchange+"/"+routingKey, "published", "Message published successfully")
 }
 
-func showStats() {
+func showStats() error {
 	resp, err := makeRabbitMQRequest("GET", "/overview", nil)
 	if err != nil {
-		color.Red("Error: %v", err)
-		return
+		return wrapError(err, "failed to reach RabbitMQ management API")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		color.Red("Error: HTTP %d", resp.StatusCode)
-		return
+		return httpStatusError(resp.StatusCode, "unexpected response from RabbitMQ")
 	}
 
-	var stats map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
-		color.Red("Error parsing response: %v", err)
-		return
+	var overview struct {
+		QueueTotals struct {
+			Messages               int `json:"messages"`
+			MessagesReady          int `json:"messages_ready"`
+			MessagesUnacknowledged int `json:"messages_unacknowledged"`
+		} `json:"queue_totals"`
+		ObjectTotals struct {
+			Connections int `json:"connections"`
+			Channels    int `json:"channels"`
+			Exchanges   int `json:"exchanges"`
+			Queues      int `json:"queues"`
+			Consumers   int `json:"consumers"`
+		} `json:"object_totals"`
 	}
-
-	color.Green("RabbitMQ Statistics:")
-
-	if queueTotals, ok := stats["queue_totals"].(map[string]interface{}); ok {
-		if messages, ok := queueTotals["messages"].(float64); ok {
-			fmt.Printf("  Total Messages: %d\n", int(messages))
-		}
-		if messagesReady, ok := queueTotals["messages_ready"].(float64); ok {
-			fmt.Printf("  Messages Ready: %d\n", int(messagesReady))
-		}
-		if messagesUnacknowledged, ok := queueTotals["messages_unacknowledged"].(float64); ok {
-			fmt.Printf("  Messages Unacknowledged: %d\n", int(messagesUnacknowledged))
-		}
+	if err := json.NewDecoder(resp.Body).Decode(&overview); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	if objectTotals, ok := stats["object_totals"].(map[string]interface{}); ok {
-		if connections, ok := objectTotals["connections"].(float64); ok {
-			fmt.Printf("  Connections: %d\n", int(connections))
-		}
-		if channels, ok := objectTotals["channels"].(float64); ok {
-			fmt.Printf("  Channels: %d\n", int(channels))
-		}
-		if exchanges, ok := objectTotals["exchanges"].(float64); ok {
-			fmt.Printf("  Exchanges: %d\n", int(exchanges))
-		}
-		if queues, ok := objectTotals["queues"].(float64); ok {
-			fmt.Printf("  Queues: %d\n", int(queues))
-		}
-		if consumers, ok := objectTotals["consumers"].(float64); ok {
-			fmt.Printf("  Consumers: %d\n", int(consumers))
-		}
+	stats := rabbitStatsRecord{
+		Messages:               overview.QueueTotals.Messages,
+		MessagesReady:          overview.QueueTotals.MessagesReady,
+		MessagesUnacknowledged: overview.QueueTotals.MessagesUnacknowledged,
+		Connections:            overview.ObjectTotals.Connections,
+		Channels:               overview.ObjectTotals.Channels,
+		Exchanges:              overview.ObjectTotals.Exchanges,
+		Queues:                 overview.ObjectTotals.Queues,
+		Consumers:              overview.ObjectTotals.Consumers,
+	}
+	if ok, err := renderOutput(stats); ok {
+		return err
 	}
+
+	color.Green("RabbitMQ Statistics:")
+	fmt.Printf("  Total Messages: %d\n", stats.Messages)
+	fmt.Printf("  Messages Ready: %d\n", stats.MessagesReady)
+	fmt.Printf("  Messages Unacknowledged: %d\n", stats.MessagesUnacknowledged)
+	fmt.Printf("  Connections: %d\n", stats.Connections)
+	fmt.Printf("  Channels: %d\n", stats.Channels)
+	fmt.Printf("  Exchanges: %d\n", stats.Exchanges)
+	fmt.Printf("  Queues: %d\n", stats.Queues)
+	fmt.Printf("  Consumers: %d\n", stats.Consumers)
+	return nil
 }
 
 func GetRabbitMQCommand() *cobra.Command {
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gofrs/flock"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+var dbScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Show scheduled backups with their next and last runs",
+	Long: `Show the backup schedules configured under schedules in the config file,
+when each runs next and how its last run went. Run them with tools daemon.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showSchedules()
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbScheduleCmd)
+}
+
+// scheduleConfig is one entry of the schedules list in the config file.
+type scheduleConfig struct {
+	Name      string `mapstructure:"name"`
+	Database  string `mapstructure:"database"`
+	Cron      string `mapstructure:"cron"`
+	Location  string `mapstructure:"location"`
+	Format    string `mapstructure:"format"`
+	Compress  string `mapstructure:"compress"`
+	Jobs      int    `mapstructure:"jobs"`
+	Encrypt   string `mapstructure:"encrypt"`
+	KeyFile   string `mapstructure:"key_file"`
+	Retention struct {
+		KeepLast    int `mapstructure:"keep_last"`
+		KeepDaily   int `mapstructure:"keep_daily"`
+		KeepWeekly  int `mapstructure:"keep_weekly"`
+		KeepMonthly int `mapstructure:"keep_monthly"`
+	} `mapstructure:"retention"`
+}
+
+// backupJob is a validated schedule ready to run.
+type backupJob struct {
+	Name     string
+	Database string
+	Spec     string
+	Schedule cron.Schedule
+	Location string
+	Options  backupOptions
+	Policy   retentionPolicy
+}
+
+type scheduleRecord struct {
+	Name       string     `json:"name"`
+	Database   string     `json:"database"`
+	Cron       string     `json:"cron"`
+	Location   string     `json:"location"`
+	Format     string     `json:"format"`
+	NextRun    time.Time  `json:"next_run"`
+	LastRun    *time.Time `json:"last_run"`
+	LastStatus string     `json:"last_status"`
+}
+
+// runRecord is one line of the run log.
+type runRecord struct {
+	Job        string    `json:"job"`
+	Database   string    `json:"database"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Statuses written to the run log.
+const (
+	runSuccess = "success"
+	runFailed  = "failed"
+	runSkipped = "skipped"
+)
+
+// loadBackupJobs reads and validates the schedules from the config file.
+// Location, format and retention fall back to the backups settings.
+func loadBackupJobs() ([]backupJob, error) {
+	var configs []scheduleConfig
+	if err := settingUnmarshal("schedules", &configs); err != nil {
+		return nil, usageError("invalid schedules in config file: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil, notFoundError("no schedules configured, add a schedules list to the config file")
+	}
+
+	seen := make(map[string]bool)
+	jobs := make([]backupJob, 0, len(configs))
+	for i, config := range configs {
+		if config.Name == "" {
+			config.Name = config.Database
+		}
+		if config.Name == "" {
+			return nil, usageError("schedule %d: name or database is required", i+1)
+		}
+		if seen[config.Name] {
+			return nil, usageError("schedule '%s' is defined twice", config.Name)
+		}
+		seen[config.Name] = true
+
+		if config.Database == "" {
+			return nil, usageError("schedule '%s': database is required", config.Name)
+		}
+		schedule, err := cron.ParseStandard(config.Cron)
+		if err != nil {
+			return nil, usageError("schedule '%s': invalid cron expression '%s': %w", config.Name, config.Cron, err)
+		}
+
+		job := backupJob{
+			Name:     config.Name,
+			Database: config.Database,
+			Spec:     config.Cron,
+			Schedule: schedule,
+			Location: config.Location,
+			Options: backupOptions{
+				Format:      config.Format,
+				Compression: config.Compress,
+				Jobs:        config.Jobs,
+				Encryption:  config.Encrypt,
+				KeyFile:     config.KeyFile,
+			},
+			Policy: retentionPolicy{
+				Last:    config.Retention.KeepLast,
+				Daily:   config.Retention.KeepDaily,
+				Weekly:  config.Retention.KeepWeekly,
+				Monthly: config.Retention.KeepMonthly,
+			},
+		}
+		if job.Location == "" {
+			job.Location = settingString("backups.location")
+		}
+		if job.Location == "" {
+			return nil, usageError("schedule '%s': location is required when backups.location is not set", config.Name)
+		}
+		if job.Options.Format == "" {
+			job.Options.Format = formatCustom
+		}
+		if job.Options.Jobs == 0 {
+			job.Options.Jobs = 1
+		}
+		if err := job.Options.validate(); err != nil {
+			return nil, usageError("schedule '%s': %w", config.Name, err)
+		}
+		if job.Policy.empty() {
+			job.Policy = retentionPolicy{
+				Last:    settingInt("backups.retention.keep_last"),
+				Daily:   settingInt("backups.retention.keep_daily"),
+				Weekly:  settingInt("backups.retention.keep_weekly"),
+				Monthly: settingInt("backups.retention.keep_monthly"),
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// stateDir holds the run log and lock files, daemon.state_dir in the config
+// file or <user config dir>/tools.
+func stateDir() (string, error) {
+	dir := settingString("daemon.state_dir")
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(configDir, "tools")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "locks"), 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func runLogPath(dir string) string {
+	if path := settingString("daemon.run_log"); path != "" {
+		return path
+	}
+	return filepath.Join(dir, "backup-runs.log")
+}
+
+// lockFile returns the lock guarding name. The locks are OS file locks, so
+// they also hold against other tools processes and are released when a
+// process dies.
+func lockFile(dir, name string) *flock.Flock {
+	return flock.New(filepath.Join(dir, "locks", name+".lock"))
+}
+
+// locationLockName identifies a backup location, whose manifest must not be
+// updated by two jobs at once.
+func locationLockName(location string) string {
+	sum := sha1.Sum([]byte(location))
+	return "location-" + hex.EncodeToString(sum[:8])
+}
+
+var runLogMu sync.Mutex
+
+func appendRunLog(path string, record runRecord) error {
+	runLogMu.Lock()
+	defer runLogMu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// lastRuns returns the latest run log record of every job.
+func lastRuns(path string) (map[string]runRecord, error) {
+	runs := make(map[string]runRecord)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return runs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record runRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			runs[record.Job] = record
+		}
+	}
+	return runs, scanner.Err()
+}
+
+// runBackupJob takes one backup for job and applies its retention. It skips
+// the run when the previous one still holds the job lock, and waits for
+// other jobs writing to the same location.
+func runBackupJob(dir string, job backupJob) runRecord {
+	record := runRecord{Job: job.Name, Database: job.Database, StartedAt: time.Now().UTC()}
+	finish := func(status string, err error) runRecord {
+		record.FinishedAt = time.Now().UTC()
+		record.Status = status
+		if err != nil {
+			record.Error = err.Error()
+		}
+		return record
+	}
+
+	jobLock := lockFile(dir, job.Name)
+	locked, err := jobLock.TryLock()
+	if err != nil {
+		return finish(runFailed, fmt.Errorf("failed to lock job: %w", err))
+	}
+	if !locked {
+		return finish(runSkipped, fmt.Errorf("previous run still in progress"))
+	}
+	defer jobLock.Unlock()
+
+	locationLock := lockFile(dir, locationLockName(job.Location))
+	if err := locationLock.Lock(); err != nil {
+		return finish(runFailed, fmt.Errorf("failed to lock backup location: %w", err))
+	}
+	defer locationLock.Unlock()
+
+	if err := backupForJob(job); err != nil {
+		return finish(runFailed, err)
+	}
+	if !job.Policy.empty() {
+		if err := pruneBackups(job.Location, job.Database, job.Policy, false); err != nil {
+			return finish(runFailed, fmt.Errorf("backup succeeded but retention failed: %w", err))
+		}
+	}
+	return finish(runSuccess, nil)
+}
+
+func backupForJob(job backupJob) error {
+	if strings.HasPrefix(job.Location, "s3://") {
+		target := job.Location
+		if !strings.HasSuffix(target, "/") {
+			target += "/"
+		}
+		return backupToS3(job.Database, target, "", job.Options)
+	}
+
+	if err := os.MkdirAll(job.Location, 0o755); err != nil {
+		return err
+	}
+	outputFile := filepath.Join(job.Location, timestampedName(job.Database, time.Now()))
+	return backupDatabase(job.Database, outputFile, job.Options)
+}
+
+func showSchedules() error {
+	jobs, err := loadBackupJobs()
+	if err != nil {
+		return err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return wrapError(err, "failed to open state directory")
+	}
+	runs, err := lastRuns(runLogPath(dir))
+	if err != nil {
+		return wrapError(err, "failed to read run log")
+	}
+
+	now := time.Now()
+	records := make([]scheduleRecord, 0, len(jobs))
+	for _, job := range jobs {
+		record := scheduleRecord{
+			Name:     job.Name,
+			Database: job.Database,
+			Cron:     job.Spec,
+			Location: job.Location,
+			Format:   job.Options.Format,
+			NextRun:  job.Schedule.Next(now),
+		}
+		if run, ok := runs[job.Name]; ok {
+			started := run.StartedAt
+			record.LastRun = &started
+			record.LastStatus = run.Status
+		}
+		records = append(records, record)
+	}
+
+	if ok, err := renderOutput(records); ok {
+		return err
+	}
+
+	color.Green("Backup schedules:")
+	var rows [][]string
+	for _, record := range records {
+		last := "never"
+		if record.LastRun != nil {
+			last = fmt.Sprintf("%s (%s)", record.LastRun.Local().Format("2006-01-02 15:04"), record.LastStatus)
+		}
+		rows = append(rows, []string{
+			record.Name,
+			record.Database,
+			record.Cron,
+			record.Location,
+			record.NextRun.Format("2006-01-02 15:04"),
+			last,
+		})
+	}
+	return writeTable(os.Stdout, []string{"name", "database", "cron", "location", "next run", "last run"}, rows)
+}
@@ -17,15 +17,14 @@ var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update tools to the latest version",
 	Long:  `Check for updates and install the latest version of tools`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		force, _ := cmd.Flags().GetBool("force")
 		check, _ := cmd.Flags().GetBool("check")
 
 		if check {
-			checkForUpdates()
-		} else {
-			performUpdate(force)
+			return checkForUpdates()
 		}
+		return performUpdate(force)
 	},
 }
 
@@ -33,8 +32,8 @@ var selfUpdateCmd = &cobra.Command{
 	Use:   "self-update",
 	Short: "Update the tools binary itself",
 	Long:  `Download and install the latest version of the tools binary`,
-	Run: func(cmd *cobra.Command, args []string) {
-		performSelfUpdate()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return performSelfUpdate()
 	},
 }
 
@@ -43,14 +42,13 @@ func init() {
 	updateCmd.Flags().BoolP("check", "c", false, "Only check for updates, don't install")
 }
 
-func checkForUpdates() {
+func checkForUpdates() error {
 	color.Yellow("Checking for updates...")
 
 	// Get current executable path
 	execPath, err := os.Executable()
 	if err != nil {
-		color.Red("Error getting executable path: %v", err)
-		return
+		return wrapError(err, "failed to get executable path")
 	}
 
 	installDir := filepath.Dir(execPath)
@@ -61,18 +59,14 @@ func checkForUpdates() {
 		color.Yellow("Not a git repository. Cannot check for updates.")
 		color.Yellow("To enable updates, clone the repository:")
 		fmt.Printf("  git clone <repository-url> %s\n", installDir)
-		return
+		return nil
 	}
 
 	// Fetch latest changes
 	fetchCmd := exec.Command("git", "fetch", "origin")
 	fetchCmd.Dir = installDir
 	if output, err := fetchCmd.CombinedOutput(); err != nil {
-		color.Red("Error fetching updates: %v", err)
-		if len(output) > 0 {
-			fmt.Println(string(output))
-		}
-		return
+		return wrapError(toolOutputError(err, output), "failed to fetch updates")
 	}
 
 	// Check if updates are available
@@ -80,8 +74,7 @@ func checkForUpdates() {
 	statusCmd.Dir = installDir
 	output, err := statusCmd.CombinedOutput()
 	if err != nil {
-		color.Red("Error checking status: %v", err)
-		return
+		return wrapError(err, "failed to check status")
 	}
 
 	if strings.Contains(string(output), "Your branch is behind") {
@@ -100,16 +93,16 @@ func checkForUpdates() {
 	} else {
 		fmt.Println(string(output))
 	}
+	return nil
 }
 
-func performUpdate(force bool) {
+func performUpdate(force bool) error {
 	color.Yellow("Starting update process...")
 
 	// Get current executable path
 	execPath, err := os.Executable()
 	if err != nil {
-		color.Red("Error getting executable path: %v", err)
-		return
+		return wrapError(err, "failed to get executable path")
 	}
 
 	installDir := filepath.Dir(execPath)
@@ -121,8 +114,7 @@ func performUpdate(force bool) {
 		color.Yellow("Initializing git repository...")
 		if err := initGitRepo(installDir); err != nil {
 			color.Red("Failed to initialize git repository: %v", err)
-			performSelfUpdate()
-			return
+			return performSelfUpdate()
 		}
 	}
 
@@ -151,14 +143,11 @@ func performUpdate(force bool) {
 			resetCmd := exec.Command("git", "reset", "--hard", "origin/main")
 			resetCmd.Dir = installDir
 			if _, err := resetCmd.CombinedOutput(); err != nil {
-				color.Red("Error resolving conflicts: %v", err)
-				return
+				return wrapError(err, "failed to resolve conflicts")
 			}
 			color.Green("✓ Conflicts resolved by using latest version")
 		} else {
-			color.Red("Error pulling updates: %v", err)
-			fmt.Println(string(pullOutput))
-			return
+			return wrapError(toolOutputError(err, pullOutput), "failed to pull updates")
 		}
 	} else {
 		fmt.Println(string(pullOutput))
@@ -176,7 +165,7 @@ func performUpdate(force bool) {
 			popCmd.Dir = installDir
 			popCmd.CombinedOutput()
 		}
-		return
+		return nil
 	}
 
 	// Install dependencies
@@ -196,8 +185,7 @@ func performUpdate(force bool) {
 	buildCmd.Dir = installDir
 	buildOutput, err := buildCmd.CombinedOutput()
 	if err != nil {
-		color.Red("Error building new version: %v", err)
-		fmt.Println(string(buildOutput))
+		buildErr := toolOutputError(err, buildOutput)
 
 		// Restore stashed changes if any
 		if hasStash {
@@ -205,7 +193,7 @@ func performUpdate(force bool) {
 			popCmd.Dir = installDir
 			popCmd.CombinedOutput()
 		}
-		return
+		return wrapError(buildErr, "failed to build new version")
 	}
 
 	// Replace old executable
@@ -215,15 +203,13 @@ func performUpdate(force bool) {
 
 	// Backup current executable
 	if err := copyFile(oldExe, backupExe); err != nil {
-		color.Red("Error creating backup: %v", err)
-		return
+		return wrapError(err, "failed to create backup")
 	}
 
 	// Windows-specific: Schedule replacement using batch file
 	if runtime.GOOS == "windows" {
 		if err := scheduleReplacement(oldExe, newExe, backupExe); err != nil {
-			color.Red("Error scheduling update: %v", err)
-			return
+			return wrapError(err, "failed to schedule update")
 		}
 
 		color.Green("✓ Update downloaded successfully!")
@@ -232,10 +218,9 @@ func performUpdate(force bool) {
 	} else {
 		// Direct replacement for non-Windows systems
 		if err := os.Rename(newExe, oldExe); err != nil {
-			color.Red("Error replacing executable: %v", err)
 			// Restore from backup
 			os.Rename(backupExe, oldExe)
-			return
+			return wrapError(err, "failed to replace executable")
 		}
 
 		color.Green("✓ Update completed successfully!")
@@ -260,16 +245,16 @@ func performUpdate(force bool) {
 
 	// Show what's new
 	showChangelog(installDir)
+	return nil
 }
 
-func performSelfUpdate() {
+func performSelfUpdate() error {
 	color.Yellow("Performing self-update...")
 
 	// Get current executable
 	execPath, err := os.Executable()
 	if err != nil {
-		color.Red("Error getting executable path: %v", err)
-		return
+		return wrapError(err, "failed to get executable path")
 	}
 
 	installDir := filepath.Dir(execPath)
@@ -285,8 +270,7 @@ del "%%~f0"
 `, installDir, installDir)
 
 	if err := os.WriteFile(updateScript, []byte(scriptContent), 0755); err != nil {
-		color.Red("Error creating update script: %v", err)
-		return
+		return wrapError(err, "failed to create update script")
 	}
 
 	// Download new version (this would typically download from a release URL)
@@ -294,21 +278,19 @@ del "%%~f0"
 	buildCmd := exec.Command("go", "build", "-o", "tools.exe.new", ".")
 	buildCmd.Dir = installDir
 	if output, err := buildCmd.CombinedOutput(); err != nil {
-		color.Red("Error building new version: %v", err)
-		fmt.Println(string(output))
-		return
+		return wrapError(toolOutputError(err, output), "failed to build new version")
 	}
 
 	// Execute update script
 	cmd := exec.Command("cmd", "/c", "start", "/b", updateScript)
 	if err := cmd.Start(); err != nil {
-		color.Red("Error starting update: %v", err)
-		return
+		return wrapError(err, "failed to start update")
 	}
 
 	color.Green("✓ Update in progress...")
 	fmt.Println("The application will restart with the new version.")
 	os.Exit(0)
+	return nil
 }
 
 func initGitRepo(dir string) error {
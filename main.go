@@ -29,7 +29,11 @@ Environment Variables:
   MinIO:
     MINIO_ENDPOINT, MINIO_ACCESS_KEY (or MINIO_ROOT_USER),
     MINIO_SECRET_KEY (or MINIO_ROOT_PASSWORD), MINIO_USE_SSL
-    or TOOLS_MINIO_ENDPOINT, TOOLS_MINIO_ACCESS_KEY, etc.`,
+    or TOOLS_MINIO_ENDPOINT, TOOLS_MINIO_ACCESS_KEY, etc.
+
+Profiles:
+  Named connection profiles can be defined in tools.yaml (or tools.toml)
+  and selected with --profile, TOOLS_PROFILE or 'tools config use'.`,
 	Version: Version,
 }
 
@@ -42,13 +46,17 @@ func main() {
 	rootCmd.AddCommand(cmd.GetRabbitMQCommand())
 	rootCmd.AddCommand(cmd.GetMinIOCommand())
 	rootCmd.AddCommand(cmd.GetConfigCommand())
+	rootCmd.AddCommand(cmd.GetDaemonCommand())
 	rootCmd.AddCommand(cmd.GetUpdateCommand())
 
+	// Register global flags once the command tree is complete
+	cmd.RegisterGlobalFlags(rootCmd)
+
 	// Disable default completion command
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }